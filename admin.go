@@ -0,0 +1,347 @@
+//go:build !minimal
+
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// adminAuthConfigured reports whether any admin credentials are set.
+func adminAuthConfigured(cfg *Config) bool {
+	return cfg.AdminToken != "" || cfg.AdminUser != ""
+}
+
+// adminAuthorized checks r for ADMIN_TOKEN as a bearer token or
+// ADMIN_USER/ADMIN_PASSWORD as basic auth. presented is false when r carries
+// no credentials at all.
+func adminAuthorized(cfg *Config, r *http.Request) (ok, presented bool) {
+	if token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); found {
+		return cfg.AdminToken != "" && secureEqual(token, cfg.AdminToken), true
+	}
+	if user, password, found := r.BasicAuth(); found {
+		// Evaluate both so the timing does not reveal which one was wrong
+		userOK := secureEqual(user, cfg.AdminUser)
+		passwordOK := secureEqual(password, cfg.AdminPassword)
+		return cfg.AdminUser != "" && userOK && passwordOK, true
+	}
+	return false, false
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// crossSiteSafe reports whether r could not have been sent by a cross-site
+// form. Browsers resend cached basic credentials with such forms, but they
+// can only make simple requests, so state changes need a bearer token, a
+// JSON body or an X-Requested-With header.
+func crossSiteSafe(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	if strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") || r.Header.Get("X-Requested-With") != "" {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/json"
+}
+
+// requireAdmin guards the admin API and web UI. Without any credentials
+// configured both are disabled.
+func requireAdmin(cfg *Config, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !adminAuthConfigured(cfg) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "disabled, set ADMIN_TOKEN or ADMIN_USER/ADMIN_PASSWORD to enable it"})
+			return
+		}
+		ok, presented := adminAuthorized(cfg, r)
+		if !ok {
+			if presented {
+				log.Printf("[HTTP WARN] rejected admin credentials for %s %s (%s)", r.Method, r.URL.Path, requestActor(cfg, r))
+			}
+			// Offer basic auth when configured so browsers prompt for it
+			if cfg.AdminUser != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="gotify-to-ntfy", charset="UTF-8"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="gotify-to-ntfy"`)
+			}
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		if !crossSiteSafe(r) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "send a bearer token, a JSON body or an X-Requested-With header"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireSnooze guards the snooze endpoints: the request needs the token of
+// the app's snooze action (see snoozeToken) or admin credentials.
+func requireSnooze(cfg *Config, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err == nil && validSnoozeToken(cfg, appID, r.URL.Query().Get("token")) {
+			next(w, r)
+			return
+		}
+		if ok, _ := adminAuthorized(cfg, r); ok && crossSiteSafe(r) {
+			next(w, r)
+			return
+		}
+		log.Printf("[HTTP WARN] rejected snooze request %s %s (%s)", r.Method, r.URL.Path, requestActor(cfg, r))
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "invalid or missing snooze token"})
+	}
+}
+
+type adminStatus struct {
+	StatsSnapshot
+	Paused         bool     `json:"paused"`
+	ObserveOnly    bool     `json:"observe_only"`
+	Gotify         string   `json:"gotify"`
+	StreamQueue    int      `json:"stream_queue"`
+	OfflineBuffer  int      `json:"offline_buffer"`
+	ReservedTopics []string `json:"reserved_topics,omitempty"`
+	Version        string   `json:"version"`
+}
+
+func handleAdminStatus(b *Bridge) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s := adminStatus{
+			StatsSnapshot:  b.stats.Snapshot(b.store),
+			Paused:         b.paused.Load(),
+			ObserveOnly:    b.cfg.ObserveOnly,
+			Gotify:         redactURL(b.cfg.ActiveGotifyURL()),
+			OfflineBuffer:  b.buffer.Len(),
+			ReservedTopics: b.reserved.All(),
+			Version:        version,
+		}
+		if q := b.queue.Load(); q != nil {
+			s.StreamQueue = len(*q)
+		}
+		writeJSON(w, http.StatusOK, s)
+	}
+}
+
+type queueDepth struct {
+	Length   int `json:"length"`
+	Capacity int `json:"capacity,omitempty"`
+}
+
+func chanDepth(q *chan GotifyMessage) queueDepth {
+	if q == nil {
+		return queueDepth{}
+	}
+	return queueDepth{Length: len(*q), Capacity: cap(*q)}
+}
+
+type queueMessage struct {
+	MessageID  int64   `json:"message_id"`
+	AppID      int64   `json:"app_id"`
+	App        string  `json:"app,omitempty"`
+	AgeSeconds float64 `json:"age_seconds"`
+}
+
+type queueWorker struct {
+	ID        int           `json:"id"`
+	Critical  bool          `json:"critical,omitempty"`
+	Forwarded int64         `json:"forwarded"`
+	Failed    int64         `json:"failed"`
+	Buffered  int64         `json:"buffered"`
+	InFlight  *queueMessage `json:"in_flight,omitempty"`
+}
+
+type queueBuffer struct {
+	queueDepth
+	OldestAgeSeconds float64 `json:"oldest_age_seconds,omitempty"`
+}
+
+// queueStatus shows where messages wait: the stream queues, what each
+// worker is forwarding and the offline (retry) buffer.
+type queueStatus struct {
+	Connected     bool          `json:"connected"`
+	Paused        bool          `json:"paused"`
+	StreamQueue   queueDepth    `json:"stream_queue"`
+	CriticalQueue queueDepth    `json:"critical_queue"`
+	Workers       []queueWorker `json:"workers"`
+	OfflineBuffer queueBuffer   `json:"offline_buffer"`
+	Dropped       int64         `json:"dropped"`
+
+	// Topics paused after a 429 from ntfy, and until when
+	RateLimited map[string]time.Time `json:"rate_limited,omitempty"`
+}
+
+func ageSeconds(since time.Time) float64 {
+	return time.Since(since).Round(time.Millisecond).Seconds()
+}
+
+func handleQueue(b *Bridge) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s := queueStatus{
+			Connected:     b.stats.Connected(),
+			Paused:        b.paused.Load(),
+			StreamQueue:   chanDepth(b.queue.Load()),
+			CriticalQueue: chanDepth(b.criticalQueue.Load()),
+			OfflineBuffer: queueBuffer{queueDepth: queueDepth{Length: b.buffer.Len(), Capacity: b.cfg.OfflineBufferSize}},
+			Dropped:       b.stats.Snapshot(b.store).Dropped,
+			RateLimited:   b.limited.All(),
+		}
+		for i := range b.workers {
+			ws := &b.workers[i]
+			qw := queueWorker{ID: i + 1, Critical: i == streamWorkers,
+				Forwarded: ws.forwarded.Load(), Failed: ws.failed.Load(), Buffered: ws.buffered.Load()}
+			if cur := ws.current.Load(); cur != nil {
+				app, _ := b.store.Get(cur.AppID)
+				qw.InFlight = &queueMessage{MessageID: cur.MessageID, AppID: cur.AppID, App: app.Name, AgeSeconds: ageSeconds(cur.Started)}
+			}
+			s.Workers = append(s.Workers, qw)
+		}
+		if oldest, ok := b.buffer.Oldest(); ok {
+			s.OfflineBuffer.OldestAgeSeconds = ageSeconds(oldest)
+		}
+		writeJSON(w, http.StatusOK, s)
+	}
+}
+
+type adminApp struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Topic       string `json:"topic"`
+	Muted       bool   `json:"muted"`
+}
+
+func handleAdminApps(b *Bridge) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		out := []adminApp{}
+		for _, app := range b.store.All() {
+			topic := b.cfg.NtfyTopic
+			if b.cfg.SplitTopics {
+				topic = b.store.TopicFor(app.ID, b.cfg.NtfyTopic)
+			}
+			out = append(out, adminApp{ID: app.ID, Name: app.Name, Description: app.Description,
+				Topic: topic, Muted: b.mutes.Muted(app.ID)})
+		}
+		writeJSON(w, http.StatusOK, out)
+	}
+}
+
+// handlePause stops (pause=true) or restarts forwarding. While paused, messages
+// wait in the offline buffer and are delivered in order on resume; critical
+// messages are still sent right away.
+func handlePause(b *Bridge, pause bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		action := "resume"
+		if pause {
+			action = "pause"
+		}
+		if b.paused.Swap(pause) != pause {
+			b.audit.Record(r.Context(), requestActor(b.cfg, r), action, nil)
+		}
+		if !pause {
+			b.buffer.Kick()
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"paused": pause, "offline_buffer": b.buffer.Len()})
+	}
+}
+
+// handleTestMessage publishes a message through the same path as live Gotify
+// messages, like the send command, so routing, profiles and templates can be
+// checked without Gotify traffic. Instead of an app_id the request may name a
+// topic, which tests the first app the topic rules route there.
+func handleTestMessage(b *Bridge) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := struct {
+			AppID    int64  `json:"app_id"`
+			Topic    string `json:"topic"`
+			Title    string `json:"title"`
+			Message  string `json:"message"`
+			Priority int    `json:"priority"`
+		}{Title: "Test message", Message: "Test message from gotify-to-ntfy-push"}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON: " + err.Error()})
+				return
+			}
+		}
+		if req.Priority < 0 || req.Priority > 10 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "priority must be 0-10"})
+			return
+		}
+		if req.AppID != 0 && req.Topic != "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "set either app_id or topic"})
+			return
+		}
+
+		msg := GotifyMessage{AppID: req.AppID, Title: req.Title, Message: req.Message, Priority: req.Priority, received: time.Now()}
+		if req.Topic != "" {
+			app, ok := appForTopic(b, req.Topic, msg)
+			if !ok {
+				writeJSON(w, http.StatusNotFound, map[string]string{"error": "no app is routed to topic " + req.Topic})
+				return
+			}
+			msg.AppID = app.ID
+		}
+		b.audit.Record(r.Context(), requestActor(b.cfg, r), "test-message", map[string]any{"app_id": msg.AppID, "topic": req.Topic, "priority": req.Priority})
+
+		topic, rule := resolveWith(b.cfg, b.store, msg, b.cfg.Rules().active)
+		app, _ := b.store.Get(msg.AppID)
+		resp := map[string]any{"app_id": msg.AppID, "app": app.Name, "topic": topic}
+		if rule != "" {
+			resp["rule"] = rule
+		}
+		if b.mutes.Muted(msg.AppID) {
+			resp["status"] = "muted"
+			writeJSON(w, http.StatusOK, resp)
+			return
+		}
+		if b.cfg.dropsPriority(msg.Priority) {
+			resp["status"] = "zero_priority"
+			writeJSON(w, http.StatusOK, resp)
+			return
+		}
+		err := forwardToNtfy(r.Context(), b, msg)
+		switch {
+		case errors.Is(err, errHeld):
+			resp["status"] = "held"
+			writeJSON(w, http.StatusAccepted, resp)
+		case errors.Is(err, errBuffered):
+			resp["status"] = "buffered"
+			writeJSON(w, http.StatusAccepted, resp)
+		case err != nil:
+			resp["error"] = err.Error()
+			writeJSON(w, http.StatusBadGateway, resp)
+		default:
+			resp["status"] = "sent"
+			writeJSON(w, http.StatusOK, resp)
+		}
+	}
+}
+
+// appForTopic returns the first app, by ID, whose messages like msg are
+// routed to topic.
+func appForTopic(b *Bridge, topic string, msg GotifyMessage) (GotifyApp, bool) {
+	rules := b.cfg.Rules().active
+	for _, app := range b.store.All() {
+		msg.AppID = app.ID
+		if t, _ := resolveWith(b.cfg, b.store, msg, rules); t == topic {
+			return app, true
+		}
+	}
+	return GotifyApp{}, false
+}
+
+func handleAdminConfig(b *Bridge) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, configFields(b.cfg))
+	}
+}
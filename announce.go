@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AnnouncedTopics remembers which split topics were introduced with an
+// announcement message (TOPIC_ANNOUNCE), persisted in ANNOUNCED_TOPICS_DB so
+// a restart does not announce them again.
+type AnnouncedTopics struct {
+	mu     sync.Mutex
+	path   string
+	topics map[string]time.Time // topic -> when it was announced
+}
+
+func NewAnnouncedTopics(path string) *AnnouncedTopics {
+	a := &AnnouncedTopics{path: path, topics: make(map[string]time.Time)}
+	f, err := os.Open(path)
+	if err == nil {
+		defer f.Close()
+		if err := json.NewDecoder(f).Decode(&a.topics); err != nil {
+			log.Printf("[ANNOUNCE ERROR] could not load %s: %v", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		log.Printf("[ANNOUNCE ERROR] could not open %s: %v", path, err)
+	}
+	return a
+}
+
+// claim marks topic as announced and reports whether it was not yet, so
+// concurrent workers announce a topic only once. A failed announcement is
+// handed back with release.
+func (a *AnnouncedTopics) claim(topic string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.topics[topic]; ok {
+		return false
+	}
+	a.topics[topic] = time.Now()
+	return true
+}
+
+func (a *AnnouncedTopics) release(topic string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.topics, topic)
+}
+
+func (a *AnnouncedTopics) save() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return writeFileAtomic(a.path, func(f *os.File) error {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return enc.Encode(a.topics)
+	})
+}
+
+// All returns the announced topics, sorted.
+func (a *AnnouncedTopics) All() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make([]string, 0, len(a.topics))
+	for t := range a.topics {
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// announceTopic publishes a one-time message to a split topic the first
+// time it is used, telling whoever subscribes what it carries and how to
+// subscribe. The default and admin topics and reserved topics are never
+// announced, and nothing is announced in observer mode or by one-shot commands.
+func announceTopic(ctx context.Context, b *Bridge, topic string, app GotifyApp) {
+	cfg := b.cfg
+	if !cfg.TopicAnnounce || cfg.ObserveOnly || b.announced == nil ||
+		topic == cfg.NtfyTopic || topic == cfg.NtfyAdminTopic ||
+		b.reserved.Reserved(topic) || !b.announced.claim(topic) {
+		return
+	}
+	err := publishNtfy(ctx, cfg, topicAnnouncement(cfg, topic, app))
+	if err != nil {
+		b.announced.release(topic)
+		if isForbidden(err) {
+			// Most likely reserved, which publishTopic is about to find out
+			dbg(cfg, "[ANNOUNCE] topic %s refused: %v", topic, err)
+			return
+		}
+		log.Printf("[ANNOUNCE ERROR] failed to announce topic %s: %v", topic, err)
+		return
+	}
+	log.Printf("[ANNOUNCE] Announced topic %s for app %s (ID=%d)", topic, app.Name, app.ID)
+	if err := b.announced.save(); err != nil {
+		log.Printf("[ANNOUNCE ERROR] could not save %s: %v", cfg.AnnouncedTopicsDB, err)
+	}
+}
+
+func topicAnnouncement(cfg *Config, topic string, app GotifyApp) ntfyPublish {
+	name := app.Name
+	if name == "" {
+		name = fmt.Sprintf("app %d", app.ID)
+	}
+	server := cfg.InstanceName
+	if server == "" {
+		if u, err := url.Parse(cfg.ActiveGotifyURL()); err == nil {
+			server = u.Hostname()
+		}
+	}
+	base := strings.TrimRight(cfg.TopicAnnounceURL, "/")
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "This topic carries notifications for **%s**", name)
+	if server != "" {
+		fmt.Fprintf(&sb, " from the Gotify server **%s**", server)
+	}
+	sb.WriteString(".\n\n")
+	if app.Description != "" {
+		fmt.Fprintf(&sb, "> %s\n\n", app.Description)
+	}
+	fmt.Fprintf(&sb, "To receive them, add a subscription to `%s` in the ntfy app on server `%s`, "+
+		"or open %s/%s in a browser.", topic, base, base, url.PathEscape(topic))
+
+	return ntfyPublish{
+		Topic:    topic,
+		Title:    "About this topic: " + name,
+		Body:     sb.String(),
+		Priority: 3,
+		Tags:     []string{"loudspeaker"},
+		Markdown: true,
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// syncedApp identifies an app in a syncSummary, leaving out its token.
+type syncedApp struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+func newSyncedApp(a GotifyApp) syncedApp {
+	return syncedApp{ID: a.ID, Name: a.Name, Description: a.Description}
+}
+
+// syncSummary lists what one app sync found.
+type syncSummary struct {
+	New     []syncedApp `json:"new"`
+	Changed []syncedApp `json:"changed"` // description changed
+	Removed []syncedApp `json:"removed"`
+}
+
+func newSyncSummary() syncSummary {
+	return syncSummary{New: []syncedApp{}, Changed: []syncedApp{}, Removed: []syncedApp{}}
+}
+
+func (s syncSummary) String() string {
+	return fmt.Sprintf("%d new, %d changed, %d removed", len(s.New), len(s.Changed), len(s.Removed))
+}
+
+// AppSync compares Gotify's applications with the apps db and announces new
+// and changed apps on the admin topic. Runs are serialized, so the periodic
+// sync loop and on-demand syncs can share it.
+type AppSync struct {
+	mu    sync.Mutex
+	cfg   *Config
+	store *AppStore
+	stats *Stats
+	known map[int64]GotifyApp
+	// The first run seeds known with the apps Gotify has at startup,
+	// without announcing them
+	seeded bool
+}
+
+func NewAppSync(cfg *Config, store *AppStore, stats *Stats) *AppSync {
+	known, err := loadKnownApps(cfg.AppsDBPath)
+	if err != nil {
+		log.Printf("[SYNC ERROR] could not load known apps db: %v", err)
+		known = make(map[int64]GotifyApp)
+	}
+	return &AppSync{cfg: cfg, store: store, stats: stats, known: known}
+}
+
+func (s *AppSync) seed(ctx context.Context) {
+	s.seeded = true
+	current, err := getApplications(ctx, s.cfg)
+	if err != nil {
+		log.Printf("[SYNC WARN] initial getApplications failed: %v", err)
+		return
+	}
+	for _, a := range current {
+		s.known[a.ID] = a
+	}
+	_ = saveKnownApps(s.cfg.AppsDBPath, s.known)
+	s.store.SetAll(current)
+}
+
+// Run loads the applications from Gotify and reconciles them with the apps db.
+func (s *AppSync) Run(ctx context.Context) (syncSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.seeded {
+		s.seed(ctx)
+	}
+	cfg, store, known := s.cfg, s.store, s.known
+	summary := newSyncSummary()
+
+	cur, err := getApplications(ctx, cfg)
+	if err != nil {
+		s.stats.RecordSyncError()
+		return summary, err
+	}
+	s.stats.RecordSync()
+
+	// Detect new or changed apps
+	seen := make(map[int64]bool, len(cur))
+	for _, a := range cur {
+		seen[a.ID] = true
+		old, ok := known[a.ID]
+		if !ok {
+			// New app detected
+			title := "New Gotify app detected"
+			body := fmt.Sprintf("Name: %s (ID=%d)\nDescription: %q", a.Name, a.ID, a.Description)
+
+			if err := sendNtfy(ctx, cfg, cfg.NtfyAdminTopic, title, body, 4); err != nil {
+				log.Printf("[SYNC ERROR] failed to notify about new app %s (ID=%d): %v", a.Name, a.ID, err)
+			} else {
+				log.Printf("[SYNC] Notified about new app: %s (ID=%d)", a.Name, a.ID)
+			}
+
+			// Add the new app to the store and known apps
+			store.Upsert(a)
+			known[a.ID] = a
+			summary.New = append(summary.New, newSyncedApp(a))
+		} else if old.Description != a.Description {
+			// Description changed
+			title := "Gotify app description updated"
+			body := fmt.Sprintf("App: %s (ID=%d)\nOld: %q\nNew: %q", a.Name, a.ID, old.Description, a.Description)
+			if err := sendNtfy(ctx, cfg, cfg.NtfyAdminTopic, title, body, 3); err != nil {
+				log.Printf("[SYNC ERROR] failed to notify about description change for %s (ID=%d): %v", a.Name, a.ID, err)
+			} else {
+				log.Printf("[SYNC] Notified description change for app %s (ID=%d)", a.Name, a.ID)
+			}
+
+			store.Upsert(a)
+			known[a.ID] = a
+			summary.Changed = append(summary.Changed, newSyncedApp(a))
+		}
+	}
+	for id, a := range known {
+		if !seen[id] {
+			log.Printf("[SYNC] App removed from Gotify: %s (ID=%d)", a.Name, a.ID)
+			store.Remove(id)
+			delete(known, id)
+			summary.Removed = append(summary.Removed, newSyncedApp(a))
+		}
+	}
+
+	if err := saveKnownApps(cfg.AppsDBPath, known); err != nil {
+		log.Printf("[SYNC ERROR] could not save known apps db: %v", err)
+	}
+
+	// Validate topics locally (no network)
+	for _, a := range cur {
+		topic := sanitizeTopic(a.Name)
+		if err := ensureTopic(cfg, topic); err != nil {
+			log.Printf("[SYNC ERROR] Could not validate topic %s: %v", topic, err)
+		} else {
+			dbg(cfg, "[SYNC] Topic ready: %s", topic)
+		}
+	}
+	return summary, nil
+}
+
+// reloadApps replaces the store's apps without the apps db or announcements,
+// for setups without NTFY_SPLIT_TOPICS where no sync loop runs.
+func reloadApps(ctx context.Context, cfg *Config, store *AppStore, stats *Stats) (syncSummary, error) {
+	summary := newSyncSummary()
+	apps, err := getApplications(ctx, cfg)
+	if err != nil {
+		stats.RecordSyncError()
+		return summary, err
+	}
+	stats.RecordSync()
+
+	seen := make(map[int64]bool, len(apps))
+	for _, a := range apps {
+		seen[a.ID] = true
+		old, ok := store.Get(a.ID)
+		if !ok {
+			summary.New = append(summary.New, newSyncedApp(a))
+		} else if old.Description != a.Description {
+			summary.Changed = append(summary.Changed, newSyncedApp(a))
+		}
+	}
+	for _, a := range store.All() {
+		if !seen[a.ID] {
+			store.Remove(a.ID)
+			summary.Removed = append(summary.Removed, newSyncedApp(a))
+		}
+	}
+	store.SetAll(apps)
+	return summary, nil
+}
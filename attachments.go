@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Attachment modes (ATTACHMENTS).
+const (
+	attachOff    = "off"
+	attachLink   = "link"   // let ntfy clients load the image from its URL
+	attachUpload = "upload" // download the image and upload it to ntfy
+)
+
+// Behaviors when an upload violates the size or type policy (ATTACHMENT_ON_LIMIT).
+const (
+	onLimitLink     = "link"
+	onLimitDrop     = "drop"
+	onLimitCompress = "compress" // downscale oversized images, then link if still too large
+)
+
+const (
+	// compressReadLimit caps downloads in compress mode, where files may
+	// exceed ATTACHMENT_MAX_BYTES before they are downscaled.
+	compressReadLimit = 32 << 20
+	// compressMaxDimension is used for oversized images when ATTACHMENT_MAX_DIMENSION is unset.
+	compressMaxDimension = 1280
+)
+
+// preferredExt overrides mime.ExtensionsByType, which sorts e.g. ".jfif" first.
+var preferredExt = map[string]string{"image/jpeg": ".jpg", "image/png": ".png", "image/gif": ".gif"}
+
+// ntfyAttachment is a file uploaded to ntfy as the message body.
+type ntfyAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// bigImageURL returns the image URL Gotify clients show with a message, if any.
+func bigImageURL(msg GotifyMessage) string {
+	n, ok := msg.Extras["client::notification"].(map[string]any)
+	if !ok {
+		return ""
+	}
+	s, _ := n["bigImageUrl"].(string)
+	return s
+}
+
+// mimeAllowed reports whether contentType matches one of the patterns, e.g. "image/*".
+func mimeAllowed(contentType string, patterns []string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, mt); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// errAttachmentPolicy is returned by downloadAttachment when the file is too
+// large or of a type that may not be uploaded.
+type errAttachmentPolicy struct{ reason string }
+
+func (e *errAttachmentPolicy) Error() string { return e.reason }
+
+func downloadAttachment(ctx context.Context, cfg *Config, rawURL string) (*ntfyAttachment, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cfg.GotifyHTTP().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+
+	readLimit := cfg.AttachmentMaxBytes
+	if cfg.AttachmentOnLimit == onLimitCompress {
+		readLimit = max(readLimit, compressReadLimit)
+	}
+	if resp.ContentLength > readLimit {
+		return nil, &errAttachmentPolicy{fmt.Sprintf("%d bytes exceeds ATTACHMENT_MAX_BYTES", resp.ContentLength)}
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, readLimit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > readLimit {
+		return nil, &errAttachmentPolicy{"size exceeds ATTACHMENT_MAX_BYTES"}
+	}
+
+	ct := resp.Header.Get("Content-Type")
+	if ct == "" || strings.HasPrefix(ct, "application/octet-stream") {
+		ct = http.DetectContentType(data)
+	}
+	if !mimeAllowed(ct, cfg.AttachmentTypes) {
+		return nil, &errAttachmentPolicy{fmt.Sprintf("type %s not in ATTACHMENT_TYPES", ct)}
+	}
+
+	name := "attachment"
+	if u, err := url.Parse(rawURL); err == nil && path.Base(u.Path) != "/" && path.Base(u.Path) != "." {
+		name = path.Base(u.Path)
+	}
+
+	oversized := int64(len(data)) > cfg.AttachmentMaxBytes
+	mt, _, _ := mime.ParseMediaType(ct)
+	if thumbnailTypes[mt] && (cfg.AttachmentMaxDimension > 0 || oversized && cfg.AttachmentOnLimit == onLimitCompress) {
+		dim := cfg.AttachmentMaxDimension
+		if dim == 0 {
+			dim = compressMaxDimension
+		}
+		small, smallType, err := thumbnail(data, dim, cfg.AttachmentJPEGQuality)
+		switch {
+		case err != nil:
+			log.Printf("[ATTACHMENT] Could not downscale %s, keeping original: %v", name, err)
+		case len(small) < len(data):
+			dbg(cfg, "[ATTACHMENT] Downscaled %s from %d to %d bytes", name, len(data), len(small))
+			if smallType != mt {
+				name = strings.TrimSuffix(name, path.Ext(name))
+			}
+			data, ct = small, smallType
+		}
+	}
+	if int64(len(data)) > cfg.AttachmentMaxBytes {
+		return nil, &errAttachmentPolicy{"size exceeds ATTACHMENT_MAX_BYTES"}
+	}
+
+	if path.Ext(name) == "" {
+		mt, _, _ = mime.ParseMediaType(ct)
+		if ext, ok := preferredExt[mt]; ok {
+			name += ext
+		} else if exts, _ := mime.ExtensionsByType(ct); len(exts) > 0 {
+			name += exts[0]
+		}
+	}
+	return &ntfyAttachment{Filename: name, ContentType: ct, Data: data}, nil
+}
+
+// attach adds the message's image to p according to the attachment policy.
+func attach(ctx context.Context, cfg *Config, p *ntfyPublish, msg GotifyMessage) {
+	imageURL := bigImageURL(msg)
+	if imageURL == "" || cfg.Attachments == attachOff {
+		return
+	}
+	if cfg.Attachments == attachLink {
+		p.Attach = imageURL
+		return
+	}
+
+	a, err := downloadAttachment(ctx, cfg, imageURL)
+	if err == nil {
+		p.Attachment = a
+		return
+	}
+	if _, ok := err.(*errAttachmentPolicy); ok && cfg.AttachmentOnLimit == onLimitDrop {
+		log.Printf("[ATTACHMENT] Dropping attachment of message id=%d: %v", msg.ID, err)
+		return
+	}
+	log.Printf("[ATTACHMENT] Linking instead of uploading attachment of message id=%d: %v", msg.ID, err)
+	p.Attach = imageURL
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditEntry is one line of the AUDIT_LOG file.
+type auditEntry struct {
+	Time   time.Time      `json:"time"`
+	Actor  string         `json:"actor"`
+	Action string         `json:"action"`
+	Params map[string]any `json:"params,omitempty"`
+}
+
+// AuditLog records admin actions such as snoozing apps or forcing a sync:
+// always to the log, as JSON lines to AUDIT_LOG if set, and optionally as a
+// notification on the admin topic.
+type AuditLog struct {
+	mu  sync.Mutex
+	cfg *Config
+}
+
+func NewAuditLog(cfg *Config) *AuditLog {
+	return &AuditLog{cfg: cfg}
+}
+
+func (a *AuditLog) Record(ctx context.Context, actor, action string, params map[string]any) {
+	cfg := a.cfg
+	e := auditEntry{Time: time.Now(), Actor: actor, Action: action, Params: params}
+	summary := formatAuditParams(params)
+	log.Printf("[AUDIT] %s by %s%s", action, actor, summary)
+
+	if cfg.AuditLogFile != "" {
+		if err := a.append(e); err != nil {
+			log.Printf("[AUDIT ERROR] could not write %s: %v", cfg.AuditLogFile, err)
+		}
+	}
+	if cfg.AuditNotify {
+		body := fmt.Sprintf("%s by %s at %s%s", action, actor, e.Time.Format("2006-01-02 15:04:05 MST"), summary)
+		if err := sendNtfy(ctx, cfg, cfg.NtfyAdminTopic, "Admin action: "+action, body, 2); err != nil {
+			log.Printf("[AUDIT ERROR] failed to notify %s: %v", cfg.NtfyAdminTopic, err)
+		}
+	}
+}
+
+func (a *AuditLog) append(e auditEntry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.OpenFile(a.cfg.AuditLogFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(e); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// formatAuditParams renders params as " (k=v, ...)" in key order.
+func formatAuditParams(params map[string]any) string {
+	if len(params) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%v", k, params[k])
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// requestActor identifies who made an HTTP request for the audit log.
+func requestActor(cfg *Config, r *http.Request) string {
+	addr, err := clientIP(r, cfg.HTTPTrustedProxies)
+	if err != nil {
+		return "http " + r.RemoteAddr
+	}
+	return "http " + addr.String()
+}
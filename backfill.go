@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// backfillPageSize is the limit asked of Gotify per GET /message page.
+	backfillPageSize = 100
+	// backfillSaveInterval is how often forwarded IDs are written to GOTIFY_STATE_DB.
+	backfillSaveInterval = 10 * time.Second
+)
+
+// Backfill remembers the highest message ID read from the stream, so that
+// after a reconnect the messages published in between can be fetched from
+// the REST API. IDs are only comparable within one Gotify server, so they
+// are kept per stream URL.
+//
+// The last ID forwarded per app is also persisted in GOTIFY_STATE_DB, so the
+// first connection after a restart backfills what was published while the
+// bridge was down.
+type Backfill struct {
+	mu     sync.Mutex
+	url    string
+	lastID int64
+
+	path  string
+	state backfillState
+	dirty bool
+}
+
+// backfillState is the content of GOTIFY_STATE_DB.
+type backfillState struct {
+	URL       string          `json:"url"`
+	Forwarded map[int64]int64 `json:"forwarded"` // app ID -> last message ID forwarded
+}
+
+func NewBackfill(path string) *Backfill {
+	f := &Backfill{path: path, state: backfillState{Forwarded: make(map[int64]int64)}}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if err := json.Unmarshal(data, &f.state); err != nil {
+			log.Printf("[BACKFILL ERROR] could not load %s: %v", path, err)
+		}
+		if f.state.Forwarded == nil {
+			f.state.Forwarded = make(map[int64]int64)
+		}
+	} else if !os.IsNotExist(err) {
+		log.Printf("[BACKFILL ERROR] could not open %s: %v", path, err)
+	}
+	return f
+}
+
+// Seen records a message read from the stream of url.
+func (f *Backfill) Seen(url string, id int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.url != url {
+		f.url, f.lastID = url, 0
+	}
+	f.lastID = max(f.lastID, id)
+}
+
+// Forwarded records a message from the stream of url delivered to ntfy.
+func (f *Backfill) Forwarded(url string, appID, id int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.state.URL != url {
+		f.state = backfillState{URL: url, Forwarded: make(map[int64]int64)}
+	}
+	if id > f.state.Forwarded[appID] {
+		f.state.Forwarded[appID] = id
+		f.dirty = true
+	}
+}
+
+// After returns the ID to backfill from for the stream of url, and false if
+// no message was read from it yet. Before the first message after a start,
+// that is the newest ID forwarded from url before the restart.
+func (f *Backfill) After(url string) (int64, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.url == url && f.lastID > 0 {
+		return f.lastID, true
+	}
+	if f.state.URL != url {
+		return 0, false
+	}
+	var after int64
+	for _, id := range f.state.Forwarded {
+		after = max(after, id)
+	}
+	return after, after > 0
+}
+
+// Save writes the forwarded IDs to GOTIFY_STATE_DB if they changed.
+func (f *Backfill) Save() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.dirty {
+		return nil
+	}
+	err := writeFileAtomic(f.path, func(file *os.File) error {
+		enc := json.NewEncoder(file)
+		enc.SetIndent("", "  ")
+		return enc.Encode(f.state)
+	})
+	if err == nil {
+		f.dirty = false
+	}
+	return err
+}
+
+// runBackfillState persists the forwarded IDs every backfillSaveInterval.
+func runBackfillState(b *Bridge) {
+	ticker := time.NewTicker(backfillSaveInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := b.backfill.Save(); err != nil {
+			log.Printf("[BACKFILL ERROR] could not save %s: %v", b.cfg.StateDBPath, err)
+		}
+	}
+}
+
+// gotifyMessagePage is a response of GET /message. Gotify pages from the
+// newest message backwards: paging.since is the ID to pass for the next,
+// older page, 0 on the last one.
+type gotifyMessagePage struct {
+	Messages []json.RawMessage `json:"messages"`
+	Paging   struct {
+		Since int64 `json:"since"`
+	} `json:"paging"`
+}
+
+// fetchMessagesAfter returns the raw messages with an ID above after,
+// oldest first. Beyond limit only the newest are returned, and skipped
+// reports how many older ones were left out.
+func fetchMessagesAfter(ctx context.Context, cfg *Config, after int64, limit int) (msgs []json.RawMessage, skipped int, err error) {
+	var since int64
+	for {
+		page, err := fetchMessagePage(ctx, cfg, since)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, raw := range page.Messages {
+			var m struct {
+				ID int64 `json:"id"`
+			}
+			if err := json.Unmarshal(raw, &m); err != nil {
+				return nil, 0, err
+			}
+			if m.ID <= after {
+				slices.Reverse(msgs)
+				return msgs, skipped, nil
+			}
+			if len(msgs) < limit {
+				msgs = append(msgs, raw)
+			} else {
+				skipped++
+			}
+		}
+		if page.Paging.Since <= 0 || len(page.Messages) == 0 || (since > 0 && page.Paging.Since >= since) {
+			slices.Reverse(msgs)
+			return msgs, skipped, nil
+		}
+		since = page.Paging.Since
+	}
+}
+
+func fetchMessagePage(ctx context.Context, cfg *Config, since int64) (*gotifyMessagePage, error) {
+	messagesURL, err := gotifyAPIURL(cfg, "/message")
+	if err != nil {
+		return nil, err
+	}
+	messagesURL += "?limit=" + strconv.Itoa(backfillPageSize)
+	if since > 0 {
+		messagesURL += "&since=" + strconv.FormatInt(since, 10)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", messagesURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Gotify-Key", cfg.GotifyToken)
+
+	resp, err := cfg.GotifyHTTP().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Gotify /message failed: %s", resp.Status)
+	}
+	var page gotifyMessagePage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// backfill passes the messages published on url since the last one read
+// from its stream to handle, oldest first, up to GOTIFY_BACKFILL_MAX. It
+// runs right after a (re)connect, before the stream is read; messages that
+// arrive on both ways are caught by the dedup guard.
+func backfill(ctx context.Context, b *Bridge, url string, handle func(GotifyMessage, []byte)) {
+	cfg := b.cfg
+	after, ok := b.backfill.After(url)
+	if !ok || cfg.BackfillMax <= 0 {
+		return
+	}
+	msgs, skipped, err := fetchMessagesAfter(ctx, cfg, after, cfg.BackfillMax)
+	if err != nil {
+		log.Printf("[BACKFILL ERROR] could not fetch messages missed since ID %d: %v", after, err)
+		return
+	}
+	if skipped > 0 {
+		log.Printf("[BACKFILL WARN] %d messages missed since ID %d, forwarding only the newest %d (GOTIFY_BACKFILL_MAX)",
+			len(msgs)+skipped, after, len(msgs))
+	}
+	if len(msgs) == 0 {
+		dbg(cfg, "[BACKFILL] No messages missed since ID %d", after)
+		return
+	}
+	log.Printf("[BACKFILL] Forwarding %d messages published while disconnected", len(msgs))
+	for _, raw := range msgs {
+		var msg GotifyMessage
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			log.Printf("[BACKFILL ERROR] skipping unreadable message: %v", err)
+			continue
+		}
+		handle(msg, raw)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// streamWorkers is the number of goroutines forwarding stream messages to ntfy.
+const streamWorkers = 4
+
+// errQueueFull marks messages dropped because the stream queue was full.
+var errQueueFull = errors.New("stream queue full, message dropped")
+
+// workerStats counts the outcomes of one stream worker across connections.
+type workerStats struct {
+	forwarded, failed, buffered atomic.Int64
+	current                     atomic.Pointer[inFlight] // nil while idle
+}
+
+// inFlight is the message a worker is forwarding right now, see GET /queue.
+type inFlight struct {
+	MessageID int64
+	AppID     int64
+	Started   time.Time
+}
+
+// Bridge bundles the runtime state shared by the stream reader, the workers
+// and the background loops.
+type Bridge struct {
+	ctx     context.Context // cancelled on shutdown, bounds alerts from background loops
+	cfg     *Config
+	store   *AppStore
+	stats   *Stats
+	batcher *Batcher
+	buffer  *OfflineBuffer
+	held    *HeldMessages // outside their delivery window
+	dedup   *DedupGuard
+	sent    *SentIDs // DEDUP_SENT, checked before publishing
+	volume  *VolumeTracker
+	mutes   *MuteStore
+	icons   *IconCache
+
+	reserved *ReservedTopics // split topics ntfy refused, see publishTopic
+	limited  *TopicPauses    // topics ntfy answered 429 for, see publishTopic
+	tracer   *Tracer         // nil unless OTLP tracing is configured
+	audit    *AuditLog
+	recent   *RecentForwards // latest forward results for the web UI
+
+	announced *AnnouncedTopics // split topics introduced via TOPIC_ANNOUNCE, nil on one-shot bridges
+	messages  *MessageLog      // MESSAGE_LOG
+	failures  *FailureHook     // nil without FAILURE_WEBHOOK_URL
+	wal       *WAL             // nil without WAL_FILE
+
+	deadLetters *DeadLetters // nil without NTFY_DEAD_LETTER_TOPIC
+
+	watchdog *Watchdog
+	outage   *GotifyMonitor
+	ntfyDown *NtfyMonitor
+	unknown  *UnknownApps // NTFY_UNKNOWN_APPS=reject
+	deadman  *DeadMan     // EXPECT_MESSAGES
+	backfill *Backfill    // last message IDs read and forwarded, see backfill.go
+	appSync  *AppSync     // nil without NTFY_SPLIT_TOPICS
+	lastRead atomic.Int64 // unix nanos of the last message or ping from Gotify, see readiness
+	paused   atomic.Bool  // set via POST /pause, messages wait in the offline buffer
+
+	// Debugging state, see dumpState
+	queue            atomic.Pointer[chan GotifyMessage] // stream queue while connected
+	criticalQueue    atomic.Pointer[chan GotifyMessage] // queue of the critical worker
+	workers          [streamWorkers + 1]workerStats     // the last one is the critical worker
+	lastForwardedID  atomic.Int64
+	reconnectAttempt atomic.Int32
+
+	recentDrops atomic.Int64 // dropped since the last alert, see runDropAlerts
+}
+
+func NewBridge(ctx context.Context, cfg *Config, store *AppStore, stats *Stats) *Bridge {
+	return newBridge(ctx, cfg, store, stats, false)
+}
+
+// NewOneShotBridge returns a bridge for commands such as send that run next
+// to the daemon: its offline buffer lives in memory only, so it neither loads
+// nor rewrites OFFLINE_BUFFER_FILE, and it has no WAL. It announces no topics
+// either, leaving ANNOUNCED_TOPICS_DB and the announcements to the daemon.
+func NewOneShotBridge(ctx context.Context, cfg *Config, store *AppStore, stats *Stats) *Bridge {
+	return newBridge(ctx, cfg, store, stats, true)
+}
+
+func newBridge(ctx context.Context, cfg *Config, store *AppStore, stats *Stats, oneShot bool) *Bridge {
+	bufferFile := cfg.OfflineBufferFile
+	if oneShot {
+		bufferFile = ""
+	}
+	b := &Bridge{
+		ctx:     ctx,
+		cfg:     cfg,
+		store:   store,
+		stats:   stats,
+		batcher: NewBatcher(),
+		buffer:  NewOfflineBuffer(cfg.OfflineBufferSize, bufferFile),
+		held:    NewHeldMessages(cfg.OfflineBufferSize),
+		dedup:   NewDedupGuard(cfg.DedupWindow),
+		sent:    NewSentIDs(cfg.DedupSent),
+		volume:  NewVolumeTracker(cfg.ReportDBPath),
+		mutes:   NewMuteStore(),
+		icons:   NewIconCache(),
+
+		reserved: NewReservedTopics(),
+		limited:  NewTopicPauses(),
+		tracer:   NewTracer(cfg),
+		audit:    NewAuditLog(cfg),
+		recent:   NewRecentForwards(),
+
+		messages: NewMessageLog(cfg),
+		failures: NewFailureHook(ctx, cfg),
+
+		watchdog: NewWatchdog(),
+		outage:   NewGotifyMonitor(),
+		ntfyDown: NewNtfyMonitor(cfg.NtfyDownAttempts, cfg.NtfyCircuitCooldown),
+		unknown:  NewUnknownApps(),
+		deadman:  NewDeadMan(),
+		backfill: NewBackfill(cfg.StateDBPath),
+	}
+	if !oneShot {
+		b.announced = NewAnnouncedTopics(cfg.AnnouncedTopicsDB)
+	}
+	b.deadLetters = NewDeadLetters(b)
+	b.buffer.onEvict = b.evicted
+	b.held.onEvict = b.evicted
+	return b
+}
+
+// evicted reports a message dropped from the full offline buffer or held
+// messages like one that expired there, and completes it so the WAL does not
+// replay it on the next start.
+func (b *Bridge) evicted(m bufferedMsg) {
+	b.messages.recordBuffered(b, m, "evicted", nil)
+	b.failures.fireBuffered(b, m, "evicted", nil)
+	b.deadLetters.sendBuffered(b, m, "evicted", nil)
+	b.wal.doneBuffered(m)
+}
+
+// SyncNow refreshes the apps right away instead of waiting for
+// NTFY_SYNC_INTERVAL, like one round of the sync loop. Without
+// NTFY_SPLIT_TOPICS there is no sync loop, so the app list is reloaded directly.
+func (b *Bridge) SyncNow(ctx context.Context) (syncSummary, error) {
+	var summary syncSummary
+	var err error
+	if b.appSync != nil {
+		summary, err = b.appSync.Run(ctx)
+	} else {
+		summary, err = reloadApps(ctx, b.cfg, b.store, b.stats)
+	}
+	if err == nil {
+		log.Printf("[SYNC] Forced sync: %s", summary)
+	}
+	return summary, err
+}
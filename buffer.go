@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// errBuffered is returned by forwardToNtfy when a message was queued in the
+// offline buffer instead of being published right away.
+var errBuffered = errors.New("buffered until ntfy is reachable")
+
+type bufferedMsg struct {
+	Seq      uint64
+	AppID    int64
+	Received time.Time // carries a monotonic reading, see annotate
+	Publish  ntfyPublish
+}
+
+// OfflineBuffer holds messages that could not be published because ntfy was
+// unreachable. Entries are sequenced and delivered strictly in order, except
+// that entries for a topic paused after a 429 wait without holding up other
+// topics. With OFFLINE_BUFFER_FILE the buffer is kept on disk and survives
+// restarts.
+type OfflineBuffer struct {
+	mu      sync.Mutex
+	max     int
+	seq     uint64 // messages ever added
+	items   []bufferedMsg
+	kick    chan struct{}
+	retryAt time.Time // backing off until then, see runOfflineBuffer
+
+	evicted uint64 // dropped because the buffer was full
+
+	// onEvict is called with each evicted entry, outside the lock
+	onEvict func(bufferedMsg)
+
+	path   string // OFFLINE_BUFFER_FILE
+	saveMu sync.Mutex
+}
+
+func NewOfflineBuffer(max int, path string) *OfflineBuffer {
+	o := &OfflineBuffer{max: max, kick: make(chan struct{}, 1), path: path}
+	if path != "" {
+		o.load()
+	}
+	return o
+}
+
+func (o *OfflineBuffer) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.items)
+}
+
+// Totals returns how many messages were ever buffered and how many of them
+// were evicted from a full buffer.
+func (o *OfflineBuffer) Totals() (added, evicted uint64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.seq, o.evicted
+}
+
+// Add queues p, evicting the oldest entry when the buffer is full.
+func (o *OfflineBuffer) Add(appID int64, p ntfyPublish) {
+	o.AddReceived(appID, p, time.Now())
+}
+
+// AddReceived queues p like Add for a message that already waited elsewhere
+// since received, which TTLs and the delay note are measured from.
+func (o *OfflineBuffer) AddReceived(appID int64, p ntfyPublish, received time.Time) {
+	o.mu.Lock()
+	o.seq++
+	o.items = append(o.items, bufferedMsg{Seq: o.seq, AppID: appID, Received: received, Publish: p})
+	var dropped bufferedMsg
+	evict := o.max > 0 && len(o.items) > o.max
+	if evict {
+		dropped = o.items[0]
+		o.items = o.items[1:]
+		o.evicted++
+		log.Printf("[OFFLINE WARN] buffer full, dropping oldest message seq=%d topic=%s", dropped.Seq, dropped.Publish.Topic)
+	}
+	backingOff := time.Now().Before(o.retryAt)
+	o.mu.Unlock()
+	if evict && o.onEvict != nil {
+		o.onEvict(dropped)
+	}
+	o.save()
+	if !backingOff {
+		o.Kick()
+	}
+}
+
+// Kick makes runOfflineBuffer try a flush now.
+func (o *OfflineBuffer) Kick() {
+	select {
+	case o.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Oldest returns when the oldest buffered message was received.
+func (o *OfflineBuffer) Oldest() (time.Time, bool) {
+	m, ok := o.peek()
+	return m.Received, ok
+}
+
+func (o *OfflineBuffer) peek() (bufferedMsg, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.items) == 0 {
+		return bufferedMsg{}, false
+	}
+	return o.items[0], true
+}
+
+// next returns the oldest entry whose topic is neither in skip nor rate
+// limited. Rate limited topics are added to skip, so their later entries
+// wait behind them and the order per topic is kept.
+func (o *OfflineBuffer) next(limited *TopicPauses, skip map[string]bool) (bufferedMsg, bool) {
+	paused := limited.All()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, m := range o.items {
+		topic := m.Publish.Topic
+		if _, ok := paused[topic]; ok {
+			skip[topic] = true
+		}
+		if !skip[topic] {
+			return m, true
+		}
+	}
+	return bufferedMsg{}, false
+}
+
+// Blocks reports whether a new message for topic has to queue behind the
+// buffer: it holds messages for the same topic, or messages waiting for ntfy
+// to be reachable. Entries that only wait for another topic's rate limit
+// pause do not hold it up.
+func (o *OfflineBuffer) Blocks(topic string, limited *TopicPauses) bool {
+	paused := limited.All()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, m := range o.items {
+		if _, ok := paused[m.Publish.Topic]; m.Publish.Topic == topic || !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// remove drops the entry seq if it is still buffered (it may have been evicted meanwhile).
+func (o *OfflineBuffer) remove(seq uint64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i, m := range o.items {
+		if m.Seq == seq {
+			o.items = append(o.items[:i], o.items[i+1:]...)
+			return
+		}
+	}
+}
+
+// annotate appends the original receive time to a message delivered late.
+// The delay is measured on the monotonic clock and the receive time is derived
+// from the current wall clock, so a host clock that was wrong while offline
+// (e.g. a router without RTC that syncs NTP only once the uplink is back)
+// does not distort the timestamp.
+func annotate(cfg *Config, m bufferedMsg) ntfyPublish {
+	p := m.Publish
+	delay := time.Since(m.Received)
+	if delay < cfg.OfflineAnnotateAfter {
+		return p
+	}
+	original := time.Now().Add(-delay)
+	note := fmt.Sprintf("(originally received %s, delayed %s)", original.Format("2006-01-02 15:04:05 MST"), delay.Round(time.Second))
+	if p.Body == "" {
+		p.Body = note
+	} else {
+		p.Body += "\n\n" + note
+	}
+	return p
+}
+
+// flush delivers buffered messages in order until the buffer is empty or
+// ntfy turns out to be unreachable again, which it reports. Messages for
+// rate limited topics stay buffered until their pause is over. Nothing is
+// delivered while forwarding is paused.
+func (o *OfflineBuffer) flush(b *Bridge) (offline bool) {
+	changed := false
+	defer func() {
+		if changed {
+			o.save()
+		}
+	}()
+	skip := make(map[string]bool)
+	for !b.paused.Load() {
+		m, ok := o.next(b.limited, skip)
+		if !ok {
+			return false
+		}
+		if ttl := m.Publish.TTL; ttl > 0 && time.Since(m.Received) > ttl {
+			o.remove(m.Seq)
+			changed = true
+			log.Printf("[OFFLINE] dropping expired message seq=%d topic=%s, not delivered within %s", m.Seq, m.Publish.Topic, formatDuration(ttl))
+			b.stats.RecordExpired()
+			b.messages.recordBuffered(b, m, "expired", nil)
+			b.failures.fireBuffered(b, m, "expired", nil)
+			b.deadLetters.sendBuffered(b, m, "expired", nil)
+			b.wal.doneBuffered(m)
+			continue
+		}
+		// Not bound to the shutdown signal: flushOnExit still delivers through here
+		err := publishTopic(context.Background(), b, annotate(b.cfg, m))
+		if errors.Is(err, errRateLimited) {
+			// Later messages for this topic wait too, other topics go on
+			dbg(b.cfg, "[OFFLINE] Holding messages for rate limited topic %s: %v", m.Publish.Topic, err)
+			skip[m.Publish.Topic] = true
+			continue
+		}
+		if isOffline(err) {
+			dbg(b.cfg, "[OFFLINE] ntfy still unreachable, %d messages buffered: %v", o.Len(), err)
+			return true
+		}
+		o.remove(m.Seq)
+		changed = true
+		if err != nil {
+			log.Printf("[OFFLINE ERROR] dropping buffered message seq=%d: %v", m.Seq, err)
+			b.stats.RecordForwardError(m.AppID)
+			b.messages.recordBuffered(b, m, "failed", err)
+			b.failures.fireBuffered(b, m, "failed", err)
+			b.deadLetters.sendBuffered(b, m, "failed", err)
+			b.wal.doneBuffered(m)
+			continue
+		}
+		dbg(b.cfg, "[OFFLINE] Delivered buffered message seq=%d to %s", m.Seq, m.Publish.Topic)
+		b.stats.RecordForward(m.AppID)
+		b.messages.recordBuffered(b, m, "delivered", nil)
+		b.wal.doneBuffered(m)
+	}
+	return false
+}
+
+// runOfflineBuffer retries the buffer every OFFLINE_RETRY_INTERVAL, backing
+// off while ntfy stays unreachable (see retryDelay). A Kick retries right
+// away, e.g. on resume.
+func runOfflineBuffer(b *Bridge) {
+	timer := time.NewTimer(b.cfg.OfflineRetryInterval)
+	defer timer.Stop()
+	attempts := 0
+	for {
+		select {
+		case <-timer.C:
+		case <-b.buffer.kick:
+		}
+		if b.buffer.flush(b) {
+			attempts++
+		} else {
+			attempts = 0
+		}
+		delay := b.cfg.OfflineRetryInterval
+		if attempts > 0 {
+			delay = retryDelay(b.cfg, attempts)
+			dbg(b.cfg, "[OFFLINE] next retry in %s", delay)
+		}
+		b.buffer.mu.Lock()
+		b.buffer.retryAt = time.Now().Add(delay)
+		b.buffer.mu.Unlock()
+		timer.Reset(delay)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func bufferTopics(o *OfflineBuffer) []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var topics []string
+	for _, m := range o.items {
+		topics = append(topics, m.Publish.Topic+":"+m.Publish.Title)
+	}
+	return topics
+}
+
+func TestOfflineBufferPerTopicOrder(t *testing.T) {
+	o := NewOfflineBuffer(0, "")
+	for _, p := range []ntfyPublish{
+		{Topic: "a", Title: "1"},
+		{Topic: "b", Title: "1"},
+		{Topic: "a", Title: "2"},
+		{Topic: "c", Title: "1"},
+		{Topic: "b", Title: "2"},
+	} {
+		o.Add(1, p)
+	}
+	limited := NewTopicPauses()
+	limited.Pause("a", time.Minute)
+
+	// Flushing skips the paused topic and everything queued behind it
+	var got []string
+	skip := make(map[string]bool)
+	for {
+		m, ok := o.next(limited, skip)
+		if !ok {
+			break
+		}
+		got = append(got, m.Publish.Topic+":"+m.Publish.Title)
+		o.remove(m.Seq)
+	}
+	want := []string{"b:1", "c:1", "b:2"}
+	if len(got) != len(want) {
+		t.Fatalf("flushed %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("flushed %v, want %v", got, want)
+		}
+	}
+	if left := bufferTopics(o); len(left) != 2 || left[0] != "a:1" || left[1] != "a:2" {
+		t.Errorf("left %v, want [a:1 a:2]", left)
+	}
+
+	// A failed entry holds back its topic for the rest of the flush
+	skip = map[string]bool{"a": true}
+	if m, ok := o.next(NewTopicPauses(), skip); ok {
+		t.Errorf("got %s:%s from a skipped topic", m.Publish.Topic, m.Publish.Title)
+	}
+}
+
+func TestOfflineBufferBlocks(t *testing.T) {
+	o := NewOfflineBuffer(0, "")
+	limited := NewTopicPauses()
+	if o.Blocks("a", limited) {
+		t.Error("empty buffer blocks")
+	}
+	o.Add(1, ntfyPublish{Topic: "a"})
+	limited.Pause("a", time.Minute)
+	if !o.Blocks("a", limited) {
+		t.Error("a new message overtook a buffered one for its topic")
+	}
+	if o.Blocks("b", limited) {
+		t.Error("another topic's rate limit pause held up b")
+	}
+	o.Add(1, ntfyPublish{Topic: "c"}) // waits for ntfy, not for a pause
+	if !o.Blocks("b", limited) {
+		t.Error("b overtook a message waiting for ntfy")
+	}
+}
+
+func TestOfflineBufferEvictsOldest(t *testing.T) {
+	o := NewOfflineBuffer(2, "")
+	var evicted []string
+	o.onEvict = func(m bufferedMsg) { evicted = append(evicted, m.Publish.Title) }
+	for _, title := range []string{"1", "2", "3", "4"} {
+		o.Add(1, ntfyPublish{Topic: "a", Title: title})
+	}
+	if len(evicted) != 2 || evicted[0] != "1" || evicted[1] != "2" {
+		t.Errorf("evicted %v, want [1 2]", evicted)
+	}
+	if left := bufferTopics(o); len(left) != 2 || left[0] != "a:3" || left[1] != "a:4" {
+		t.Errorf("left %v, want [a:3 a:4]", left)
+	}
+	if added, ev := o.Totals(); added != 4 || ev != 2 {
+		t.Errorf("Totals() = %d, %d, want 4, 2", added, ev)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+)
+
+// bufferedEntry is how a bufferedMsg is kept in OFFLINE_BUFFER_FILE. The
+// Gotify message travels along so the message log, the failure webhook and
+// the WAL still see it after a restart.
+type bufferedEntry struct {
+	bufferedMsg
+	Message   *GotifyMessage  `json:"message,omitempty"`
+	RawExtras json.RawMessage `json:"raw_extras,omitempty"`
+	WALSeq    uint64          `json:"wal_seq,omitempty"`
+}
+
+// load restores the buffer from path, left by a previous run.
+func (o *OfflineBuffer) load() {
+	f, err := os.Open(o.path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		log.Printf("[OFFLINE ERROR] could not open %s: %v", o.path, err)
+		return
+	}
+	defer f.Close()
+	var entries []bufferedEntry
+	if err := json.NewDecoder(f).Decode(&entries); err != nil {
+		log.Printf("[OFFLINE ERROR] could not load %s: %v", o.path, err)
+		return
+	}
+	for _, e := range entries {
+		m := e.bufferedMsg
+		if e.Message != nil {
+			msg := *e.Message
+			msg.rawExtras, msg.walSeq, msg.received = e.RawExtras, e.WALSeq, m.Received
+			m.Publish.msg = &msg
+		}
+		o.seq++
+		m.Seq = o.seq
+		o.items = append(o.items, m)
+	}
+	if len(o.items) > 0 {
+		log.Printf("[OFFLINE] Restored %d buffered messages from %s, oldest from %s",
+			len(o.items), o.path, o.items[0].Received.Format("2006-01-02 15:04:05"))
+	}
+}
+
+// save writes the buffer to OFFLINE_BUFFER_FILE, if set. Saves are
+// serialized so an older snapshot never overwrites a newer one.
+func (o *OfflineBuffer) save() {
+	if o.path == "" {
+		return
+	}
+	o.saveMu.Lock()
+	defer o.saveMu.Unlock()
+
+	o.mu.Lock()
+	entries := make([]bufferedEntry, len(o.items))
+	for i, m := range o.items {
+		entries[i].bufferedMsg = m
+		if msg := m.Publish.msg; msg != nil {
+			entries[i].Message, entries[i].RawExtras, entries[i].WALSeq = msg, msg.rawExtras, msg.walSeq
+		}
+	}
+	o.mu.Unlock()
+
+	err := writeFileAtomic(o.path, func(f *os.File) error {
+		return json.NewEncoder(f).Encode(entries)
+	})
+	if err != nil {
+		log.Printf("[OFFLINE ERROR] could not save %s: %v", o.path, err)
+	}
+}
+
+// buffered reports whether the message with WAL record seq waits in the
+// buffer, restored from OFFLINE_BUFFER_FILE; the WAL must not replay it.
+func (o *OfflineBuffer) buffered(walSeq uint64) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, m := range o.items {
+		if m.Publish.msg != nil && m.Publish.msg.walSeq == walSeq {
+			return true
+		}
+	}
+	return false
+}
+
+// retryDelay is how long runOfflineBuffer waits after attempts consecutive
+// flushes found ntfy unreachable: OFFLINE_RETRY_INTERVAL, doubled per
+// failed attempt up to OFFLINE_RETRY_MAX.
+func retryDelay(cfg *Config, attempts int) time.Duration {
+	d := cfg.OfflineRetryInterval
+	for i := 1; i < attempts && d < cfg.OfflineRetryMax; i++ {
+		d *= 2
+	}
+	return min(d, max(cfg.OfflineRetryMax, cfg.OfflineRetryInterval))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOfflineBufferReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "buffer.json")
+	received := time.Date(2025, 8, 20, 9, 30, 0, 0, time.UTC)
+	msg := &GotifyMessage{
+		ID: 42, AppID: 3, Title: "Disk", Message: "full", Priority: 8,
+		rawExtras: json.RawMessage(`{"client::display":{"contentType":"text/markdown"}}`),
+		received:  received,
+		walSeq:    7,
+	}
+
+	o := NewOfflineBuffer(0, path)
+	o.AddReceived(3, ntfyPublish{Topic: "infra", Title: "Disk", Body: "full", Priority: 5, msg: msg}, received)
+	o.Add(4, ntfyPublish{Topic: "home", Title: "Door"}) // no Gotify message, e.g. a digest
+
+	o = NewOfflineBuffer(0, path)
+	if n := o.Len(); n != 2 {
+		t.Fatalf("restored %d entries, want 2", n)
+	}
+	m, _ := o.peek()
+	if m.AppID != 3 || m.Publish.Topic != "infra" || m.Publish.Priority != 5 || !m.Received.Equal(received) {
+		t.Errorf("restored entry lost fields: %+v", m)
+	}
+	r := m.Publish.msg
+	if r == nil {
+		t.Fatal("restored entry lost its Gotify message")
+	}
+	if r.ID != 42 || r.walSeq != 7 || !r.received.Equal(received) || string(r.rawExtras) != string(msg.rawExtras) {
+		t.Errorf("restored message lost fields: %+v", r)
+	}
+	if !o.buffered(7) || o.buffered(8) {
+		t.Error("buffered() does not match the restored WAL seq")
+	}
+
+	// Restored entries get fresh seqs after the ones from the file
+	o.Add(1, ntfyPublish{Topic: "infra"})
+	o.mu.Lock()
+	seqs := []uint64{o.items[0].Seq, o.items[1].Seq, o.items[2].Seq}
+	o.mu.Unlock()
+	if seqs[0] >= seqs[1] || seqs[1] >= seqs[2] {
+		t.Errorf("seqs %v not increasing", seqs)
+	}
+	o.remove(seqs[0])
+	o.save()
+	if n := NewOfflineBuffer(0, path).Len(); n != 2 {
+		t.Errorf("restored %d entries after remove, want 2", n)
+	}
+}
+
+func TestOfflineBufferMissingFile(t *testing.T) {
+	o := NewOfflineBuffer(0, filepath.Join(t.TempDir(), "none.json"))
+	if n := o.Len(); n != 0 {
+		t.Errorf("Len() = %d, want 0", n)
+	}
+}
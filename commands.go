@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+// runCommand dispatches CLI subcommands and returns the process exit code.
+func runCommand(ctx context.Context, cfg *Config, args []string) int {
+	switch args[0] {
+	case "send":
+		return runSend(ctx, cfg, args[1:])
+	case "apps":
+		return runApps(ctx, cfg)
+	case "config":
+		return runConfig(cfg, args[1:])
+	case "preflight":
+		if !printPreflight(os.Stdout, runPreflight(ctx, cfg)) {
+			return 1
+		}
+		return 0
+	case "service":
+		return runService(cfg, args[1:])
+	case "queue":
+		return runQueue(cfg, args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q (available: send, apps, config, preflight, doctor, service, queue)\n", args[0])
+		return 2
+	}
+}
+
+// runSend publishes a manual test message through the same topic resolution,
+// priority mapping and formatting path as live Gotify messages.
+func runSend(ctx context.Context, cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	title := fs.String("title", "Test message", "message title")
+	message := fs.String("message", "Test message from gotify-to-ntfy-push", "message body")
+	priority := fs.Int("priority", 0, "Gotify priority 0-10 (0 follows PRIORITY_ZERO)")
+	appID := fs.Int64("app-id", 0, "Gotify application ID used for topic resolution")
+	_ = fs.Parse(args)
+
+	apps, err := getApplications(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not load applications, app topics fall back to %s: %v\n", cfg.NtfyTopic, err)
+	}
+	store := NewAppStore(apps)
+	b := NewOneShotBridge(ctx, cfg, store, NewStats(NewSLATracker(cfg.SLADBPath)))
+
+	msg := GotifyMessage{AppID: *appID, Title: *title, Message: *message, Priority: *priority}
+	effective := effectivePriority(cfg, *priority)
+	fmt.Printf("topic=%s priority=%d->%d\n", resolveTopic(cfg, store, msg), effective, cfg.ntfyPriority(messageEnv(cfg, store, msg)))
+
+	if cfg.dropsPriority(*priority) {
+		fmt.Fprintln(os.Stderr, "priority 0 is dropped (PRIORITY_ZERO=drop), message not sent")
+		return 1
+	}
+	err = forwardToNtfy(ctx, b, msg)
+	if errors.Is(err, errHeld) {
+		fmt.Fprintln(os.Stderr, "outside the app's delivery window, message not sent")
+		return 1
+	}
+	if errors.Is(err, errBuffered) {
+		fmt.Fprintln(os.Stderr, "ntfy is unreachable, message not sent")
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "send failed: %v\n", err)
+		return 1
+	}
+	// Metered mode batches low priorities; a one-shot command has to flush right away
+	b.batcher.Flush(ctx, cfg)
+
+	fmt.Println("sent")
+	return 0
+}
+
+// runApps lists the Gotify applications with the ntfy topic each one maps to
+// and whether it is recorded in the apps db, flagging topic collisions.
+func runApps(ctx context.Context, cfg *Config) int {
+	apps, err := getApplications(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not load applications: %v\n", err)
+		return 1
+	}
+	known, err := loadKnownApps(cfg.AppsDBPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not load apps db %s: %v\n", cfg.AppsDBPath, err)
+	}
+	store := NewAppStore(apps)
+
+	topics := make(map[string][]int64)
+	for _, a := range apps {
+		t := store.TopicFor(a.ID, cfg.NtfyTopic)
+		topics[t] = append(topics[t], a.ID)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tDESCRIPTION\tTOPIC\tIN APPS DB\tNOTE")
+	for _, a := range apps {
+		topic := cfg.NtfyTopic
+		if cfg.SplitTopics {
+			topic = store.TopicFor(a.ID, cfg.NtfyTopic)
+		}
+		_, inDB := known[a.ID]
+
+		note := ""
+		if t := store.TopicFor(a.ID, cfg.NtfyTopic); len(topics[t]) > 1 {
+			note = fmt.Sprintf("topic %q shared with apps %v", t, topics[t])
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%t\t%s\n", a.ID, a.Name, a.Description, topic, inDB, note)
+	}
+	_ = w.Flush()
+
+	if !cfg.SplitTopics {
+		fmt.Printf("\nNTFY_SPLIT_TOPICS is off: all apps publish to %s\n", cfg.NtfyTopic)
+	}
+	return 0
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+)
+
+// isSecretField reports whether a Config field holds a credential.
+func isSecretField(name string) bool {
+	return strings.Contains(name, "Token") || strings.Contains(name, "Password") || strings.Contains(name, "Secret")
+}
+
+// redactValue masks secrets and passwords embedded in URLs.
+func redactValue(name string, v any) string {
+	switch v := v.(type) {
+	case *url.URL:
+		if v == nil {
+			return ""
+		}
+		return v.Redacted()
+	case []string:
+		out := make([]string, len(v))
+		for i, s := range v {
+			out[i] = redactValue(name, s)
+		}
+		return strings.Join(out, ",")
+	case string:
+		if isSecretField(name) {
+			if v == "" {
+				return ""
+			}
+			return "********"
+		}
+		return redactURL(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// redactURL masks the password and a ?token= query parameter of URL-shaped values.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return s
+	}
+	if q := u.Query(); q.Has("token") {
+		q.Set("token", "xxxxx")
+		u.RawQuery = q.Encode()
+	}
+	return u.Redacted()
+}
+
+// runConfig implements `config show`, printing the effective configuration
+// after env files, the environment and flags were applied, secrets masked.
+func runConfig(cfg *Config, args []string) int {
+	if len(args) == 0 || args[0] != "show" {
+		fmt.Fprintln(os.Stderr, "usage: config show")
+		return 2
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, f := range configFields(cfg) {
+		fmt.Fprintf(w, "%s\t%s\n", f.Name, f.Value)
+	}
+	_ = w.Flush()
+	return 0
+}
+
+type configField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// configFields lists the exported Config fields in declaration order with
+// secrets masked.
+func configFields(cfg *Config) []configField {
+	var out []configField
+	rv := reflect.ValueOf(cfg).Elem()
+	rt := rv.Type()
+	for i := range rt.NumField() {
+		f := rt.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		out = append(out, configField{f.Name, redactValue(f.Name, rv.Field(i).Interface())})
+	}
+	return out
+}
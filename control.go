@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// ntfy sends a keepalive every 45s; a subscription silent for longer
+	// than this is considered dead and reconnected.
+	controlIdleTimeout = 2 * time.Minute
+	controlRetryMax    = time.Minute
+)
+
+// controlCommands are the commands accepted on NTFY_CONTROL_TOPIC. Each
+// returns the title and body of the reply.
+var controlCommands = map[string]func(b *Bridge) (title, body string){
+	"status": statusSnapshot,
+}
+
+// controlEvent is the part of an ntfy JSON stream event the bridge reads.
+type controlEvent struct {
+	Event   string `json:"event"`
+	Message string `json:"message"`
+}
+
+// runControlTopic subscribes to NTFY_CONTROL_TOPIC and answers commands
+// published there with a reply on the same topic, so the bridge can be
+// queried from the ntfy app alone. Other messages, including the replies,
+// are ignored.
+func runControlTopic(b *Bridge) {
+	cfg := b.cfg
+	delay := 5 * time.Second
+	for {
+		connected, err := subscribeControl(b)
+		if connected {
+			delay = 5 * time.Second
+		}
+		log.Printf("[CONTROL ERROR] subscription to %s ended, retrying in %s: %v", cfg.ControlTopic, delay, err)
+		time.Sleep(delay)
+		delay = min(delay*2, controlRetryMax)
+	}
+}
+
+// subscribeControl streams the control topic until the connection fails,
+// and reports whether it was established.
+func subscribeControl(b *Bridge) (bool, error) {
+	cfg := b.cfg
+	endpoint := strings.TrimRight(cfg.NtfyURL, "/") + "/" + url.PathEscape(cfg.ControlTopic) + "/json"
+
+	ctx, cancel := context.WithCancel(b.ctx)
+	defer cancel()
+	idle := time.AfterFunc(controlIdleTimeout, cancel)
+	defer idle.Stop()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return false, err
+	}
+	if cfg.NtfyAuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.NtfyAuthToken)
+	}
+	// The stream never ends, so the publishing client's timeout does not apply
+	client := *cfg.NtfyHTTP()
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("ntfy answered %s", resp.Status)
+	}
+	dbg(cfg, "[CONTROL] Subscribed to %s", cfg.ControlTopic)
+
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		idle.Reset(controlIdleTimeout)
+		var ev controlEvent
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil || ev.Event != "message" {
+			continue
+		}
+		command := strings.ToLower(strings.TrimSpace(ev.Message))
+		handler, ok := controlCommands[command]
+		if !ok {
+			dbg(cfg, "[CONTROL] Ignoring message on %s: %q", cfg.ControlTopic, ev.Message)
+			continue
+		}
+		log.Printf("[CONTROL] Command %q received on %s", command, cfg.ControlTopic)
+		title, body := handler(b)
+		if err := sendNtfy(ctx, cfg, cfg.ControlTopic, title, body, 3); err != nil {
+			log.Printf("[CONTROL ERROR] failed to reply to %q: %v", command, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return true, err
+	}
+	return true, fmt.Errorf("stream closed")
+}
+
+// statusSnapshot renders uptime, connection state, counters and queue
+// depths for the "status" command.
+func statusSnapshot(b *Bridge) (title, body string) {
+	cfg := b.cfg
+	snap := b.stats.Snapshot(b.store)
+	title = "Bridge status"
+	if cfg.InstanceName != "" {
+		title += ": " + cfg.InstanceName
+	}
+
+	gotify := "not connected yet"
+	switch connected, since, ever := b.stats.ConnectionState(); {
+	case connected:
+		gotify = "connected since " + since.Format("2006-01-02 15:04:05")
+	case ever:
+		gotify = "disconnected since " + since.Format("2006-01-02 15:04:05")
+	}
+	ntfy := "available"
+	if down, reason := b.ntfyDown.Down(); down {
+		ntfy = reason
+	}
+	lines := []string{
+		fmt.Sprintf("Uptime: %s (since %s)", formatDuration(time.Since(snap.Started).Round(time.Minute)), snap.Started.Format("2006-01-02 15:04")),
+		"Gotify: " + gotify,
+		"ntfy: " + ntfy,
+		fmt.Sprintf("Forwarded: %d, failed: %d, dropped: %d, duplicates: %d", snap.Forwarded, snap.ForwardErrs, snap.Dropped, snap.Duplicates),
+	}
+	if !snap.LastDelivery.IsZero() {
+		lines = append(lines, "Last delivery: "+snap.LastDelivery.Format("2006-01-02 15:04:05"))
+	}
+
+	depth := func(q *chan GotifyMessage) string {
+		if q == nil {
+			return "-"
+		}
+		return fmt.Sprintf("%d/%d", len(*q), cap(*q))
+	}
+	inFlight := 0
+	for i := range b.workers {
+		if b.workers[i].current.Load() != nil {
+			inFlight++
+		}
+	}
+	lines = append(lines, fmt.Sprintf("Queues: stream %s, critical %s, %d in flight",
+		depth(b.queue.Load()), depth(b.criticalQueue.Load()), inFlight))
+	buffer := fmt.Sprintf("Offline buffer: %d", b.buffer.Len())
+	if oldest, ok := b.buffer.Oldest(); ok {
+		buffer += fmt.Sprintf(" (oldest %s ago)", formatDuration(time.Since(oldest).Round(time.Second)))
+	}
+	lines = append(lines, buffer)
+	if n := b.held.Len(); n > 0 {
+		lines = append(lines, fmt.Sprintf("Held for delivery windows: %d", n))
+	}
+	if limited := b.limited.All(); len(limited) > 0 {
+		topics := make([]string, 0, len(limited))
+		for topic, until := range limited {
+			topics = append(topics, fmt.Sprintf("%s until %s", topic, until.Format("15:04:05")))
+		}
+		sort.Strings(topics)
+		lines = append(lines, "Rate limited: "+strings.Join(topics, ", "))
+	}
+	if b.paused.Load() {
+		lines = append(lines, "Forwarding is paused")
+	}
+	return title, strings.Join(lines, "\n")
+}
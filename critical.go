@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// messageEnv collects the fields rule conditions can refer to.
+func messageEnv(cfg *Config, store *AppStore, msg GotifyMessage) topicEnv {
+	app, _ := store.Get(msg.AppID)
+	return topicEnv{App: app.Name, AppID: msg.AppID, Priority: effectivePriority(cfg, msg.Priority), Title: msg.Title, Message: msg.Message}
+}
+
+// isCritical reports whether msg matches CRITICAL_RULE. Critical messages are
+// handled by a dedicated worker, skip metered batching and the offline buffer
+// queue, use CRITICAL_TIMEOUT for the ntfy request and are escalated to the
+// admin topic when delivery fails.
+func isCritical(cfg *Config, store *AppStore, msg GotifyMessage) bool {
+	return cfg.criticalCond != nil && cfg.criticalCond.eval(messageEnv(cfg, store, msg))
+}
+
+// escalate reports a critical message ntfy did not accept to the admin topic
+// at max priority, so the failure itself does not go unnoticed.
+func escalate(b *Bridge, p ntfyPublish, cause error) {
+	cfg := b.cfg
+	body := fmt.Sprintf("A critical message for topic %s could not be delivered: %v\n\n%s", p.Topic, cause, p.Body)
+	// The bridge's context, not the message's, which may be what ran out
+	err := publishNtfy(b.ctx, cfg, ntfyPublish{
+		Topic:    cfg.NtfyAdminTopic,
+		Title:    "Critical message not delivered: " + p.Title,
+		Body:     body,
+		Priority: 5,
+		Tags:     []string{"rotating_light"},
+		Timeout:  cfg.CriticalTimeout,
+	})
+	if err != nil {
+		log.Printf("[CRITICAL ERROR] escalation to %s failed: %v", cfg.NtfyAdminTopic, err)
+		return
+	}
+	log.Printf("[CRITICAL] Escalated failed message for %s to %s", p.Topic, cfg.NtfyAdminTopic)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",     // 4 fields
+		"* * * * * *", // 6 fields
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-x * * * *",
+	} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("%q: want an error", expr)
+		}
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	// 2025-08-20 is a Wednesday
+	at := func(day, hh, mm int) time.Time { return time.Date(2025, 8, day, hh, mm, 0, 0, time.UTC) }
+	tests := []struct {
+		expr string
+		t    time.Time
+		want bool
+	}{
+		{"* * * * *", at(20, 3, 17), true},
+		{"0 9 * * 1-5", at(20, 9, 0), true},
+		{"0 9 * * 1-5", at(23, 9, 0), false}, // Saturday
+		{"0 9 * * 1-5", at(20, 9, 1), false},
+		{"*/15 * * * *", at(20, 3, 45), true},
+		{"*/15 * * * *", at(20, 3, 46), false},
+		{"5/20 * * * *", at(20, 3, 45), true}, // 5, 25, 45
+		{"5/20 * * * *", at(20, 3, 5), true},
+		{"0 0 * * 7", at(24, 0, 0), true}, // 7 is Sunday
+		{"0 0 * * 0", at(24, 0, 0), true},
+		{"0 12 1,15 * *", at(15, 12, 0), true},
+		{"0 12 1,15 * *", at(20, 12, 0), false},
+		// Restricted day-of-month and day-of-week: either one matches
+		{"0 12 1 * 3", at(20, 12, 0), true},
+		{"0 12 1 * 3", at(21, 12, 0), false},
+		{"0 12 * 9 *", at(20, 12, 0), false},
+	}
+	for _, tt := range tests {
+		c, err := parseCron(tt.expr)
+		if err != nil {
+			t.Errorf("%q: %v", tt.expr, err)
+			continue
+		}
+		if got := c.Matches(tt.t); got != tt.want {
+			t.Errorf("%q at %s: got %v, want %v", tt.expr, tt.t.Format("Mon 2006-01-02 15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2025, 8, 20, 9, 0, 30, 0, time.UTC) // Wednesday
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2025, 8, 20, 9, 1, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2025, 8, 21, 9, 0, 0, 0, time.UTC)}, // never the current minute
+		{"30 8 * * 1", time.Date(2025, 8, 25, 8, 30, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}}, // never
+	}
+	for _, tt := range tests {
+		c, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.expr, err)
+		}
+		if got := c.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: got %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+const deadLetterQueue = 100
+
+// deadLetterReasons explain the forward results that end up here.
+var deadLetterReasons = map[string]string{
+	"failed":  "could not be delivered",
+	"expired": "expired in the offline buffer",
+	"dropped": "was dropped from the full stream queue",
+	"evicted": "was evicted from a full offline buffer or delivery window queue",
+}
+
+// deadLetter is a message given up on, waiting to be reported.
+type deadLetter struct {
+	msg GotifyMessage
+	ev  forwardEvent
+}
+
+// DeadLetters reports messages that will never reach their topic, the same
+// ones FailureHook posts: a compact notice goes to NTFY_DEAD_LETTER_TOPIC and
+// the full message to the audit log. Reports are queued so the stream reader
+// and workers never wait for them.
+type DeadLetters struct {
+	b     *Bridge
+	queue chan deadLetter
+}
+
+// NewDeadLetters returns nil without NTFY_DEAD_LETTER_TOPIC; Send is a
+// no-op on nil.
+func NewDeadLetters(b *Bridge) *DeadLetters {
+	if b.cfg.DeadLetterTopic == "" {
+		return nil
+	}
+	d := &DeadLetters{b: b, queue: make(chan deadLetter, deadLetterQueue)}
+	go d.run()
+	return d
+}
+
+// Send reports msg, which ended with ev.
+func (d *DeadLetters) Send(msg GotifyMessage, ev forwardEvent) {
+	if d == nil {
+		return
+	}
+	select {
+	case d.queue <- deadLetter{msg, ev}:
+	default:
+		log.Printf("[DEADLETTER WARN] queue full, not reporting failed message %d", msg.ID)
+	}
+}
+
+// sendBuffered reports a message from the offline buffer that failed or
+// expired. Entries restored without their Gotify message are rebuilt from
+// what would have been published.
+func (d *DeadLetters) sendBuffered(b *Bridge, m bufferedMsg, result string, err error) {
+	if d == nil {
+		return
+	}
+	msg := GotifyMessage{AppID: m.AppID, Title: m.Publish.Title, Message: m.Publish.Body, received: m.Received}
+	if m.Publish.msg != nil {
+		msg = *m.Publish.msg
+	}
+	d.Send(msg, bufferedEvent(b, m, result, err))
+}
+
+func (d *DeadLetters) run() {
+	cfg := d.b.cfg
+	for l := range d.queue {
+		msg, ev := l.msg, l.ev
+		app := ev.App
+		if app == "" {
+			app = fmt.Sprintf("app %d", msg.AppID)
+		}
+		d.b.audit.Record(d.b.ctx, "bridge", "dead_letter", map[string]any{
+			"message_id": msg.ID, "app_id": msg.AppID, "app": ev.App, "topic": ev.Topic,
+			"title": msg.Title, "message": msg.Message, "priority": msg.Priority,
+			"result": ev.Result, "error": ev.Error,
+		})
+
+		title := msg.Title
+		if title == "" {
+			title = "(no title)"
+		}
+		lines := []string{fmt.Sprintf("%s from %s %s", title, app, deadLetterReasons[ev.Result])}
+		if ev.Topic != "" {
+			lines = append(lines, "Topic: "+ev.Topic)
+		}
+		if ev.Error != "" {
+			lines = append(lines, "Error: "+ev.Error)
+		}
+		if err := sendNtfy(d.b.ctx, cfg, cfg.DeadLetterTopic, "Undelivered: "+title, strings.Join(lines, "\n"), 4); err != nil {
+			log.Printf("[DEADLETTER ERROR] could not report failed message %d to %s: %v", msg.ID, cfg.DeadLetterTopic, err)
+		}
+	}
+}
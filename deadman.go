@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// expectRule is one EXPECT_MESSAGES entry: at least one message every
+// Every, from the app named App (or with that ID), or from any app for "*".
+type expectRule struct {
+	Source string
+	App    string
+	Every  time.Duration
+}
+
+func (r expectRule) global() bool { return r.App == "*" }
+
+// matches reports whether app is the one r expects messages from.
+func (r expectRule) matches(app GotifyApp) bool {
+	if id, err := strconv.ParseInt(r.App, 10, 64); err == nil {
+		return app.ID == id
+	}
+	return strings.EqualFold(app.Name, r.App)
+}
+
+// parseExpectRules parses EXPECT_MESSAGES, "app = duration" entries
+// separated by ";" or newlines, e.g. "Backup = 25h; 7 = 2h; * = 6h".
+func parseExpectRules(src string) ([]expectRule, error) {
+	var rules []expectRule
+	for _, line := range splitOutsideQuotes(src, func(r rune) bool { return r == ';' || r == '\n' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndex(line, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("%q: missing \"= duration\"", line)
+		}
+		app := strings.Trim(strings.TrimSpace(line[:i]), `"`)
+		if app == "" {
+			return nil, fmt.Errorf("%q: missing app", line)
+		}
+		every, err := time.ParseDuration(strings.TrimSpace(line[i+1:]))
+		if err != nil || every <= 0 {
+			return nil, fmt.Errorf("%q: invalid duration, expected e.g. 30m or 25h", line)
+		}
+		rules = append(rules, expectRule{Source: line, App: app, Every: every})
+	}
+	return rules, nil
+}
+
+// DeadMan tracks when each app last sent a message, for the EXPECT_MESSAGES
+// dead man's switch. Silence is measured from startup for apps that have
+// not sent anything since.
+type DeadMan struct {
+	mu      sync.Mutex
+	started time.Time
+	any     time.Time
+	last    map[int64]time.Time
+	alerted map[string]bool // rule source -> silence already reported
+}
+
+func NewDeadMan() *DeadMan {
+	return &DeadMan{started: time.Now(), last: make(map[int64]time.Time), alerted: make(map[string]bool)}
+}
+
+// Seen records a message from appID.
+func (d *DeadMan) Seen(appID int64) {
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.any = now
+	d.last[appID] = now
+}
+
+// lastSeen returns when r last matched a message, zero if never.
+func (d *DeadMan) lastSeen(r expectRule, store *AppStore) time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if r.global() {
+		return d.any
+	}
+	var last time.Time
+	for id, t := range d.last {
+		app, _ := store.Get(id)
+		app.ID = id
+		if r.matches(app) && t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
+
+// setAlerted records whether r is in the silent state and reports whether
+// that changed.
+func (d *DeadMan) setAlerted(r expectRule, silent bool) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.alerted[r.Source] == silent {
+		return false
+	}
+	d.alerted[r.Source] = silent
+	return true
+}
+
+// check alerts the admin topic about rules whose window passed without a
+// message, once per silence, and again when messages resume.
+func (d *DeadMan) check(b *Bridge, now time.Time) {
+	cfg := b.cfg
+	for _, r := range cfg.ExpectRules {
+		last := d.lastSeen(r, b.store)
+		since := last
+		if since.IsZero() {
+			since = d.started
+		}
+		silent := now.Sub(since) >= r.Every
+		if !d.setAlerted(r, silent) {
+			continue
+		}
+
+		who := "any app"
+		if !r.global() {
+			who = r.App
+		}
+		var title, body string
+		priority := 8
+		if silent {
+			title = "No messages from " + who
+			body = fmt.Sprintf("Expected at least one message from %s every %s, but none arrived ", who, formatDuration(r.Every))
+			if last.IsZero() {
+				body += fmt.Sprintf("since the bridge started %s ago.", formatDuration(now.Sub(d.started).Round(time.Minute)))
+			} else {
+				body += fmt.Sprintf("for %s (last at %s).", formatDuration(now.Sub(last).Round(time.Minute)), last.Format("2006-01-02 15:04 MST"))
+			}
+			body += "\nThe job or service sending them may have stopped."
+			log.Printf("[DEADMAN WARN] no messages from %s for %s", who, formatDuration(now.Sub(since).Round(time.Minute)))
+		} else {
+			title = "Messages from " + who + " resumed"
+			body = fmt.Sprintf("%s is sending messages again (expected every %s).", who, formatDuration(r.Every))
+			priority = 4
+			log.Printf("[DEADMAN] messages from %s resumed", who)
+		}
+		if err := sendNtfy(b.ctx, cfg, cfg.NtfyAdminTopic, title, body, priority); err != nil {
+			log.Printf("[DEADMAN ERROR] failed to send alert for %s: %v", who, err)
+			// Try again on the next check
+			d.setAlerted(r, !silent)
+		}
+	}
+}
+
+// deadManInterval is how often EXPECT_MESSAGES windows are checked.
+const deadManInterval = time.Minute
+
+func runDeadMan(b *Bridge) {
+	ticker := time.NewTicker(deadManInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		b.deadman.check(b, now)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// idRing remembers the last N message IDs of one app.
+type idRing struct {
+	ids  []int64
+	set  map[int64]struct{}
+	next int
+}
+
+// DedupGuard detects message IDs that were already seen for an app, e.g.
+// when a clustered Gotify behind a load balancer replays messages after a
+// failover.
+type DedupGuard struct {
+	mu     sync.Mutex
+	window int
+	apps   map[int64]*idRing
+}
+
+func NewDedupGuard(window int) *DedupGuard {
+	return &DedupGuard{window: window, apps: make(map[int64]*idRing)}
+}
+
+// Seen records msgID for appID and reports whether it was already known.
+// IDs of 0 (not assigned by Gotify) are never treated as duplicates.
+func (d *DedupGuard) Seen(appID, msgID int64) bool {
+	if d.window <= 0 || msgID == 0 {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	r, ok := d.apps[appID]
+	if !ok {
+		r = &idRing{ids: make([]int64, d.window), set: make(map[int64]struct{}, d.window)}
+		d.apps[appID] = r
+	}
+	if _, dup := r.set[msgID]; dup {
+		return true
+	}
+	if old := r.ids[r.next]; old != 0 {
+		delete(r.set, old)
+	}
+	r.ids[r.next] = msgID
+	r.set[msgID] = struct{}{}
+	r.next = (r.next + 1) % d.window
+	return false
+}
+
+// sentKey identifies a forwarded message. IDs are keyed by app as in
+// DedupGuard, so a failover server with overlapping IDs is less likely to hit.
+type sentKey struct {
+	appID, msgID int64
+}
+
+// SentIDs is an LRU of the message IDs forwarded recently (DEDUP_SENT).
+// DedupGuard filters what is read from Gotify; SentIDs is checked right
+// before publishing, so a message that reaches the workers twice, e.g. from
+// the stream and a backfill, or from the WAL and the stream, is published to
+// ntfy only once.
+type SentIDs struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // of sentKey, most recently used first
+	ids   map[sentKey]*list.Element
+}
+
+func NewSentIDs(size int) *SentIDs {
+	return &SentIDs{size: size, order: list.New(), ids: make(map[sentKey]*list.Element)}
+}
+
+// Claim records msgID of appID as forwarded and reports false if it already
+// was. Claiming before publishing keeps two workers from sending the same
+// message. IDs of 0 are always claimed.
+func (s *SentIDs) Claim(appID, msgID int64) bool {
+	if s.size <= 0 || msgID == 0 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := sentKey{appID, msgID}
+	if e, ok := s.ids[key]; ok {
+		s.order.MoveToFront(e)
+		return false
+	}
+	s.ids[key] = s.order.PushFront(key)
+	if s.order.Len() > s.size {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.ids, oldest.Value.(sentKey))
+	}
+	return true
+}
+
+// Release forgets a claim after the forward failed, so the message is sent
+// if it arrives again.
+func (s *SentIDs) Release(appID, msgID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := sentKey{appID, msgID}
+	if e, ok := s.ids[key]; ok {
+		s.order.Remove(e)
+		delete(s.ids, key)
+	}
+}
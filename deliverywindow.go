@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// windowCheckInterval is how often held messages are checked against their
+// delivery window.
+const windowCheckInterval = time.Minute
+
+// errHeld is returned by forwardToNtfy when a message arrived outside its
+// app's delivery window and waits for it to open. It counts as buffered.
+var errHeld = fmt.Errorf("held until the delivery window opens: %w", errBuffered)
+
+// deliveryWindow is a daily time range in local time, e.g. 09:00-21:00. A
+// range like 22:00-06:00 spans midnight.
+type deliveryWindow struct {
+	from, to int // minutes since midnight
+}
+
+func parseDeliveryWindow(s string) (*deliveryWindow, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid window %q, expected HH:MM-HH:MM", s)
+	}
+	var w deliveryWindow
+	for i, part := range []string{from, to} {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid window %q, expected HH:MM-HH:MM", s)
+		}
+		if i == 0 {
+			w.from = t.Hour()*60 + t.Minute()
+		} else {
+			w.to = t.Hour()*60 + t.Minute()
+		}
+	}
+	if w.from == w.to {
+		return nil, fmt.Errorf("invalid window %q, start and end are equal", s)
+	}
+	return &w, nil
+}
+
+// Open reports whether t falls within the window. A nil window is always open.
+func (w *deliveryWindow) Open(t time.Time) bool {
+	if w == nil {
+		return true
+	}
+	m := t.Hour()*60 + t.Minute()
+	if w.from < w.to {
+		return m >= w.from && m < w.to
+	}
+	return m >= w.from || m < w.to
+}
+
+func (w *deliveryWindow) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.from/60, w.from%60, w.to/60, w.to%60)
+}
+
+type heldMsg struct {
+	AppID    int64
+	Received time.Time
+	Publish  ntfyPublish
+	window   *deliveryWindow
+}
+
+// HeldMessages keeps messages that arrived outside their app's delivery
+// window, see the profile "window" setting. Once the window opens they move
+// to the offline buffer, which delivers them in order and applies TTLs. Held
+// messages live in memory only; with WAL_FILE they are replayed after a
+// restart and held again if their window is still closed.
+type HeldMessages struct {
+	mu      sync.Mutex
+	max     int
+	items   []heldMsg
+	evicted uint64
+
+	// onEvict is called with each evicted message, outside the lock
+	onEvict func(bufferedMsg)
+}
+
+func NewHeldMessages(max int) *HeldMessages {
+	return &HeldMessages{max: max}
+}
+
+func (h *HeldMessages) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.items)
+}
+
+// Add holds p until w opens, evicting the oldest message when full.
+func (h *HeldMessages) Add(appID int64, p ntfyPublish, received time.Time, w *deliveryWindow) {
+	h.mu.Lock()
+	h.items = append(h.items, heldMsg{AppID: appID, Received: received, Publish: p, window: w})
+	var dropped heldMsg
+	evict := h.max > 0 && len(h.items) > h.max
+	if evict {
+		dropped = h.items[0]
+		h.items = h.items[1:]
+		h.evicted++
+		log.Printf("[WINDOW WARN] too many held messages, dropping oldest for app %d topic=%s", dropped.AppID, dropped.Publish.Topic)
+	}
+	h.mu.Unlock()
+	if evict && h.onEvict != nil {
+		h.onEvict(bufferedMsg{AppID: dropped.AppID, Received: dropped.Received, Publish: dropped.Publish})
+	}
+}
+
+// due removes and returns the messages whose window is open at now, in the
+// order they arrived.
+func (h *HeldMessages) due(now time.Time) []heldMsg {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var out []heldMsg
+	kept := h.items[:0]
+	for _, m := range h.items {
+		if m.window.Open(now) {
+			out = append(out, m)
+		} else {
+			kept = append(kept, m)
+		}
+	}
+	clear(h.items[len(kept):])
+	h.items = kept
+	return out
+}
+
+// runDeliveryWindows releases held messages into the offline buffer once
+// their window opens.
+func runDeliveryWindows(b *Bridge) {
+	ticker := time.NewTicker(windowCheckInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		due := b.held.due(now)
+		if len(due) == 0 {
+			continue
+		}
+		log.Printf("[WINDOW] Delivery window open, releasing %d held messages", len(due))
+		for _, m := range due {
+			b.buffer.AddReceived(m.AppID, m.Publish, m.Received)
+		}
+	}
+}
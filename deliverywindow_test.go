@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDeliveryWindow(t *testing.T) {
+	tests := []struct {
+		in       string
+		from, to int
+		wantErr  bool
+	}{
+		{in: "08:00-22:00", from: 8 * 60, to: 22 * 60},
+		{in: " 22:30 - 07:15 ", from: 22*60 + 30, to: 7*60 + 15},
+		{in: "00:00-23:59", from: 0, to: 23*60 + 59},
+		{in: "08:00", wantErr: true},
+		{in: "8-22", wantErr: true},
+		{in: "08:00-24:00", wantErr: true},
+		{in: "08:00-08:00", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		w, err := parseDeliveryWindow(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (w.from != tt.from || w.to != tt.to) {
+			t.Errorf("%q: got %d-%d, want %d-%d", tt.in, w.from, w.to, tt.from, tt.to)
+		}
+	}
+}
+
+func TestDeliveryWindowOpen(t *testing.T) {
+	at := func(hh, mm int) time.Time { return time.Date(2025, 8, 20, hh, mm, 0, 0, time.UTC) }
+	day, _ := parseDeliveryWindow("08:00-22:00")
+	night, _ := parseDeliveryWindow("22:00-07:00")
+	tests := []struct {
+		w    *deliveryWindow
+		t    time.Time
+		want bool
+	}{
+		{day, at(7, 59), false},
+		{day, at(8, 0), true},
+		{day, at(21, 59), true},
+		{day, at(22, 0), false},
+		{night, at(21, 59), false},
+		{night, at(22, 0), true},
+		{night, at(3, 0), true},
+		{night, at(7, 0), false},
+		{nil, at(3, 0), true},
+	}
+	for _, tt := range tests {
+		if got := tt.w.Open(tt.t); got != tt.want {
+			t.Errorf("%v at %s: got %v, want %v", tt.w, tt.t.Format("15:04"), got, tt.want)
+		}
+	}
+	if s := night.String(); s != "22:00-07:00" {
+		t.Errorf("String() = %q", s)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// deprecatedEnv maps a deprecated environment variable to its replacement.
+type deprecatedEnv struct {
+	Old     string
+	New     string
+	Removal string // release in which Old stops being read
+
+	// Convert maps an old value to the new setting's; nil copies it as is
+	Convert func(string) string
+}
+
+// deprecatedEnvs lists renamed settings. Old names keep working until their
+// removal release, with a warning at startup.
+var deprecatedEnvs = []deprecatedEnv{
+	{Old: "NTFY_DEBUG", New: "LOG_LEVEL", Removal: "v2.0.0", Convert: func(v string) string {
+		if strings.ToLower(v) == "true" {
+			return "debug"
+		}
+		return "info"
+	}},
+}
+
+// applyDeprecations copies deprecated variables to their replacements when
+// those are unset, and returns a warning for every deprecated name in use.
+func applyDeprecations() []string {
+	var warnings []string
+	for _, d := range deprecatedEnvs {
+		old, ok := os.LookupEnv(d.Old)
+		if !ok {
+			continue
+		}
+		if _, set := os.LookupEnv(d.New); set {
+			warnings = append(warnings, fmt.Sprintf("%s is deprecated and ignored because %s is set; remove it (support ends in %s)", d.Old, d.New, d.Removal))
+			continue
+		}
+		if d.Convert != nil {
+			old = d.Convert(old)
+		}
+		_ = os.Setenv(d.New, old)
+		warnings = append(warnings, fmt.Sprintf("%s is deprecated, rename it to %s (support ends in %s)", d.Old, d.New, d.Removal))
+	}
+	return warnings
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// digestBaseline holds the counters at the start of a digest period.
+type digestBaseline struct {
+	since    time.Time
+	snap     StatsSnapshot
+	connects int64
+}
+
+func newDigestBaseline(b *Bridge) digestBaseline {
+	return digestBaseline{since: time.Now(), snap: b.stats.Snapshot(b.store), connects: b.stats.connectCount()}
+}
+
+// dailyDigest renders what happened since base: messages per app, failures,
+// drops and reconnects, plus the uptime and the 30-day availability figures.
+func dailyDigest(b *Bridge, base digestBaseline, now time.Time) (title, body string) {
+	snap := b.stats.Snapshot(b.store)
+	title = "Daily digest " + now.Format("2006-01-02")
+
+	prev := make(map[int64]AppStats, len(base.snap.Apps))
+	for _, a := range base.snap.Apps {
+		prev[a.AppID] = a
+	}
+	type appDelta struct {
+		name              string
+		forwarded, failed int64
+	}
+	var apps []appDelta
+	for _, a := range snap.Apps {
+		d := appDelta{name: a.Name, forwarded: a.Forwarded - prev[a.AppID].Forwarded, failed: a.Failed - prev[a.AppID].Failed}
+		if d.name == "" {
+			d.name = fmt.Sprintf("#%d", a.AppID)
+		}
+		if d.forwarded > 0 || d.failed > 0 {
+			apps = append(apps, d)
+		}
+	}
+	sort.SliceStable(apps, func(i, j int) bool { return apps[i].forwarded > apps[j].forwarded })
+
+	// The first connect after startup is not a reconnect
+	reconnects := b.stats.connectCount() - base.connects
+	if base.connects == 0 && reconnects > 0 {
+		reconnects--
+	}
+	connection := "connected"
+	if !snap.Connected {
+		connection = "disconnected"
+	}
+
+	lines := []string{
+		fmt.Sprintf("Since %s:", base.since.Format("2006-01-02 15:04 MST")),
+		fmt.Sprintf("Forwarded: %d", snap.Forwarded-base.snap.Forwarded),
+		fmt.Sprintf("Failures: %d", snap.ForwardErrs-base.snap.ForwardErrs),
+		fmt.Sprintf("Dropped: %d", snap.Dropped-base.snap.Dropped),
+		fmt.Sprintf("Reconnects: %d", reconnects),
+		fmt.Sprintf("Uptime: %s (%s)", formatDuration(now.Sub(snap.Started).Round(time.Minute)), connection),
+		fmt.Sprintf("%d-day availability: bridge %s, Gotify %s, ntfy %s", snap.SLA.WindowDays,
+			formatPct(snap.SLA.BridgeUptimePct), formatPct(snap.SLA.GotifyAvailablePct), formatPct(snap.SLA.NtfySuccessPct)),
+	}
+	if b.cfg.ResourceMonitor {
+		lines = append(lines, resourceSummary(b, now)...)
+	}
+	if len(apps) > 0 {
+		lines = append(lines, "", "Per app:")
+		for _, a := range apps {
+			line := fmt.Sprintf("- %s: %d forwarded", a.name, a.forwarded)
+			if a.failed > 0 {
+				line += fmt.Sprintf(", %d failed", a.failed)
+			}
+			lines = append(lines, line)
+		}
+	} else {
+		lines = append(lines, "", "No messages forwarded.")
+	}
+	return title, strings.Join(lines, "\n")
+}
+
+// runDailyDigest publishes the digest to the admin topic every day at
+// DAILY_DIGEST_TIME (local time, see TZ). The first digest after a restart
+// covers the time since startup.
+func runDailyDigest(b *Bridge) {
+	cfg := b.cfg
+	base := newDigestBaseline(b)
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+		today := now.Format("2006-01-02")
+		if now.Format("15:04") < cfg.DailyDigestTime || b.volume.DigestSentOn(today) {
+			continue
+		}
+		title, body := dailyDigest(b, base, now)
+		if err := sendNtfy(b.ctx, cfg, cfg.NtfyAdminTopic, title, body, 2); err != nil {
+			log.Printf("[REPORT ERROR] failed to send daily digest: %v", err)
+			continue
+		}
+		log.Printf("[REPORT] Sent daily digest to %s", cfg.NtfyAdminTopic)
+		base = newDigestBaseline(b)
+		b.volume.MarkDigestSent(today)
+		if err := b.volume.Save(); err != nil {
+			log.Printf("[REPORT ERROR] could not save %s: %v", cfg.ReportDBPath, err)
+		}
+	}
+}
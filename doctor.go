@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+)
+
+// ntfyTopicRe is what ntfy accepts as a topic name.
+var ntfyTopicRe = regexp.MustCompile(`^[-_A-Za-z0-9]{1,64}$`)
+
+// doctorFinding is one row of the doctor report.
+type doctorFinding struct {
+	Check  string
+	Status string // OK, WARN or FAIL
+	Detail string // what is wrong and how to fix it
+}
+
+// runDoctor lints the raw settings for common Gotify and ntfy pitfalls,
+// offline. It runs before loadConfig, so it also explains settings the
+// bridge refuses to start with.
+func runDoctor(w io.Writer, envFiles []string) int {
+	var findings []doctorFinding
+	add := func(check, status, format string, a ...any) {
+		findings = append(findings, doctorFinding{check, status, fmt.Sprintf(format, a...)})
+	}
+
+	cfg, err := loadConfig(envFiles)
+	if err != nil {
+		add("config", "FAIL", "%v", err)
+	} else {
+		add("config", "OK", "all settings parse")
+	}
+
+	doctorGotifyURL(add)
+	doctorGotifyAPIURL(add)
+	doctorNtfyURL(add)
+	doctorTokens(add)
+	doctorTopics(cfg, add)
+
+	failed := false
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tRESULT\tDETAIL")
+	for _, f := range findings {
+		if f.Status == "FAIL" {
+			failed = true
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", f.Check, f.Status, f.Detail)
+	}
+	_ = tw.Flush()
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+type doctorAdd func(check, status, format string, a ...any)
+
+func doctorGotifyURL(add doctorAdd) {
+	raw := os.Getenv("GOTIFY_URL")
+	if raw == "" {
+		add("GOTIFY_URL", "FAIL", "not set; use the stream URL, e.g. wss://gotify.example.com/stream")
+		return
+	}
+	appendStream := strings.ToLower(os.Getenv("GOTIFY_APPEND_STREAM")) != "false"
+	for _, s := range strings.Split(raw, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		u, err := url.Parse(s)
+		if err != nil || u.Host == "" {
+			add("GOTIFY_URL", "FAIL", "%s is not a URL; expected e.g. wss://gotify.example.com/stream", s)
+			continue
+		}
+		switch u.Scheme {
+		case "ws", "wss":
+		case "http", "https":
+			fixed := *u
+			fixed.Scheme = map[string]string{"http": "ws", "https": "wss"}[u.Scheme]
+			suggestion, _ := withStreamPath(fixed.String())
+			add("GOTIFY_URL", "FAIL", "%s uses %s://, but the stream is a websocket; use %s", s, u.Scheme, suggestion)
+			continue
+		default:
+			add("GOTIFY_URL", "FAIL", "%s: unsupported scheme %q, use ws:// or wss://", s, u.Scheme)
+			continue
+		}
+		if !strings.HasSuffix(u.Path, "/stream") {
+			fixed, _ := withStreamPath(s)
+			if appendStream {
+				add("GOTIFY_URL", "WARN", "%s does not end in /stream; it is appended automatically, write %s to be explicit", s, fixed)
+			} else {
+				add("GOTIFY_URL", "FAIL", "%s does not end in /stream and GOTIFY_APPEND_STREAM=false; unless a proxy maps this path to the stream, use %s", s, fixed)
+			}
+			continue
+		}
+		if u.Scheme == "ws" && !isLocalHost(u.Hostname()) {
+			add("GOTIFY_URL", "WARN", "%s is unencrypted; the client token travels in clear text, use wss:// if Gotify is behind TLS", s)
+			continue
+		}
+		add("GOTIFY_URL", "OK", "%s", redactURL(s))
+	}
+}
+
+func doctorGotifyAPIURL(add doctorAdd) {
+	raw := os.Getenv("GOTIFY_API_URL")
+	if raw == "" {
+		return
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		add("GOTIFY_API_URL", "FAIL", "%s is not a URL; expected e.g. https://gotify.example.com", raw)
+		return
+	}
+	switch {
+	case u.Scheme == "ws" || u.Scheme == "wss":
+		add("GOTIFY_API_URL", "FAIL", "%s uses %s://, but the REST API is plain HTTP; use %s://", raw, u.Scheme, strings.Replace(u.Scheme, "ws", "http", 1))
+	case u.Scheme != "http" && u.Scheme != "https":
+		add("GOTIFY_API_URL", "FAIL", "%s: unsupported scheme %q, use http:// or https://", raw, u.Scheme)
+	case path.Base(u.Path) == "stream":
+		add("GOTIFY_API_URL", "WARN", "%s ends in /stream; GOTIFY_API_URL is the REST base, drop /stream", raw)
+	default:
+		add("GOTIFY_API_URL", "OK", "%s", raw)
+	}
+}
+
+func doctorNtfyURL(add doctorAdd) {
+	raw := os.Getenv("NTFY_URL")
+	if raw == "" {
+		add("NTFY_URL", "FAIL", "not set; use the server base URL, e.g. https://ntfy.sh")
+		return
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		add("NTFY_URL", "FAIL", "%s is not a URL; expected e.g. https://ntfy.example.com", raw)
+		return
+	}
+	topic := os.Getenv("NTFY_TOPIC")
+	switch {
+	case u.Scheme == "ws" || u.Scheme == "wss":
+		add("NTFY_URL", "FAIL", "%s uses %s://, but messages are published over HTTP; use %s://", raw, u.Scheme, strings.Replace(u.Scheme, "ws", "http", 1))
+	case u.Scheme != "http" && u.Scheme != "https":
+		add("NTFY_URL", "FAIL", "%s: unsupported scheme %q, use http:// or https://", raw, u.Scheme)
+	case topic != "" && path.Base(u.Path) == topic:
+		add("NTFY_URL", "FAIL", "%s includes the topic, so messages would go to .../%s/%s; set NTFY_URL to the server base", raw, topic, topic)
+	case strings.Trim(u.Path, "/") != "":
+		add("NTFY_URL", "WARN", "%s has a path; fine if ntfy is served under it, otherwise use the server base and set the topic in NTFY_TOPIC", raw)
+	case u.Scheme == "http" && os.Getenv("NTFY_AUTH_TOKEN") != "" && !isLocalHost(u.Hostname()):
+		add("NTFY_URL", "WARN", "%s is unencrypted; NTFY_AUTH_TOKEN travels in clear text, use https://", raw)
+	default:
+		add("NTFY_URL", "OK", "%s", raw)
+	}
+}
+
+func doctorTokens(add doctorAdd) {
+	// Gotify prefixes application tokens with A and client tokens with C
+	switch token := os.Getenv("GOTIFY_CLIENT_TOKEN"); {
+	case token == "":
+		if os.Getenv("GOTIFY_USER") != "" {
+			add("GOTIFY_CLIENT_TOKEN", "OK", "not set, logging in as GOTIFY_USER")
+		}
+	case strings.HasPrefix(token, "A"):
+		add("GOTIFY_CLIENT_TOKEN", "FAIL", "looks like an application token (they start with A); application tokens can only send. "+
+			"Create a client under Clients in the Gotify web UI and use its token (starts with C)")
+	case !strings.HasPrefix(token, "C"):
+		add("GOTIFY_CLIENT_TOKEN", "WARN", "does not look like a Gotify client token (they start with C); check it was copied completely")
+	default:
+		add("GOTIFY_CLIENT_TOKEN", "OK", "looks like a client token")
+	}
+
+	if token := os.Getenv("NTFY_AUTH_TOKEN"); token != "" {
+		if strings.HasPrefix(token, "tk_") {
+			add("NTFY_AUTH_TOKEN", "OK", "looks like an ntfy access token")
+		} else {
+			add("NTFY_AUTH_TOKEN", "WARN", "ntfy access tokens start with tk_; create one with `ntfy token add <user>` or in the web app under Account")
+		}
+	}
+}
+
+func doctorTopics(cfg *Config, add doctorAdd) {
+	check := func(name, topic string) {
+		if topic == "" {
+			return
+		}
+		if !ntfyTopicRe.MatchString(topic) {
+			add(name, "FAIL", "%q will be rejected by ntfy; topics are 1-64 letters, digits, _ or -", topic)
+			return
+		}
+		add(name, "OK", "%s", topic)
+	}
+	check("NTFY_TOPIC", os.Getenv("NTFY_TOPIC"))
+	check("NTFY_ADMIN_TOPIC", os.Getenv("NTFY_ADMIN_TOPIC"))
+	check("NTFY_ERROR_TOPIC", os.Getenv("NTFY_ERROR_TOPIC"))
+	check("HEARTBEAT_TOPIC", os.Getenv("HEARTBEAT_TOPIC"))
+	if topic := os.Getenv("NTFY_TOPIC"); topic != "" && len(topic) < 8 && strings.Contains(os.Getenv("NTFY_URL"), "ntfy.sh") {
+		add("NTFY_TOPIC", "WARN", "%q is short and easy to guess on the public ntfy.sh; anyone can subscribe to it", topic)
+	}
+
+	if cfg == nil {
+		return
+	}
+	for _, r := range cfg.Rules().active {
+		if strings.Contains(r.Topic, "{") {
+			continue // expanded per message
+		}
+		if !ntfyTopicRe.MatchString(r.Topic) {
+			add("TOPIC_RULES", "FAIL", "rule %q targets %q, which ntfy will reject", r.Source, r.Topic)
+		}
+	}
+
+	// Split topics are derived from app names; check the apps seen last time
+	apps, err := loadKnownApps(cfg.AppsDBPath)
+	if err != nil || !cfg.SplitTopics {
+		return
+	}
+	for _, a := range apps {
+		if topic := sanitizeTopic(a.Name); !ntfyTopicRe.MatchString(topic) {
+			add("split topic", "FAIL", "app %s (ID=%d) maps to %q, which ntfy will reject; shorten the app name or route it with TOPIC_RULES", a.Name, a.ID, topic)
+		}
+	}
+}
+
+// isLocalHost reports whether host is a loopback name or address, where
+// plain HTTP is harmless.
+func isLocalHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// dropAlertInterval is how often dropped messages are reported, so a burst
+// of drops produces one alert rather than one per message.
+const dropAlertInterval = time.Minute
+
+// runDropAlerts notifies the error topic about messages dropped because the
+// stream queue was full since the previous check.
+func runDropAlerts(b *Bridge) {
+	cfg := b.cfg
+	ticker := time.NewTicker(dropAlertInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		n := b.recentDrops.Swap(0)
+		if n == 0 {
+			continue
+		}
+		title := "Gotify messages dropped"
+		body := fmt.Sprintf("%d messages dropped in the last minute because the stream queue was full.\n"+
+			"ntfy may be slow or the bridge overloaded; check the log and /metrics.", n)
+		if err := sendNtfy(b.ctx, cfg, cfg.NtfyErrorTopic, title, body, 8); err != nil {
+			log.Printf("[NTFY ERROR] failed to send dropped messages alert: %v", err)
+		}
+	}
+}
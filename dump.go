@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// dumpState logs the bridge's internal state for debugging stalled delivery.
+func dumpState(b *Bridge) {
+	cfg := b.cfg
+	queued, capacity := 0, 0
+	if q := b.queue.Load(); q != nil {
+		queued, capacity = len(*q), cap(*q)
+	}
+
+	log.Printf("[STATE] connected=%v gotify=%s reconnect_attempt=%d", b.stats.Connected(), cfg.ActiveGotifyURL(), b.reconnectAttempt.Load())
+	log.Printf("[STATE] stream_queue=%d/%d offline_buffer=%d last_forwarded_id=%d", queued, capacity, b.buffer.Len(), b.lastForwardedID.Load())
+	for i := range b.workers {
+		w := &b.workers[i]
+		name := fmt.Sprint(i + 1)
+		if i == streamWorkers {
+			name += " (critical)"
+		}
+		log.Printf("[STATE] worker %s: forwarded=%d failed=%d buffered=%d", name, w.forwarded.Load(), w.failed.Load(), w.buffered.Load())
+	}
+	if reserved := b.reserved.All(); len(reserved) > 0 {
+		log.Printf("[STATE] reserved topics (delivered to %s): %v", cfg.NtfyTopic, reserved)
+	}
+	for topic, until := range b.limited.All() {
+		log.Printf("[STATE] topic %s rate limited until %s", topic, until.Format("15:04:05"))
+	}
+	if cfg.TopicAnnounce {
+		log.Printf("[STATE] announced topics: %v", b.announced.All())
+	}
+	apps := b.store.All()
+	log.Printf("[STATE] %d apps:", len(apps))
+	for _, app := range apps {
+		topic := cfg.NtfyTopic
+		if cfg.SplitTopics {
+			topic = b.store.TopicFor(app.ID, cfg.NtfyTopic)
+		}
+		log.Printf("[STATE] - ID=%d Name=%s Topic=%s Muted=%v", app.ID, app.Name, topic, b.mutes.Muted(app.ID))
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	// errorAlertInterval is how often errors are summarized, so a burst of
+	// failures produces one alert rather than one per message.
+	errorAlertInterval = time.Minute
+
+	// A reconnect storm is this many Gotify connects within the window.
+	reconnectStormCount  = 5
+	reconnectStormWindow = 10 * time.Minute
+)
+
+// runErrorAlerts publishes forward failures, app sync failures and reconnect
+// storms to NTFY_ERROR_TOPIC.
+func runErrorAlerts(b *Bridge) {
+	cfg := b.cfg
+	ticker := time.NewTicker(errorAlertInterval)
+	defer ticker.Stop()
+
+	prev, since := b.stats.Snapshot(b.store), time.Now()
+	connects := []int64{b.stats.connectCount()} // per tick, oldest first
+	storm := false
+	for now := range ticker.C {
+		snap := b.stats.Snapshot(b.store)
+
+		if n := snap.ForwardErrs - prev.ForwardErrs; n > 0 {
+			body := fmt.Sprintf("%d messages could not be forwarded in the last %s.", n, formatDuration(now.Sub(since).Round(time.Minute)))
+			if failed := recentFailures(b, since, 3); len(failed) > 0 {
+				body += "\n\nLatest:\n" + strings.Join(failed, "\n")
+			}
+			if err := sendNtfy(b.ctx, cfg, cfg.NtfyErrorTopic, "Forward failures", body, 4); err != nil {
+				log.Printf("[NTFY ERROR] failed to send forward failures alert: %v", err)
+			}
+		}
+
+		if n := snap.SyncErrs - prev.SyncErrs; n > 0 {
+			body := fmt.Sprintf("%d app syncs with Gotify failed in the last %s; new apps and renames are not picked up.\n"+
+				"Check the log for [SYNC ERROR] lines.", n, formatDuration(now.Sub(since).Round(time.Minute)))
+			if err := sendNtfy(b.ctx, cfg, cfg.NtfyErrorTopic, "App sync failing", body, 3); err != nil {
+				log.Printf("[NTFY ERROR] failed to send sync failures alert: %v", err)
+			}
+		}
+
+		connects = append(connects, b.stats.connectCount())
+		if window := int(reconnectStormWindow / errorAlertInterval); len(connects) > window+1 {
+			connects = connects[1:]
+		}
+		switch reconnects := connects[len(connects)-1] - connects[0]; {
+		case reconnects >= reconnectStormCount && !storm:
+			storm = true
+			log.Printf("[GOTIFY WARN] reconnect storm: %d connections within %s", reconnects, formatDuration(reconnectStormWindow))
+			body := fmt.Sprintf("The Gotify stream connected %d times within %s: it keeps dropping shortly after connecting.\n"+
+				"Check proxies or load balancers between the bridge and Gotify for idle or websocket timeouts.\nURL: %s",
+				reconnects, formatDuration(reconnectStormWindow), redactURL(cfg.ActiveGotifyURL()))
+			if err := sendNtfy(b.ctx, cfg, cfg.NtfyErrorTopic, "Gotify reconnect storm", body, 5); err != nil {
+				log.Printf("[NTFY ERROR] failed to send reconnect storm alert: %v", err)
+				storm = false
+			}
+		case reconnects == 0:
+			storm = false
+		}
+
+		prev, since = snap, now
+	}
+}
+
+// recentFailures describes up to n failed forwards since t, newest first.
+func recentFailures(b *Bridge, t time.Time, n int) []string {
+	var lines []string
+	for _, e := range b.recent.Recent() {
+		if len(lines) == n || e.Time.Before(t) {
+			break
+		}
+		if e.Result != "failed" {
+			continue
+		}
+		app := e.App
+		if app == "" {
+			app = fmt.Sprintf("#%d", e.AppID)
+		}
+		lines = append(lines, fmt.Sprintf("- %s %s -> %s: %s", e.Time.Format("15:04:05"), app, e.Topic, e.Error))
+	}
+	return lines
+}
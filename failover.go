@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// errFailback is returned by listenAndForward when it dropped a failover
+// connection because the primary Gotify URL became reachable again.
+var errFailback = errors.New("primary Gotify URL recovered")
+
+// errDial marks listenAndForward errors where no connection was established.
+var errDial = errors.New("could not connect to Gotify")
+
+// exitReconnectLimit is the exit code used when MAX_RECONNECT_ATTEMPTS is exceeded.
+const exitReconnectLimit = 3
+
+// ActiveGotifyURL returns the Gotify websocket URL currently in use.
+func (c *Config) ActiveGotifyURL() string {
+	if len(c.GotifyURLs) == 0 {
+		return c.GotifyURL
+	}
+	return c.GotifyURLs[int(c.gotifyActive.Load())%len(c.GotifyURLs)]
+}
+
+// FailoverGotifyURL switches to the next configured Gotify URL and returns it.
+func (c *Config) FailoverGotifyURL() string {
+	if len(c.GotifyURLs) > 1 {
+		c.gotifyActive.Store((c.gotifyActive.Load() + 1) % int32(len(c.GotifyURLs)))
+	}
+	return c.ActiveGotifyURL()
+}
+
+// ResetGotifyURL switches back to the primary Gotify URL.
+func (c *Config) ResetGotifyURL() {
+	c.gotifyActive.Store(0)
+}
+
+// probePrimary periodically dials the primary Gotify URL while conn is a
+// failover connection. Once the primary answers it switches the active URL
+// back, signals failback and closes conn so the read loop reconnects. It
+// returns once ctx is done.
+func probePrimary(ctx context.Context, cfg *Config, conn *websocket.Conn, failback chan<- struct{}) {
+	ticker := time.NewTicker(cfg.GotifyFailbackInterval)
+	defer ticker.Stop()
+
+	headers := http.Header{}
+	headers.Set("X-Gotify-Key", cfg.GotifyToken)
+	headers.Set("User-Agent", cfg.UserAgent)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		probe, _, err := cfg.GotifyDialer().DialContext(ctx, cfg.GotifyURL, headers)
+		if err != nil {
+			dbg(cfg, "[FAILOVER] Primary Gotify URL still unreachable: %v", err)
+			continue
+		}
+		_ = probe.Close()
+
+		cfg.ResetGotifyURL()
+		close(failback)
+		_ = conn.Close()
+		return
+	}
+}
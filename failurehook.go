@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	failureHookQueue    = 100
+	failureHookAttempts = 3
+)
+
+// failurePayload is the JSON body posted to FAILURE_WEBHOOK_URL. Unlike the
+// message log it carries the message itself, so the receiver can act on it.
+type failurePayload struct {
+	Event       string    `json:"event"` // always "forward_failed"
+	Time        time.Time `json:"time"`
+	Received    time.Time `json:"received,omitzero"`
+	MessageID   int64     `json:"message_id"`
+	AppID       int64     `json:"app_id"`
+	App         string    `json:"app,omitempty"`
+	Title       string    `json:"title"`
+	Message     string    `json:"message"`
+	PriorityIn  int       `json:"priority_in"`
+	PriorityOut int       `json:"priority_out,omitempty"`
+	Topic       string    `json:"topic,omitempty"`
+	Result      string    `json:"result"` // failed, expired, dropped or evicted
+	Error       string    `json:"error,omitempty"`
+	Instance    string    `json:"instance,omitempty"`
+
+	// Gotify extras exactly as received, including keys the bridge ignores
+	Extras json.RawMessage `json:"extras,omitempty"`
+}
+
+// FailureHook posts messages that will never reach ntfy to
+// FAILURE_WEBHOOK_URL: forwards that failed for good, buffered messages that
+// failed or expired, and messages dropped from a full stream queue. Posts
+// are queued so a slow webhook never holds up forwarding.
+type FailureHook struct {
+	cfg    *Config
+	client *http.Client
+	queue  chan failurePayload
+}
+
+// NewFailureHook returns nil without FAILURE_WEBHOOK_URL; Fire is a no-op
+// on a nil hook.
+func NewFailureHook(ctx context.Context, cfg *Config) *FailureHook {
+	if cfg.FailureWebhookURL == "" {
+		return nil
+	}
+	h := &FailureHook{
+		cfg:    cfg,
+		client: newHTTPClient(nil, cfg.TorProxy, cfg.UserAgent),
+		queue:  make(chan failurePayload, failureHookQueue),
+	}
+	go h.run(ctx)
+	return h
+}
+
+// Fire reports msg, which ended with ev.
+func (h *FailureHook) Fire(msg GotifyMessage, ev forwardEvent) {
+	if h == nil {
+		return
+	}
+	p := failurePayload{
+		Event:       "forward_failed",
+		Time:        time.Now(),
+		Received:    msg.received,
+		MessageID:   msg.ID,
+		AppID:       msg.AppID,
+		App:         ev.App,
+		Title:       msg.Title,
+		Message:     msg.Message,
+		PriorityIn:  msg.Priority,
+		PriorityOut: ev.Priority,
+		Topic:       ev.Topic,
+		Result:      ev.Result,
+		Error:       ev.Error,
+		Instance:    h.cfg.InstanceName,
+		Extras:      msg.rawExtras,
+	}
+	select {
+	case h.queue <- p:
+	default:
+		log.Printf("[WEBHOOK WARN] queue full, not reporting failed message %d", msg.ID)
+	}
+}
+
+// fireBuffered reports a message from the offline buffer that failed, expired
+// or was evicted.
+func (h *FailureHook) fireBuffered(b *Bridge, m bufferedMsg, result string, err error) {
+	if h == nil {
+		return
+	}
+	// Entries loaded from OFFLINE_BUFFER_FILE carry only the publish
+	msg := GotifyMessage{AppID: m.AppID, Title: m.Publish.Title, Message: m.Publish.Body, received: m.Received}
+	if m.Publish.msg != nil {
+		msg = *m.Publish.msg
+	}
+	h.Fire(msg, bufferedEvent(b, m, result, err))
+}
+
+func (h *FailureHook) run(ctx context.Context) {
+	for p := range h.queue {
+		body, err := json.Marshal(p)
+		if err != nil {
+			log.Printf("[WEBHOOK ERROR] could not encode message %d: %v", p.MessageID, err)
+			continue
+		}
+		if err := h.deliver(ctx, body); err != nil {
+			log.Printf("[WEBHOOK ERROR] could not report failed message %d: %v", p.MessageID, err)
+		}
+	}
+}
+
+// deliver posts body, retrying up to failureHookAttempts times unless ctx
+// is done.
+func (h *FailureHook) deliver(ctx context.Context, body []byte) error {
+	for attempt := 1; ; attempt++ {
+		err := h.post(ctx, body)
+		if err == nil || attempt == failureHookAttempts {
+			return err
+		}
+		select {
+		case <-time.After(time.Duration(attempt) * 2 * time.Second):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+func (h *FailureHook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.cfg.FailureWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
@@ -5,4 +5,5 @@ go 1.26.5
 require (
 	github.com/gorilla/websocket v1.5.3
 	github.com/joho/godotenv v1.5.1
+	golang.org/x/sys v0.48.0
 )
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// GotifyClient is a Gotify client as returned by GET/POST /client.
+type GotifyClient struct {
+	ID    int64  `json:"id"`
+	Token string `json:"token"`
+	Name  string `json:"name"`
+}
+
+// loginClientToken obtains a client token using GOTIFY_USER/GOTIFY_PASSWORD.
+// An existing client named cfg.GotifyClientName is reused so restarts do not
+// pile up new clients in Gotify; otherwise one is created.
+func loginClientToken(ctx context.Context, cfg *Config) (string, error) {
+	client := cfg.GotifyHTTP()
+
+	clientURL, err := gotifyAPIURL(cfg, "/client")
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, clientURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(cfg.GotifyUser, cfg.GotifyPassword)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Gotify GET /client failed: %s", resp.Status)
+	}
+
+	var clients []GotifyClient
+	if err := json.NewDecoder(resp.Body).Decode(&clients); err != nil {
+		return "", err
+	}
+	for _, c := range clients {
+		if c.Name == cfg.GotifyClientName && c.Token != "" {
+			dbg(cfg, "Reusing Gotify client %q (ID=%d)", c.Name, c.ID)
+			return c.Token, nil
+		}
+	}
+
+	body, err := json.Marshal(map[string]string{"name": cfg.GotifyClientName})
+	if err != nil {
+		return "", err
+	}
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, clientURL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(cfg.GotifyUser, cfg.GotifyPassword)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Gotify POST /client failed: %s", resp.Status)
+	}
+
+	var created GotifyClient
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", err
+	}
+	if created.Token == "" {
+		return "", fmt.Errorf("Gotify POST /client returned no token")
+	}
+	log.Printf("Created Gotify client %q (ID=%d)", created.Name, created.ID)
+	return created.Token, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// GotifyMonitor alerts the error topic once Gotify has been unreachable for
+// GOTIFY_DOWN_ATTEMPTS consecutive connection attempts or GOTIFY_DOWN_AFTER,
+// and again when the stream is back.
+type GotifyMonitor struct {
+	mu       sync.Mutex
+	failures int
+	since    time.Time // start of the current outage, zero while connected
+	alerted  bool
+}
+
+func NewGotifyMonitor() *GotifyMonitor {
+	return &GotifyMonitor{}
+}
+
+// Failed records a failed connection attempt.
+func (m *GotifyMonitor) Failed(b *Bridge, err error) {
+	cfg := b.cfg
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures++
+	if m.since.IsZero() {
+		m.since = time.Now()
+		// A connection that just dropped has been down since then
+		if connected, changed, ever := b.stats.ConnectionState(); !connected && ever && changed.Before(m.since) {
+			m.since = changed
+		}
+	}
+	down := time.Since(m.since)
+	due := (cfg.GotifyDownAttempts > 0 && m.failures >= cfg.GotifyDownAttempts) ||
+		(cfg.GotifyDownAfter > 0 && down >= cfg.GotifyDownAfter)
+	if m.alerted || !due {
+		return
+	}
+
+	log.Printf("[GOTIFY WARN] unreachable since %s (%d failed attempts)", m.since.Format("15:04:05"), m.failures)
+	body := fmt.Sprintf("Gotify connection down since %s (%s, %d failed attempts).\nURL: %s\nLast error: %v\n"+
+		"No messages are forwarded until the bridge reconnects.",
+		m.since.Format("2006-01-02 15:04:05 MST"), formatDuration(down.Round(time.Second)), m.failures,
+		redactURL(cfg.ActiveGotifyURL()), err)
+	if err := sendNtfy(b.ctx, cfg, cfg.NtfyErrorTopic, "Gotify unreachable", body, 8); err != nil {
+		log.Printf("[NTFY ERROR] failed to send Gotify down alert: %v", err)
+		return
+	}
+	m.alerted = true
+}
+
+// Connected ends the current outage, announcing the recovery if it was alerted.
+func (m *GotifyMonitor) Connected(b *Bridge) {
+	cfg := b.cfg
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.alerted {
+		body := fmt.Sprintf("Gotify connection restored after %s (%d failed attempts).",
+			formatDuration(time.Since(m.since).Round(time.Second)), m.failures)
+		log.Printf("[GOTIFY] connection restored after %d failed attempts", m.failures)
+		if err := sendNtfy(b.ctx, cfg, cfg.NtfyErrorTopic, "Gotify reachable again", body, 5); err != nil {
+			log.Printf("[NTFY ERROR] failed to send Gotify recovery notice: %v", err)
+		}
+	}
+	m.failures, m.since, m.alerted = 0, time.Time{}, false
+}
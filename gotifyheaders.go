@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// gotifyHeaderFields maps NTFY_GOTIFY_HEADERS field names to the header
+// carrying them on the ntfy publish.
+var gotifyHeaderFields = map[string]string{
+	"app_id":     "X-Gotify-App-Id",
+	"app":        "X-Gotify-App",
+	"message_id": "X-Gotify-Message-Id",
+	"priority":   "X-Gotify-Priority",
+}
+
+// parseGotifyHeaders parses NTFY_GOTIFY_HEADERS, a comma-separated list of
+// gotifyHeaderFields keys.
+func parseGotifyHeaders(s string) ([]string, error) {
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" {
+			continue
+		}
+		if _, ok := gotifyHeaderFields[f]; !ok {
+			return nil, fmt.Errorf("unknown field %q (want app_id, app, message_id or priority)", f)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// gotifyHeaders returns the configured X-Gotify-* provenance headers for msg.
+// The priority is the one Gotify sent, before NTFY_PRIORITY and profiles.
+func gotifyHeaders(cfg *Config, msg GotifyMessage, app GotifyApp) http.Header {
+	if len(cfg.GotifyHeaders) == 0 {
+		return nil
+	}
+	h := http.Header{}
+	for _, f := range cfg.GotifyHeaders {
+		var v string
+		switch f {
+		case "app_id":
+			v = strconv.FormatInt(msg.AppID, 10)
+		case "app":
+			v = app.Name
+		case "message_id":
+			v = strconv.FormatInt(msg.ID, 10)
+		case "priority":
+			v = strconv.Itoa(msg.Priority)
+		}
+		if v != "" {
+			h.Set(gotifyHeaderFields[f], v)
+		}
+	}
+	return h
+}
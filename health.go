@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// readiness reports whether the bridge is receiving from Gotify: connected
+// and heard from (message or ping) within cfg.ReadyThreshold. A disconnect
+// shorter than the threshold is tolerated so a routine reconnect does not
+// flip the probe. Sustained ntfy publish failures also make it unready.
+func readiness(b *Bridge) (bool, string) {
+	if down, reason := b.ntfyDown.Down(); down {
+		return false, reason
+	}
+	threshold := b.cfg.ReadyThreshold
+	connected, since, ever := b.stats.ConnectionState()
+	if !ever {
+		return false, "not connected to Gotify yet"
+	}
+	if !connected {
+		if down := time.Since(since); down > threshold {
+			return false, fmt.Sprintf("Gotify disconnected for %s", down.Round(time.Second))
+		}
+		return true, ""
+	}
+	if silent := time.Since(time.Unix(0, b.lastRead.Load())); silent > threshold {
+		return false, fmt.Sprintf("nothing read from Gotify for %s", silent.Round(time.Second))
+	}
+	return true, ""
+}
+
+// writeJSON writes v as an indented JSON response.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(v)
+}
+
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func handleReadyz(b *Bridge) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ok, reason := readiness(b); !ok {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "not ready", "reason": reason})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// heartbeatMessage renders the heartbeat with the number of messages
+// forwarded since the previous one.
+func heartbeatMessage(cfg *Config, snap StatsSnapshot, forwarded int64) (title, body string) {
+	title = "Bridge alive"
+	if cfg.InstanceName != "" {
+		title += ": " + cfg.InstanceName
+	}
+	connection := "connected to Gotify"
+	if !snap.Connected {
+		connection = "NOT connected to Gotify"
+	}
+	body = fmt.Sprintf("%d messages forwarded since the last heartbeat.\nUp %s, %s.",
+		forwarded, formatDuration(time.Since(snap.Started).Round(time.Minute)), connection)
+	return title, body
+}
+
+// runHeartbeat publishes a low-priority sign of life to HEARTBEAT_TOPIC every
+// HEARTBEAT_INTERVAL, so a bridge on flaky hardware is known to be running.
+func runHeartbeat(b *Bridge) {
+	cfg := b.cfg
+	ticker := time.NewTicker(cfg.HeartbeatInterval)
+	defer ticker.Stop()
+
+	last := b.stats.Snapshot(b.store).Forwarded
+	for range ticker.C {
+		snap := b.stats.Snapshot(b.store)
+		title, body := heartbeatMessage(cfg, snap, snap.Forwarded-last)
+		if err := sendNtfy(b.ctx, cfg, cfg.HeartbeatTopic, title, body, 2); err != nil {
+			log.Printf("[HEARTBEAT ERROR] failed to send heartbeat to %s: %v", cfg.HeartbeatTopic, err)
+			continue
+		}
+		dbg(cfg, "[HEARTBEAT] Sent heartbeat to %s", cfg.HeartbeatTopic)
+		last = snap.Forwarded
+	}
+}
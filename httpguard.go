@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// parseCIDRs parses a comma-separated list of CIDRs; bare addresses are
+// taken as single hosts.
+func parseCIDRs(s string) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if !strings.Contains(f, "/") {
+			addr, err := netip.ParseAddr(f)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(f)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p.Masked())
+	}
+	return out, nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the HTTP client. Behind one of the trusted
+// proxies, the rightmost X-Forwarded-For entry not added by a trusted proxy
+// is used instead of the connection's address.
+func clientIP(r *http.Request, trusted []netip.Prefix) (netip.Addr, error) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid remote address %q", r.RemoteAddr)
+	}
+	addr = addr.Unmap()
+	if !containsAddr(trusted, addr) {
+		return addr, nil
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+		if !containsAddr(trusted, addr) {
+			break
+		}
+	}
+	return addr, nil
+}
+
+// rateLimiter is a token bucket per client address.
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens per second
+	burst   float64
+	buckets map[netip.Addr]*tokenBucket
+	swept   time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(perMinute, burst int) *rateLimiter {
+	return &rateLimiter{rate: float64(perMinute) / 60, burst: float64(burst), buckets: make(map[netip.Addr]*tokenBucket)}
+}
+
+func (l *rateLimiter) Allow(addr netip.Addr) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+
+	// Forget clients whose bucket has refilled completely
+	if now.Sub(l.swept) > time.Minute {
+		for a, b := range l.buckets {
+			if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+				delete(l.buckets, a)
+			}
+		}
+		l.swept = now
+	}
+
+	b, ok := l.buckets[addr]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[addr] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// guardHTTP applies HTTP_ALLOW_CIDRS and HTTP_RATE_LIMIT to every request
+// of the bridge's HTTP server.
+func guardHTTP(cfg *Config, next http.Handler) http.Handler {
+	if len(cfg.HTTPAllowCIDRs) == 0 && cfg.HTTPRateLimit == 0 {
+		return next
+	}
+	var limiter *rateLimiter
+	if cfg.HTTPRateLimit > 0 {
+		limiter = newRateLimiter(cfg.HTTPRateLimit, cfg.HTTPRateBurst)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addr, err := clientIP(r, cfg.HTTPTrustedProxies)
+		if err != nil {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if len(cfg.HTTPAllowCIDRs) > 0 && !containsAddr(cfg.HTTPAllowCIDRs, addr) {
+			dbg(cfg, "[HTTP] Rejected %s %s from %s: not in HTTP_ALLOW_CIDRS", r.Method, r.URL.Path, addr)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if limiter != nil && !limiter.Allow(addr) {
+			log.Printf("[HTTP WARN] rate limit exceeded by %s (%s %s)", addr, r.Method, r.URL.Path)
+			w.Header().Set("Retry-After", "60")
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	iconCacheTTL = 6 * time.Hour
+	iconMaxBytes = 1 << 20
+)
+
+type cachedIcon struct {
+	ContentType string
+	Data        []byte
+	Fetched     time.Time
+}
+
+// IconCache keeps Gotify app images in memory so /icons/{appID} can serve
+// them without exposing Gotify or its token to ntfy clients.
+type IconCache struct {
+	mu    sync.Mutex
+	icons map[int64]cachedIcon
+}
+
+func NewIconCache() *IconCache {
+	return &IconCache{icons: make(map[int64]cachedIcon)}
+}
+
+// Get returns the image of app, fetching it from Gotify when missing or stale.
+// A stale copy is served if Gotify cannot be reached.
+func (c *IconCache) Get(ctx context.Context, cfg *Config, app GotifyApp) (cachedIcon, error) {
+	c.mu.Lock()
+	icon, ok := c.icons[app.ID]
+	c.mu.Unlock()
+	if ok && time.Since(icon.Fetched) < iconCacheTTL {
+		return icon, nil
+	}
+
+	fresh, err := fetchIcon(ctx, cfg, app)
+	if err != nil {
+		if ok {
+			log.Printf("[ICON ERROR] could not refresh icon of app %d, serving cached copy: %v", app.ID, err)
+			return icon, nil
+		}
+		return cachedIcon{}, err
+	}
+	c.mu.Lock()
+	c.icons[app.ID] = fresh
+	c.mu.Unlock()
+	return fresh, nil
+}
+
+func fetchIcon(ctx context.Context, cfg *Config, app GotifyApp) (cachedIcon, error) {
+	if app.Image == "" {
+		return cachedIcon{}, fmt.Errorf("app %d has no image", app.ID)
+	}
+	iconURL, err := gotifyAPIURL(cfg, "/"+app.Image)
+	if err != nil {
+		return cachedIcon{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, iconURL, nil)
+	if err != nil {
+		return cachedIcon{}, err
+	}
+	req.Header.Set("X-Gotify-Key", cfg.GotifyToken)
+
+	resp, err := cfg.GotifyHTTP().Do(req)
+	if err != nil {
+		return cachedIcon{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return cachedIcon{}, fmt.Errorf("gotify returned %s for %s", resp.Status, app.Image)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, iconMaxBytes+1))
+	if err != nil {
+		return cachedIcon{}, err
+	}
+	if len(data) > iconMaxBytes {
+		return cachedIcon{}, fmt.Errorf("icon of app %d exceeds %d bytes", app.ID, iconMaxBytes)
+	}
+	ct := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(ct, "image/") {
+		ct = http.DetectContentType(data)
+	}
+	if !strings.HasPrefix(ct, "image/") {
+		return cachedIcon{}, fmt.Errorf("icon of app %d is %s, not an image", app.ID, ct)
+	}
+	return cachedIcon{ContentType: ct, Data: data, Fetched: time.Now()}, nil
+}
+
+// appIconURL is the public URL of appID's icon, or "" when icons are disabled.
+func appIconURL(cfg *Config, appID int64) string {
+	if !cfg.AppIcons || cfg.PublicURL == "" || cfg.HTTPListen == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/icons/%d", strings.TrimRight(cfg.PublicURL, "/"), appID)
+}
+
+func handleIcon(b *Bridge) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appID, err := strconv.ParseInt(r.PathValue("appID"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid app id", http.StatusBadRequest)
+			return
+		}
+		app, ok := b.store.Get(appID)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		icon, err := b.icons.Get(r.Context(), b.cfg, app)
+		if err != nil {
+			log.Printf("[ICON ERROR] %v", err)
+			http.Error(w, "icon unavailable", http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", icon.ContentType)
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		_, _ = w.Write(icon.Data)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// parseLogLevel maps LOG_LEVEL values to slog levels.
+func parseLogLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("invalid LOG_LEVEL %q, expected debug, info, warn or error", s)
+}
+
+// setupLogging routes the log package and slog through one handler writing
+// to stderr and, if configured, the rotated LOG_FILE. The handlers mask
+// secrets, see maskSecrets.
+func setupLogging(cfg *Config) error {
+	var w io.Writer = os.Stderr
+	if cfg.LogFile != "" {
+		rf, err := openRotatingFile(cfg.LogFile, cfg.LogMaxSize, cfg.LogMaxBackups)
+		if err != nil {
+			return fmt.Errorf("could not open LOG_FILE: %w", err)
+		}
+		w = io.MultiWriter(os.Stderr, rf)
+	}
+
+	opts := &slog.HandlerOptions{Level: cfg.LogLevel}
+	var h slog.Handler
+	switch cfg.LogFormat {
+	case "json":
+		h = &taggedHandler{slog.NewJSONHandler(w, opts)}
+	case "logfmt":
+		h = &taggedHandler{slog.NewTextHandler(w, opts)}
+	default:
+		h = &classicHandler{mu: &sync.Mutex{}, w: w, level: cfg.LogLevel}
+	}
+	registerConfigSecrets(cfg)
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// logTagRe matches the "[SYNC ERROR] " style prefixes of log.Printf messages.
+var logTagRe = regexp.MustCompile(`^\[([A-Za-z][A-Za-z0-9 ]*)\] ?`)
+
+// taggedHandler turns the bracketed prefix of log.Printf messages into a
+// level and a component attribute, so they are structured in JSON and
+// logfmt output like the slog calls.
+type taggedHandler struct {
+	slog.Handler
+}
+
+// Enabled lets every info record through: the log package logs at info, the
+// real level is only known once the prefix is parsed in Handle.
+func (h *taggedHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return l == slog.LevelInfo || h.Handler.Enabled(ctx, l)
+}
+
+func (h *taggedHandler) Handle(ctx context.Context, r slog.Record) error {
+	msg, level := r.Message, r.Level
+	var component string
+	if m := logTagRe.FindStringSubmatch(msg); m != nil {
+		msg = msg[len(m[0]):]
+		tag := strings.ToLower(m[1])
+		switch {
+		case strings.HasSuffix(tag, "error"):
+			level, tag = max(level, slog.LevelError), strings.TrimSpace(strings.TrimSuffix(tag, "error"))
+		case strings.HasSuffix(tag, "warn"):
+			level, tag = max(level, slog.LevelWarn), strings.TrimSpace(strings.TrimSuffix(tag, "warn"))
+		case tag == "debug":
+			level, tag = slog.LevelDebug, ""
+		}
+		component = strings.ReplaceAll(tag, " ", "_")
+	}
+	if !h.Handler.Enabled(ctx, level) {
+		return nil
+	}
+
+	out := slog.NewRecord(r.Time, level, maskSecrets(strings.TrimRight(msg, "\n")), r.PC)
+	if component != "" {
+		out.AddAttrs(slog.String("component", component))
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(maskAttr(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, out)
+}
+
+func (h *taggedHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	masked := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		masked[i] = maskAttr(a)
+	}
+	return &taggedHandler{h.Handler.WithAttrs(masked)}
+}
+
+func (h *taggedHandler) WithGroup(name string) slog.Handler {
+	return &taggedHandler{h.Handler.WithGroup(name)}
+}
+
+// classicHandler keeps the traditional "2006/01/02 15:04:05 [TAG] message"
+// format, appending attributes as key=value pairs.
+type classicHandler struct {
+	mu    *sync.Mutex
+	w     io.Writer
+	level slog.Level
+	attrs []slog.Attr
+}
+
+func (h *classicHandler) Enabled(_ context.Context, l slog.Level) bool {
+	// Prefixed log.Printf messages are always shown, as before
+	return l >= h.level || l == slog.LevelInfo
+}
+
+func (h *classicHandler) Handle(_ context.Context, r slog.Record) error {
+	tagged := strings.HasPrefix(r.Message, "[")
+	level := r.Level
+	if level == slog.LevelInfo && tagged && h.level > slog.LevelInfo {
+		// Only let tagged warnings and errors pass a raised LOG_LEVEL
+		if m := logTagRe.FindStringSubmatch(r.Message); m != nil {
+			switch tag := strings.ToLower(m[1]); {
+			case strings.HasSuffix(tag, "error"):
+				level = slog.LevelError
+			case strings.HasSuffix(tag, "warn"):
+				level = slog.LevelWarn
+			}
+		}
+	}
+	if level < h.level {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(r.Time.Format("2006/01/02 15:04:05 "))
+	switch {
+	case r.Level < slog.LevelInfo:
+		buf.WriteString("[DEBUG] ")
+	case r.Level >= slog.LevelError && !tagged:
+		buf.WriteString("[ERROR] ")
+	case r.Level >= slog.LevelWarn && !tagged:
+		buf.WriteString("[WARN] ")
+	}
+	buf.WriteString(maskSecrets(strings.TrimRight(r.Message, "\n")))
+	write := func(a slog.Attr) bool {
+		a = maskAttr(a)
+		v := a.Value.String()
+		if a.Value.Kind() == slog.KindTime {
+			v = a.Value.Time().Format(time.RFC3339)
+		}
+		if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+			v = strconv.Quote(v)
+		}
+		fmt.Fprintf(&buf, " %s=%s", a.Key, v)
+		return true
+	}
+	for _, a := range h.attrs {
+		write(a)
+	}
+	r.Attrs(write)
+	buf.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(buf.Bytes())
+	return err
+}
+
+func (h *classicHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.attrs = append(append([]slog.Attr(nil), h.attrs...), attrs...)
+	return &c
+}
+
+// WithGroup is not used by the bridge; groups are flattened.
+func (h *classicHandler) WithGroup(string) slog.Handler { return h }
@@ -2,19 +2,32 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"math"
+	"mime"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"os"
+	"os/signal"
 	"path"
 	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"text/template"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -31,11 +44,18 @@ type GotifyApp struct {
 
 // Gotify message struct (simplified)
 type GotifyMessage struct {
-	ID       int64  `json:"id"`
-	AppID    int64  `json:"appid"`
-	Title    string `json:"title"`
-	Message  string `json:"message"`
-	Priority int    `json:"priority"`
+	ID       int64          `json:"id"`
+	AppID    int64          `json:"appid"`
+	Title    string         `json:"title"`
+	Message  string         `json:"message"`
+	Priority int            `json:"priority"`
+	Extras   map[string]any `json:"extras,omitempty"`
+
+	rawExtras json.RawMessage // extras exactly as received, for JSON outputs
+	received  time.Time       // read off the stream
+	walSeq    uint64          // WAL record, 0 without WAL_FILE
+	span      *Span           // whole pipeline, nil unless tracing
+	queueSpan *Span           // waiting for a worker
 }
 
 type AppStore struct {
@@ -64,44 +84,328 @@ type AppStore struct {
 // It includes server URLs, authentication tokens, database path, and synchronization preferences.
 type Config struct {
 	GotifyURL     string
+	GotifyURLs    []string
+	GotifyAPIURL  string
 	GotifyToken   string
 	NtfyURL       string
 	NtfyTopic     string
 	NtfyAuthToken string
 	NtfyPriority  int
 	SplitTopics   bool
+	UnknownApps   string // NTFY_UNKNOWN_APPS, see unknownAppTopic
 	SyncInterval  time.Duration
 	Debug         bool
 	Timezone      string
 	AppsDBPath    string
+	SLADBPath     string
+	StateDBPath   string // GOTIFY_STATE_DB, last message IDs forwarded
+
+	GotifyUser       string
+	GotifyPassword   string
+	GotifyClientName string
+
+	StatusDir      string
+	StatusInterval time.Duration
+
+	HTTPListen     string
+	PublicURL      string // how phones reach HTTPListen, used for ntfy actions
+	SnoozeDuration time.Duration
+	SnoozeSecret   string
+	AppIcons       bool // set ntfy Icon to the bridge's /icons/{appID} proxy
+	ReadyThreshold time.Duration
+	Pprof          bool // mount net/http/pprof under /debug/pprof/
+	MessageTTL     time.Duration
+
+	HTTPAllowCIDRs     []netip.Prefix
+	HTTPTrustedProxies []netip.Prefix
+	HTTPRateLimit      int // requests per minute and client, 0 disables
+	HTTPRateBurst      int
+
+	AdminToken    string // bearer token for the admin API and web UI
+	AdminUser     string // basic auth alternative to AdminToken
+	AdminPassword string
+
+	AuditLogFile string // JSON lines of admin actions
+	AuditNotify  bool
+
+	MessageLogFile    string // JSON lines of forwarded messages, see messagelog.go
+	MessageLogMaxSize int64  // bytes before rotating, 0 never rotates
+	MessageLogBackups int    // rotated files kept
+
+	FailureWebhookURL string // receives messages that permanently failed, see failurehook.go
+	DeadLetterTopic   string // ntfy topic noting messages that permanently failed, see deadletter.go
+	WALFile           string // write-ahead log of messages in flight, see wal.go
+
+	NtfyAdminTopic   string // bridge-generated notifications; defaults to NtfyTopic
+	NtfyErrorTopic   string // bridge errors and outages; defaults to NtfyAdminTopic
+	WeeklyReport     bool
+	WeeklyReportDay  time.Weekday
+	WeeklyReportTime string // HH:MM, local time
+	ReportDBPath     string
+
+	DailyDigest     bool
+	DailyDigestTime string // HH:MM, local time
+	ResourceMonitor bool   // hourly goroutine, heap and FD samples, see stability.go
+
+	HeartbeatInterval time.Duration // 0 disables
+	HeartbeatTopic    string        // defaults to NtfyAdminTopic
+	ControlTopic      string        // commands such as "status", see control.go
+	StaleForwardAfter time.Duration // alert when connected but idle this long, 0 disables
+
+	GotifyFailbackInterval time.Duration
+	MaxReconnectAttempts   int // consecutive failed dials before exiting; 0 retries forever
+	BackfillMax            int // messages fetched via REST after a reconnect, 0 disables
+	GotifyDownAttempts     int // failed dials before alerting the error topic, 0 disables
+	GotifyDownAfter        time.Duration
+	NtfyDownAttempts       int // failed publishes before ntfy counts as down, 0 disables
+	NtfyCircuitCooldown    time.Duration
+	NtfyRetryMax           int // retries of a publish after a transient error, see ntfyretry.go
+	NtfyRetryBaseDelay     time.Duration
+	NtfyRetryJitter        float64 // share of the delay, 0–1
+	ShutdownTimeout        time.Duration
+	ForwardTimeout         time.Duration // per message, including retries; on expiry it is buffered
+	ShutdownFlushTimeout   time.Duration // keep retrying the offline buffer on exit; 0 gives up right away
+
+	DryRun      bool
+	ObserveOnly bool // connect and record everything, but never publish to ntfy
+
+	DedupWindow int
+	DedupSent   int // message IDs remembered before publishing, see SentIDs
+
+	OfflineBufferSize    int
+	OfflineRetryInterval time.Duration
+	OfflineAnnotateAfter time.Duration
+	OfflineRetryMax      time.Duration // backoff cap while ntfy stays unreachable
+	OfflineBufferFile    string        // keeps the buffer across restarts, see bufferstore.go
+
+	Metered              bool
+	MeteredMaxBody       int
+	MeteredBatchInterval time.Duration
+
+	ProfilesFile  string
+	SchedulesFile string
+	TopicRules    string
+
+	TitleTemplate string // text/template for the ntfy title, profiles can override it
+	BodyTemplate  string
+
+	TopicRulesCandidate string // evaluated next to TopicRules and logged where it differs, see rulesets.go
+
+	GotifyHeaders []string // Gotify fields passed to ntfy as X-Gotify-* headers
+
+	ExpectRules []expectRule // EXPECT_MESSAGES, see deadman.go
+
+	PriorityMapping    string // linear, passthrough, banded or expression, see priomap.go
+	PriorityBands      string
+	PriorityExpression string
+	ZeroPriority       string // PRIORITY_ZERO: default, min or drop
+
+	TopicAnnounce     bool   // introduce each split topic with a one-time message, see announce.go
+	TopicAnnounceURL  string // ntfy URL shown in the subscribe instructions
+	AnnouncedTopicsDB string
+
+	CriticalRule    string
+	CriticalTimeout time.Duration
+
+	SlowForwardThreshold time.Duration
+
+	LogFile       string
+	LogMaxSize    int64 // bytes
+	LogMaxBackups int
+	LogLevel      slog.Level
+	LogFormat     string // text, json or logfmt
+
+	UnsafeLogSecrets bool // log tokens and passwords unmasked, for development only
+
+	Attachments        string // off, link or upload
+	AttachmentMaxBytes int64
+	AttachmentTypes    []string
+	AttachmentOnLimit  string // link, drop or compress
+
+	AttachmentMaxDimension int // downscale uploaded images to fit; 0 keeps their size
+	AttachmentJPEGQuality  int
+
+	Preflight       bool
+	PreflightStrict bool
+
+	OTLPEndpoint    string // OTLP/HTTP traces URL, empty disables tracing
+	OTLPServiceName string
+
+	TraceSampleRate float64  // share of messages whose spans are logged, 0-1
+	TraceApps       []string // app names or IDs whose messages are always logged
+
+	InstanceName string // identifies this bridge in the User-Agent
+	UserAgent    string // sent on every outbound request and websocket dial
+
+	GotifyProxy *url.URL
+	NtfyProxy   *url.URL
+	TorProxy    *url.URL // used for .onion hosts without an explicit proxy
+
+	envFiles     []string
+	rules        atomic.Pointer[ruleSets]
+	criticalCond topicCond
+	prioMapper   priorityMapping
+	otlpHeaders  http.Header
+	profiles     *ProfileSet
+	titleTpl     *template.Template
+	bodyTpl      *template.Template
+	schedules    []*Schedule
+	gotifyActive atomic.Int32 // index into GotifyURLs of the URL in use
+	gotifyHTTP   *http.Client
+	ntfyHTTP     *http.Client
+	gotifyDialer *websocket.Dialer
 }
 
-func loadConfig() (*Config, error) {
-	// load .env into environment (only if present)
-	_ = godotenv.Load()
+// loadEnvFiles loads the given env files in order, later files overriding
+// earlier ones. Variables already set in the process environment always win.
+// Without files, an optional .env in the working directory is loaded.
+func loadEnvFiles(files []string) error {
+	if len(files) == 0 {
+		// load .env into environment (only if present)
+		_ = godotenv.Load()
+		return nil
+	}
+
+	merged := make(map[string]string)
+	for _, f := range files {
+		vars, err := godotenv.Read(f)
+		if err != nil {
+			return fmt.Errorf("could not read env file %s: %w", f, err)
+		}
+		for k, v := range vars {
+			merged[k] = v
+		}
+	}
+	for k, v := range merged {
+		if _, set := os.LookupEnv(k); !set {
+			_ = os.Setenv(k, v)
+		}
+	}
+	return nil
+}
+
+func loadConfig(envFiles []string) (*Config, error) {
+	if err := loadEnvFiles(envFiles); err != nil {
+		return nil, err
+	}
+	deprecated := applyDeprecations()
 
 	cfg := &Config{
+		envFiles:      envFiles,
 		GotifyURL:     os.Getenv("GOTIFY_URL"),
+		GotifyAPIURL:  os.Getenv("GOTIFY_API_URL"),
 		GotifyToken:   os.Getenv("GOTIFY_CLIENT_TOKEN"),
 		NtfyURL:       os.Getenv("NTFY_URL"),
 		NtfyTopic:     os.Getenv("NTFY_TOPIC"),
 		NtfyAuthToken: os.Getenv("NTFY_AUTH_TOKEN"),
 		Timezone:      os.Getenv("TZ"),
 		AppsDBPath:    os.Getenv("GOTIFY_APPS_DB"),
+		SLADBPath:     os.Getenv("SLA_DB"),
+		StateDBPath:   os.Getenv("GOTIFY_STATE_DB"),
+		StatusDir:     os.Getenv("STATUS_DIR"),
+		HTTPListen:    os.Getenv("HTTP_LISTEN"),
+		PublicURL:     os.Getenv("BRIDGE_PUBLIC_URL"),
+	}
+
+	// Set up logging first so the messages below use the configured format and file
+	cfg.LogFile = os.Getenv("LOG_FILE")
+	if mb, err := strconv.Atoi(os.Getenv("LOG_MAX_SIZE")); err == nil && mb > 0 {
+		cfg.LogMaxSize = int64(mb) << 20
+	} else {
+		cfg.LogMaxSize = 10 << 20
+	}
+	if n, err := strconv.Atoi(os.Getenv("LOG_MAX_BACKUPS")); err == nil && n >= 0 {
+		cfg.LogMaxBackups = n
+	} else {
+		cfg.LogMaxBackups = 3
+	}
+	level, levelErr := parseLogLevel(os.Getenv("LOG_LEVEL"))
+	if levelErr != nil {
+		return nil, levelErr
+	}
+	cfg.LogLevel, cfg.Debug = level, level == slog.LevelDebug
+	cfg.LogFormat = strings.ToLower(os.Getenv("LOG_FORMAT"))
+	switch cfg.LogFormat {
+	case "":
+		cfg.LogFormat = "text"
+	case "text", "json", "logfmt":
+	default:
+		return nil, fmt.Errorf("invalid LOG_FORMAT %q, expected text, json or logfmt", cfg.LogFormat)
+	}
+	cfg.UnsafeLogSecrets = strings.ToLower(os.Getenv("UNSAFE_LOG_SECRETS")) == "true"
+	if err := setupLogging(cfg); err != nil {
+		return nil, err
+	}
+	if cfg.UnsafeLogSecrets {
+		log.Printf("[SECURITY WARN] UNSAFE_LOG_SECRETS=true: tokens and passwords are logged unmasked, never use this in production")
+	}
+	for _, w := range deprecated {
+		log.Printf("[DEPRECATED] %s", w)
+	}
+
+	// GOTIFY_URL may list several comma-separated URLs for the same server;
+	// the first one is the primary, the rest are failover paths.
+	appendStream := strings.ToLower(os.Getenv("GOTIFY_APPEND_STREAM")) != "false"
+	for _, u := range strings.Split(cfg.GotifyURL, ",") {
+		if u = strings.TrimSpace(u); u == "" {
+			continue
+		}
+		if appendStream {
+			if fixed, changed := withStreamPath(u); changed {
+				log.Printf("GOTIFY_URL %s does not end in /stream, using %s (set GOTIFY_APPEND_STREAM=false to disable)", u, fixed)
+				u = fixed
+			}
+		}
+		cfg.GotifyURLs = append(cfg.GotifyURLs, u)
+	}
+	if len(cfg.GotifyURLs) > 0 {
+		cfg.GotifyURL = cfg.GotifyURLs[0]
+	}
+	if interval, err := strconv.Atoi(os.Getenv("GOTIFY_FAILBACK_INTERVAL")); err == nil && interval > 0 {
+		cfg.GotifyFailbackInterval = time.Duration(interval) * time.Second
+	} else {
+		cfg.GotifyFailbackInterval = time.Minute
+	}
+	if n, err := strconv.Atoi(os.Getenv("GOTIFY_BACKFILL_MAX")); err == nil && n >= 0 {
+		cfg.BackfillMax = n
+	} else {
+		cfg.BackfillMax = 100
+	}
+
+	cfg.GotifyUser = os.Getenv("GOTIFY_USER")
+	cfg.GotifyPassword = os.Getenv("GOTIFY_PASSWORD")
+	cfg.GotifyClientName = os.Getenv("GOTIFY_CLIENT_NAME")
+	if cfg.GotifyClientName == "" {
+		cfg.GotifyClientName = "gotify-to-ntfy-push"
 	}
 
 	if cfg.AppsDBPath == "" {
 		cfg.AppsDBPath = "apps_db.json"
 	}
+	if cfg.SLADBPath == "" {
+		cfg.SLADBPath = "sla_db.json"
+	}
+	if cfg.StateDBPath == "" {
+		cfg.StateDBPath = "gotify_state.json"
+	}
 
 	cfg.SplitTopics = strings.ToLower(os.Getenv("NTFY_SPLIT_TOPICS")) == "true"
+	unknownApps, unknownErr := parseUnknownApps(os.Getenv("NTFY_UNKNOWN_APPS"), cfg.NtfyTopic)
+	if unknownErr != nil {
+		return nil, unknownErr
+	}
+	cfg.UnknownApps = unknownApps
 	if interval, err := strconv.Atoi(os.Getenv("NTFY_SYNC_INTERVAL")); err == nil {
 		cfg.SyncInterval = time.Duration(interval) * time.Second
 	} else {
 		cfg.SyncInterval = 5 * time.Minute
 	}
 
-	cfg.Debug = strings.ToLower(os.Getenv("NTFY_DEBUG")) == "true"
+	if interval, err := strconv.Atoi(os.Getenv("STATUS_INTERVAL")); err == nil && interval > 0 {
+		cfg.StatusInterval = time.Duration(interval) * time.Second
+	} else {
+		cfg.StatusInterval = time.Minute
+	}
 
 	dbg(cfg, "Using SplitTopics: %t", cfg.SplitTopics)
 	if cfg.NtfyAuthToken != "" {
@@ -114,17 +418,474 @@ func loadConfig() (*Config, error) {
 		cfg.NtfyPriority = 3
 	}
 
+	if n, err := strconv.Atoi(os.Getenv("MAX_RECONNECT_ATTEMPTS")); err == nil && n > 0 {
+		cfg.MaxReconnectAttempts = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("GOTIFY_DOWN_ATTEMPTS")); err == nil && n >= 0 {
+		cfg.GotifyDownAttempts = n
+	} else {
+		cfg.GotifyDownAttempts = 5
+	}
+	if after, err := strconv.Atoi(os.Getenv("GOTIFY_DOWN_AFTER")); err == nil && after >= 0 {
+		cfg.GotifyDownAfter = time.Duration(after) * time.Second
+	} else {
+		cfg.GotifyDownAfter = 5 * time.Minute
+	}
+	if n, err := strconv.Atoi(os.Getenv("NTFY_DOWN_ATTEMPTS")); err == nil && n >= 0 {
+		cfg.NtfyDownAttempts = n
+	} else {
+		cfg.NtfyDownAttempts = 5
+	}
+	cfg.NtfyCircuitCooldown = 30 * time.Second
+	if v := os.Getenv("NTFY_CIRCUIT_COOLDOWN"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid NTFY_CIRCUIT_COOLDOWN %q, expected e.g. 30s (0 disables)", v)
+		}
+		cfg.NtfyCircuitCooldown = d
+	}
+	if n, err := strconv.Atoi(os.Getenv("NTFY_RETRY_MAX")); err == nil && n >= 0 {
+		cfg.NtfyRetryMax = n
+	} else {
+		cfg.NtfyRetryMax = 2
+	}
+	cfg.NtfyRetryBaseDelay = 500 * time.Millisecond
+	if v := os.Getenv("NTFY_RETRY_BASE_DELAY"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid NTFY_RETRY_BASE_DELAY %q, expected e.g. 500ms", v)
+		}
+		cfg.NtfyRetryBaseDelay = d
+	}
+	cfg.NtfyRetryJitter = 0.2
+	if v := os.Getenv("NTFY_RETRY_JITTER"); v != "" {
+		j, err := strconv.ParseFloat(v, 64)
+		if err != nil || j < 0 || j > 1 {
+			return nil, fmt.Errorf("invalid NTFY_RETRY_JITTER %q, expected a share between 0 and 1, e.g. 0.2", v)
+		}
+		cfg.NtfyRetryJitter = j
+	}
+
+	if timeout, err := strconv.Atoi(os.Getenv("SHUTDOWN_TIMEOUT")); err == nil && timeout > 0 {
+		cfg.ShutdownTimeout = time.Duration(timeout) * time.Second
+	} else {
+		cfg.ShutdownTimeout = 10 * time.Second
+	}
+	if timeout, err := strconv.Atoi(os.Getenv("FORWARD_TIMEOUT")); err == nil && timeout > 0 {
+		cfg.ForwardTimeout = time.Duration(timeout) * time.Second
+	} else {
+		cfg.ForwardTimeout = 30 * time.Second
+	}
+	if timeout, err := strconv.Atoi(os.Getenv("SHUTDOWN_FLUSH_TIMEOUT")); err == nil && timeout > 0 {
+		cfg.ShutdownFlushTimeout = time.Duration(timeout) * time.Second
+	}
+
+	cfg.DryRun = strings.ToLower(os.Getenv("NTFY_DRY_RUN")) == "true"
+	cfg.ObserveOnly = strings.ToLower(os.Getenv("OBSERVE_ONLY")) == "true"
+
+	cfg.PreflightStrict = strings.ToLower(os.Getenv("PREFLIGHT_STRICT")) == "true"
+	cfg.Preflight = strings.ToLower(os.Getenv("PREFLIGHT")) == "true" || cfg.PreflightStrict
+
+	// Standard OpenTelemetry exporter variables
+	if ep := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); ep != "" {
+		cfg.OTLPEndpoint = ep
+	} else if ep := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); ep != "" {
+		cfg.OTLPEndpoint = strings.TrimRight(ep, "/") + "/v1/traces"
+	}
+	for _, name := range []string{"OTEL_EXPORTER_OTLP_HEADERS", "OTEL_EXPORTER_OTLP_TRACES_HEADERS"} {
+		h, err := parseOTLPHeaders(os.Getenv(name))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		if cfg.otlpHeaders == nil {
+			cfg.otlpHeaders = h
+		}
+		for k, v := range h {
+			cfg.otlpHeaders[k] = v
+		}
+	}
+	if cfg.OTLPServiceName = os.Getenv("OTEL_SERVICE_NAME"); cfg.OTLPServiceName == "" {
+		cfg.OTLPServiceName = serviceName
+	}
+	if s := os.Getenv("TRACE_SAMPLE_RATE"); s != "" {
+		rate, err := strconv.ParseFloat(s, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("invalid TRACE_SAMPLE_RATE %q, expected a share between 0 and 1, e.g. 0.01", s)
+		}
+		cfg.TraceSampleRate = rate
+	}
+	for _, app := range strings.Split(os.Getenv("TRACE_APPS"), ",") {
+		if app = strings.TrimSpace(app); app != "" {
+			cfg.TraceApps = append(cfg.TraceApps, app)
+		}
+	}
+
+	cfg.Attachments = strings.ToLower(os.Getenv("ATTACHMENTS"))
+	switch cfg.Attachments {
+	case "":
+		cfg.Attachments = attachOff
+	case attachOff, attachLink, attachUpload:
+	default:
+		return nil, fmt.Errorf("invalid ATTACHMENTS %q, expected off, link or upload", cfg.Attachments)
+	}
+	if n, err := strconv.ParseInt(os.Getenv("ATTACHMENT_MAX_BYTES"), 10, 64); err == nil && n > 0 {
+		cfg.AttachmentMaxBytes = n
+	} else {
+		cfg.AttachmentMaxBytes = 2 << 20
+	}
+	cfg.AttachmentTypes = []string{"image/*"}
+	if v := os.Getenv("ATTACHMENT_TYPES"); v != "" {
+		cfg.AttachmentTypes = nil
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				cfg.AttachmentTypes = append(cfg.AttachmentTypes, t)
+			}
+		}
+	}
+	cfg.AttachmentOnLimit = strings.ToLower(os.Getenv("ATTACHMENT_ON_LIMIT"))
+	switch cfg.AttachmentOnLimit {
+	case "":
+		cfg.AttachmentOnLimit = onLimitLink
+	case onLimitLink, onLimitDrop, onLimitCompress:
+	default:
+		return nil, fmt.Errorf("invalid ATTACHMENT_ON_LIMIT %q, expected link, drop or compress", cfg.AttachmentOnLimit)
+	}
+	if n, err := strconv.Atoi(os.Getenv("ATTACHMENT_MAX_DIMENSION")); err == nil && n > 0 {
+		cfg.AttachmentMaxDimension = n
+	}
+	if q, err := strconv.Atoi(os.Getenv("ATTACHMENT_JPEG_QUALITY")); err == nil && q >= 1 && q <= 100 {
+		cfg.AttachmentJPEGQuality = q
+	} else {
+		cfg.AttachmentJPEGQuality = 80
+	}
+
+	cfg.AppIcons = strings.ToLower(os.Getenv("NTFY_APP_ICONS")) == "true"
+
+	cfg.Pprof = strings.ToLower(os.Getenv("HTTP_PPROF")) == "true"
+
+	for _, v := range []struct {
+		name string
+		dst  *[]netip.Prefix
+	}{{"HTTP_ALLOW_CIDRS", &cfg.HTTPAllowCIDRs}, {"HTTP_TRUSTED_PROXIES", &cfg.HTTPTrustedProxies}} {
+		prefixes, err := parseCIDRs(os.Getenv(v.name))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", v.name, err)
+		}
+		*v.dst = prefixes
+	}
+	if n, err := strconv.Atoi(os.Getenv("HTTP_RATE_LIMIT")); err == nil && n > 0 {
+		cfg.HTTPRateLimit = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("HTTP_RATE_BURST")); err == nil && n > 0 {
+		cfg.HTTPRateBurst = n
+	} else {
+		cfg.HTTPRateBurst = max(cfg.HTTPRateLimit/6, 5)
+	}
+
+	cfg.AdminToken = os.Getenv("ADMIN_TOKEN")
+	cfg.AdminUser = os.Getenv("ADMIN_USER")
+	cfg.AdminPassword = os.Getenv("ADMIN_PASSWORD")
+	if (cfg.AdminUser == "") != (cfg.AdminPassword == "") {
+		return nil, fmt.Errorf("ADMIN_USER and ADMIN_PASSWORD must be set together")
+	}
+	cfg.AuditLogFile = os.Getenv("AUDIT_LOG")
+	cfg.AuditNotify = strings.ToLower(os.Getenv("AUDIT_NOTIFY")) == "true"
+
+	cfg.MessageLogFile = os.Getenv("MESSAGE_LOG")
+	if size, err := strconv.Atoi(os.Getenv("MESSAGE_LOG_MAX_SIZE")); err == nil && size >= 0 {
+		cfg.MessageLogMaxSize = int64(size) << 20
+	} else {
+		cfg.MessageLogMaxSize = 10 << 20
+	}
+	if backups, err := strconv.Atoi(os.Getenv("MESSAGE_LOG_BACKUPS")); err == nil && backups >= 0 {
+		cfg.MessageLogBackups = backups
+	} else {
+		cfg.MessageLogBackups = 5
+	}
+	cfg.WALFile = os.Getenv("WAL_FILE")
+	if cfg.FailureWebhookURL = os.Getenv("FAILURE_WEBHOOK_URL"); cfg.FailureWebhookURL != "" {
+		if u, err := url.Parse(cfg.FailureWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid FAILURE_WEBHOOK_URL %q, expected an http(s) URL", cfg.FailureWebhookURL)
+		}
+	}
+	cfg.DeadLetterTopic = os.Getenv("NTFY_DEAD_LETTER_TOPIC")
+
+	if threshold, err := strconv.Atoi(os.Getenv("READY_THRESHOLD")); err == nil && threshold > 0 {
+		cfg.ReadyThreshold = time.Duration(threshold) * time.Second
+	} else {
+		cfg.ReadyThreshold = 2 * time.Minute
+	}
+
+	cfg.SnoozeDuration = time.Hour
+	if v := os.Getenv("SNOOZE_DURATION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid SNOOZE_DURATION %q, expected e.g. 1h or 30m (0 disables)", v)
+		}
+		cfg.SnoozeDuration = d
+	}
+	if cfg.SnoozeDuration > snoozeMaxDuration {
+		return nil, fmt.Errorf("invalid SNOOZE_DURATION %q, at most %s", os.Getenv("SNOOZE_DURATION"), formatDuration(snoozeMaxDuration))
+	}
+	// Without a fixed secret, snooze actions sent before a restart stop working
+	cfg.SnoozeSecret = os.Getenv("SNOOZE_SECRET")
+	if cfg.SnoozeSecret == "" {
+		cfg.SnoozeSecret = rand.Text()
+	}
+
+	if v := os.Getenv("MESSAGE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid MESSAGE_TTL %q, expected e.g. 30m or 2h (0 disables)", v)
+		}
+		cfg.MessageTTL = d
+	}
+
+	if cfg.ProfilesFile = os.Getenv("NTFY_PROFILES_FILE"); cfg.ProfilesFile != "" {
+		ps, err := loadProfiles(cfg.ProfilesFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid NTFY_PROFILES_FILE %s: %w", cfg.ProfilesFile, err)
+		}
+		cfg.profiles = ps
+	}
+	cfg.TitleTemplate, cfg.BodyTemplate = os.Getenv("NTFY_TITLE_TEMPLATE"), os.Getenv("NTFY_BODY_TEMPLATE")
+	var tplErr error
+	if cfg.titleTpl, tplErr = parseMessageTemplate("title", cfg.TitleTemplate); tplErr != nil {
+		return nil, fmt.Errorf("invalid NTFY_TITLE_TEMPLATE: %w", tplErr)
+	}
+	if cfg.bodyTpl, tplErr = parseMessageTemplate("body", cfg.BodyTemplate); tplErr != nil {
+		return nil, fmt.Errorf("invalid NTFY_BODY_TEMPLATE: %w", tplErr)
+	}
+
+	cfg.TopicRules = os.Getenv("TOPIC_RULES")
+	cfg.TopicRulesCandidate = os.Getenv("TOPIC_RULES_CANDIDATE")
+	rules, rulesErr := parseRuleSets(cfg.TopicRules, cfg.TopicRulesCandidate)
+	if rulesErr != nil {
+		return nil, rulesErr
+	}
+	cfg.rules.Store(rules)
+
+	headers, headersErr := parseGotifyHeaders(os.Getenv("NTFY_GOTIFY_HEADERS"))
+	if headersErr != nil {
+		return nil, fmt.Errorf("invalid NTFY_GOTIFY_HEADERS: %w", headersErr)
+	}
+	cfg.GotifyHeaders = headers
+
+	expect, expectErr := parseExpectRules(os.Getenv("EXPECT_MESSAGES"))
+	if expectErr != nil {
+		return nil, fmt.Errorf("invalid EXPECT_MESSAGES: %w", expectErr)
+	}
+	cfg.ExpectRules = expect
+
+	cfg.PriorityMapping = os.Getenv("PRIORITY_MAPPING")
+	cfg.PriorityBands = os.Getenv("PRIORITY_BANDS")
+	cfg.PriorityExpression = os.Getenv("PRIORITY_EXPRESSION")
+	mapper, mapperErr := parsePriorityMapping(cfg.PriorityMapping, cfg.PriorityBands, cfg.PriorityExpression)
+	if mapperErr != nil {
+		return nil, mapperErr
+	}
+	cfg.prioMapper = mapper
+	zeroPriority, zeroErr := parseZeroPriority(os.Getenv("PRIORITY_ZERO"))
+	if zeroErr != nil {
+		return nil, zeroErr
+	}
+	cfg.ZeroPriority = zeroPriority
+
+	cfg.TopicAnnounce = strings.ToLower(os.Getenv("TOPIC_ANNOUNCE")) == "true"
+	cfg.TopicAnnounceURL = os.Getenv("TOPIC_ANNOUNCE_URL")
+	if cfg.TopicAnnounceURL == "" {
+		cfg.TopicAnnounceURL = cfg.NtfyURL
+	}
+	cfg.AnnouncedTopicsDB = os.Getenv("ANNOUNCED_TOPICS_DB")
+	if cfg.AnnouncedTopicsDB == "" {
+		cfg.AnnouncedTopicsDB = "announced_topics.json"
+	}
+
+	if cfg.CriticalRule = os.Getenv("CRITICAL_RULE"); cfg.CriticalRule != "" {
+		cond, err := parseTopicCond(cfg.CriticalRule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CRITICAL_RULE: %w", err)
+		}
+		cfg.criticalCond = cond
+	}
+	if timeout, err := strconv.Atoi(os.Getenv("CRITICAL_TIMEOUT")); err == nil && timeout > 0 {
+		cfg.CriticalTimeout = time.Duration(timeout) * time.Second
+	} else {
+		cfg.CriticalTimeout = 5 * time.Second
+	}
+
+	if ms, err := strconv.Atoi(os.Getenv("SLOW_FORWARD_THRESHOLD")); err == nil && ms > 0 {
+		cfg.SlowForwardThreshold = time.Duration(ms) * time.Millisecond
+	} else {
+		cfg.SlowForwardThreshold = 5 * time.Second
+	}
+
+	if cfg.SchedulesFile = os.Getenv("SCHEDULES_FILE"); cfg.SchedulesFile != "" {
+		schedules, err := loadSchedules(cfg.SchedulesFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SCHEDULES_FILE %s: %w", cfg.SchedulesFile, err)
+		}
+		cfg.schedules = schedules
+	}
+
+	cfg.NtfyAdminTopic = os.Getenv("NTFY_ADMIN_TOPIC")
+	if cfg.NtfyAdminTopic == "" {
+		cfg.NtfyAdminTopic = cfg.NtfyTopic
+	}
+	if cfg.NtfyErrorTopic = os.Getenv("NTFY_ERROR_TOPIC"); cfg.NtfyErrorTopic == "" {
+		cfg.NtfyErrorTopic = cfg.NtfyAdminTopic
+	}
+	cfg.WeeklyReport = strings.ToLower(os.Getenv("WEEKLY_REPORT")) == "true"
+	cfg.WeeklyReportDay = time.Monday
+	if d := os.Getenv("WEEKLY_REPORT_DAY"); d != "" {
+		day, ok := parseWeekday(d)
+		if !ok {
+			return nil, fmt.Errorf("invalid WEEKLY_REPORT_DAY %q", d)
+		}
+		cfg.WeeklyReportDay = day
+	}
+	cfg.WeeklyReportTime = os.Getenv("WEEKLY_REPORT_TIME")
+	if cfg.WeeklyReportTime == "" {
+		cfg.WeeklyReportTime = "09:00"
+	} else if _, err := time.Parse("15:04", cfg.WeeklyReportTime); err != nil {
+		return nil, fmt.Errorf("invalid WEEKLY_REPORT_TIME %q, expected HH:MM", cfg.WeeklyReportTime)
+	}
+	cfg.DailyDigest = strings.ToLower(os.Getenv("DAILY_DIGEST")) == "true"
+	cfg.DailyDigestTime = os.Getenv("DAILY_DIGEST_TIME")
+	if cfg.DailyDigestTime == "" {
+		cfg.DailyDigestTime = "08:00"
+	} else if _, err := time.Parse("15:04", cfg.DailyDigestTime); err != nil {
+		return nil, fmt.Errorf("invalid DAILY_DIGEST_TIME %q, expected HH:MM", cfg.DailyDigestTime)
+	}
+	cfg.ResourceMonitor = strings.ToLower(os.Getenv("RESOURCE_MONITOR")) == "true"
+	if v := os.Getenv("HEARTBEAT_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid HEARTBEAT_INTERVAL %q, expected e.g. 6h (0 disables)", v)
+		}
+		cfg.HeartbeatInterval = d
+	}
+	cfg.HeartbeatTopic = os.Getenv("HEARTBEAT_TOPIC")
+	if cfg.HeartbeatTopic == "" {
+		cfg.HeartbeatTopic = cfg.NtfyAdminTopic
+	}
+	cfg.ControlTopic = os.Getenv("NTFY_CONTROL_TOPIC")
+	if v := os.Getenv("STALE_FORWARD_AFTER"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid STALE_FORWARD_AFTER %q, expected e.g. 12h (0 disables)", v)
+		}
+		cfg.StaleForwardAfter = d
+	}
+	cfg.ReportDBPath = os.Getenv("REPORT_DB")
+	if cfg.ReportDBPath == "" {
+		cfg.ReportDBPath = "report_db.json"
+	}
+
+	if n, err := strconv.Atoi(os.Getenv("DEDUP_WINDOW")); err == nil && n >= 0 {
+		cfg.DedupWindow = n
+	} else {
+		cfg.DedupWindow = 256
+	}
+	if n, err := strconv.Atoi(os.Getenv("DEDUP_SENT")); err == nil && n >= 0 {
+		cfg.DedupSent = n
+	} else {
+		cfg.DedupSent = 1024
+	}
+
+	if n, err := strconv.Atoi(os.Getenv("OFFLINE_BUFFER_SIZE")); err == nil && n >= 0 {
+		cfg.OfflineBufferSize = n
+	} else {
+		cfg.OfflineBufferSize = 1000
+	}
+	if interval, err := strconv.Atoi(os.Getenv("OFFLINE_RETRY_INTERVAL")); err == nil && interval > 0 {
+		cfg.OfflineRetryInterval = time.Duration(interval) * time.Second
+	} else {
+		cfg.OfflineRetryInterval = 15 * time.Second
+	}
+	if n, err := strconv.Atoi(os.Getenv("OFFLINE_RETRY_MAX")); err == nil && n > 0 {
+		cfg.OfflineRetryMax = time.Duration(n) * time.Second
+	} else {
+		cfg.OfflineRetryMax = 5 * time.Minute
+	}
+	cfg.OfflineBufferFile = os.Getenv("OFFLINE_BUFFER_FILE")
+	if interval, err := strconv.Atoi(os.Getenv("OFFLINE_ANNOTATE_AFTER")); err == nil && interval >= 0 {
+		cfg.OfflineAnnotateAfter = time.Duration(interval) * time.Second
+	} else {
+		cfg.OfflineAnnotateAfter = time.Minute
+	}
+
+	cfg.Metered = strings.ToLower(os.Getenv("METERED")) == "true"
+	if n, err := strconv.Atoi(os.Getenv("METERED_MAX_BODY")); err == nil && n >= 0 {
+		cfg.MeteredMaxBody = n
+	} else {
+		cfg.MeteredMaxBody = 512
+	}
+	if interval, err := strconv.Atoi(os.Getenv("METERED_BATCH_INTERVAL")); err == nil && interval > 0 {
+		cfg.MeteredBatchInterval = time.Duration(interval) * time.Second
+	} else {
+		cfg.MeteredBatchInterval = 15 * time.Minute
+	}
+
+	var err error
+	if cfg.GotifyProxy, err = parseProxy("GOTIFY_PROXY", os.Getenv("GOTIFY_PROXY")); err != nil {
+		return nil, err
+	}
+	if cfg.NtfyProxy, err = parseProxy("NTFY_PROXY", os.Getenv("NTFY_PROXY")); err != nil {
+		return nil, err
+	}
+	torProxy := os.Getenv("TOR_PROXY")
+	if torProxy == "" {
+		torProxy = "socks5h://127.0.0.1:9050"
+	}
+	if cfg.TorProxy, err = parseProxy("TOR_PROXY", torProxy); err != nil {
+		return nil, err
+	}
+	cfg.InstanceName = os.Getenv("INSTANCE_NAME")
+	if cfg.UserAgent = os.Getenv("USER_AGENT"); cfg.UserAgent == "" {
+		cfg.UserAgent = defaultUserAgent(cfg.InstanceName)
+	}
+	cfg.setupClients()
+
 	// sanity check
-	if cfg.GotifyURL == "" || cfg.GotifyToken == "" || cfg.NtfyURL == "" || cfg.NtfyTopic == "" {
-		return nil, fmt.Errorf("missing required env vars: GOTIFY_URL, GOTIFY_CLIENT_TOKEN, NTFY_URL, NTFY_TOPIC")
+	hasLogin := cfg.GotifyUser != "" && cfg.GotifyPassword != ""
+	// Split topics do not need a catch-all topic, but bridge notifications need somewhere to go
+	hasTopic := cfg.NtfyTopic != "" || (cfg.SplitTopics && cfg.NtfyAdminTopic != "")
+	if cfg.GotifyURL == "" || (cfg.GotifyToken == "" && !hasLogin) || cfg.NtfyURL == "" || !hasTopic {
+		return nil, fmt.Errorf("missing required env vars: GOTIFY_URL, GOTIFY_CLIENT_TOKEN (or GOTIFY_USER and GOTIFY_PASSWORD), NTFY_URL, " +
+			"NTFY_TOPIC (or NTFY_SPLIT_TOPICS=true and NTFY_ADMIN_TOPIC)")
 	}
 
 	return cfg, nil
 }
 
+// parseWeekday accepts full or three-letter English weekday names.
+func parseWeekday(s string) (time.Weekday, bool) {
+	s = strings.ToLower(s)
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || s == name[:3] {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// withStreamPath appends the /stream endpoint to a Gotify URL that lacks it,
+// e.g. wss://host/gotify -> wss://host/gotify/stream.
+func withStreamPath(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || strings.HasSuffix(u.Path, "/stream") {
+		return raw, false
+	}
+	u.Path = strings.TrimRight(u.Path, "/") + "/stream"
+	u.RawPath = ""
+	return u.String(), true
+}
+
 func dbg(cfg *Config, format string, a ...interface{}) {
 	if cfg.Debug {
-		log.Printf("[DEBUG] "+format, a...)
+		slog.Debug(fmt.Sprintf(format, a...))
 	}
 }
 
@@ -160,6 +921,12 @@ func (a *AppStore) Upsert(app GotifyApp) {
 	a.byID[app.ID] = app
 }
 
+func (a *AppStore) Remove(appID int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.byID, appID)
+}
+
 func (a *AppStore) Get(appID int64) (GotifyApp, bool) {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
@@ -167,6 +934,18 @@ func (a *AppStore) Get(appID int64) (GotifyApp, bool) {
 	return app, ok
 }
 
+// All returns the apps sorted by ID.
+func (a *AppStore) All() []GotifyApp {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	apps := make([]GotifyApp, 0, len(a.byID))
+	for _, app := range a.byID {
+		apps = append(apps, app)
+	}
+	sort.Slice(apps, func(i, j int) bool { return apps[i].ID < apps[j].ID })
+	return apps
+}
+
 func (a *AppStore) TopicFor(appID int64, fallback string) string {
 	app, ok := a.Get(appID)
 	if !ok {
@@ -175,20 +954,44 @@ func (a *AppStore) TopicFor(appID int64, fallback string) string {
 	return sanitizeTopic(app.Name)
 }
 
+// effectivePriority substitutes the configured default for a Gotify priority
+// of 0, unless PRIORITY_ZERO asks to keep it.
+func effectivePriority(cfg *Config, gotify int) int {
+	if gotify <= 0 {
+		if cfg.ZeroPriority != zeroPriorityDefault {
+			return 0
+		}
+		return cfg.NtfyPriority
+	}
+	return gotify
+}
+
 func mapGotifyToNtfyPriority(gotify int) int {
 	p := int(math.Round(float64(gotify) / 2.5))        // 0–10 -> 0–4
 	return int(math.Min(math.Max(float64(p+1), 1), 5)) // clamp to 1–5
 }
 
-func getApplications(cfg *Config) ([]GotifyApp, error) {
-	// Build the REST base URL from the configured websocket URL, preserving subpaths.
-	// Examples:
-	//   wss://host/gotify/stream     -> https://host/gotify/application
-	//   ws://host/stream?x=y         -> http://host/application
-	//   https://host/gotify/stream   -> https://host/gotify/application
-	u, err := url.Parse(cfg.GotifyURL)
+// gotifyAPIURL returns the Gotify REST URL for endpoint (e.g. "/application").
+// GOTIFY_API_URL is used as the base when set; otherwise the base is derived
+// from the configured websocket URL, preserving subpaths.
+// Examples:
+//
+//	wss://host/gotify/stream     -> https://host/gotify/application
+//	ws://host/stream?x=y         -> http://host/application
+//	https://host/gotify/stream   -> https://host/gotify/application
+func gotifyAPIURL(cfg *Config, endpoint string) (string, error) {
+	if cfg.GotifyAPIURL != "" {
+		u, err := url.Parse(cfg.GotifyAPIURL)
+		if err != nil {
+			return "", fmt.Errorf("invalid GOTIFY_API_URL: %w", err)
+		}
+		u.Path = path.Join("/", u.Path, endpoint)
+		return u.String(), nil
+	}
+
+	u, err := url.Parse(cfg.ActiveGotifyURL())
 	if err != nil {
-		return nil, fmt.Errorf("invalid GOTIFY_URL: %w", err)
+		return "", fmt.Errorf("invalid GOTIFY_URL: %w", err)
 	}
 
 	// Map ws(s) -> http(s); keep http/https as-is
@@ -207,18 +1010,24 @@ func getApplications(cfg *Config) ([]GotifyApp, error) {
 	basePath := strings.TrimSuffix(u.EscapedPath(), "/stream")
 	u.RawQuery = ""
 	u.Fragment = ""
-	u.Path = path.Join(basePath, "/application")
+	u.Path = path.Join(basePath, endpoint)
+
+	return u.String(), nil
+}
 
-	appsURL := u.String()
+func getApplications(ctx context.Context, cfg *Config) ([]GotifyApp, error) {
+	appsURL, err := gotifyAPIURL(cfg, "/application")
+	if err != nil {
+		return nil, err
+	}
 
-	req, err := http.NewRequest("GET", appsURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", appsURL, nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("X-Gotify-Key", cfg.GotifyToken)
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := cfg.GotifyHTTP().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -232,6 +1041,9 @@ func getApplications(cfg *Config) ([]GotifyApp, error) {
 	if err := json.NewDecoder(resp.Body).Decode(&apps); err != nil {
 		return nil, err
 	}
+	for _, app := range apps {
+		registerSecret(app.Token)
+	}
 	return apps, nil
 }
 
@@ -264,168 +1076,395 @@ func loadKnownApps(path string) (map[int64]GotifyApp, error) {
 }
 
 func saveKnownApps(path string, m map[int64]GotifyApp) error {
-	tmp := path + ".tmp"
-	f, err := os.Create(tmp)
-	if err != nil {
-		return err
+	return writeFileAtomic(path, func(f *os.File) error {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return enc.Encode(m)
+	})
+}
+
+// ntfyPublish is a fully resolved ntfy message.
+type ntfyPublish struct {
+	Topic    string
+	Title    string
+	Body     string
+	Priority int    // ntfy priority 1–5
+	Actions  string // optional ntfy Actions header
+	Tags     []string
+	Markdown bool
+	Icon     string
+	Headers  http.Header // extra headers, e.g. X-Gotify-* provenance
+
+	Attach     string          // URL ntfy clients load the attachment from
+	Attachment *ntfyAttachment // file uploaded as the message body
+
+	// Not sent to ntfy
+	TTL     time.Duration // drop instead of delivering once buffered this long
+	Timeout time.Duration // overrides the client timeout for this request
+
+	span *Span          // parent of the request span, nil unless tracing
+	msg  *GotifyMessage // the message p was built from, for MESSAGE_LOG
+}
+
+// ntfyStatusError is returned when ntfy answered with a non-success status.
+type ntfyStatusError struct {
+	Code       int
+	Status     string
+	Body       string
+	RetryAfter time.Duration // from the Retry-After header, see rateLimitPause
+}
+
+func (e *ntfyStatusError) Error() string {
+	return fmt.Sprintf("ntfy error: %s: %s", e.Status, strings.TrimSpace(e.Body))
+}
+
+// isOffline reports whether err means ntfy could not be reached at all, as
+// opposed to ntfy rejecting the message.
+func isOffline(err error) bool {
+	var statusErr *ntfyStatusError
+	return err != nil && !errors.As(err, &statusErr)
+}
+
+func publishNtfy(ctx context.Context, cfg *Config, p ntfyPublish) (err error) {
+	endpoint := strings.TrimRight(cfg.NtfyURL, "/") + "/" + url.PathEscape(strings.TrimLeft(p.Topic, "/"))
+	span := p.span.Child("ntfy.publish", spanKindClient)
+	span.Set("ntfy.topic", p.Topic)
+	defer func() { span.End(err) }()
+
+	if cfg.ObserveOnly {
+		span.Set("observed", true)
+		slog.Info("Observed, not published", "topic", p.Topic, "title", p.Title, "priority", p.Priority)
+		return nil
 	}
-	enc := json.NewEncoder(f)
-	enc.SetIndent("", "  ")
-	if err := enc.Encode(m); err != nil {
-		_ = f.Close()
-		_ = os.Remove(tmp)
-		return err
+
+	dbg(cfg, "Forwarding to ntfy URL: %s", endpoint)
+	dbg(cfg, "Payload:\n%s", p.Body)
+
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
 	}
-	if err := f.Close(); err != nil {
-		_ = os.Remove(tmp)
-		return err
+	for attempt := 1; ; attempt++ {
+		err = publishAttempt(ctx, cfg, p, endpoint, span)
+		if err == nil || attempt > cfg.NtfyRetryMax || !ntfyRetryable(err) {
+			if attempt > 1 {
+				span.Set("ntfy.attempts", attempt)
+			}
+			return err
+		}
+		delay := ntfyRetryDelay(cfg, attempt)
+		log.Printf("[NTFY WARN] publish to %s failed (attempt %d of %d), retrying in %s: %v",
+			p.Topic, attempt, cfg.NtfyRetryMax+1, delay.Round(time.Millisecond), err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
 	}
-	return os.Rename(tmp, path)
 }
 
-func sendNtfy(cfg *Config, topic, title, body string, priority int) error {
-	endpoint := strings.TrimRight(cfg.NtfyURL, "/") + "/" + url.PathEscape(strings.TrimLeft(topic, "/"))
-	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewBufferString(body))
+// publishAttempt makes one request to ntfy for p.
+func publishAttempt(ctx context.Context, cfg *Config, p ntfyPublish, endpoint string, span *Span) error {
+	method, payload := http.MethodPost, []byte(p.Body)
+	if p.Attachment != nil {
+		// ntfy takes an uploaded file as the body and the text in the Message header
+		method, payload = http.MethodPut, p.Attachment.Data
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(payload))
 	if err != nil {
 		return err
 	}
-	if title != "" {
-		req.Header.Set("Title", title)
-	}
-	if priority <= 0 {
-		priority = cfg.NtfyPriority
+
+	// Set the Title header separately (this becomes the notification title)
+	if p.Title != "" {
+		req.Header.Set("Title", p.Title)
 	}
-	req.Header.Set("Priority", fmt.Sprint(mapGotifyToNtfyPriority(priority)))
+
+	req.Header.Set("Priority", fmt.Sprint(p.Priority))
 	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if p.Attachment != nil {
+		req.Header.Set("Content-Type", p.Attachment.ContentType)
+		req.Header.Set("Filename", p.Attachment.Filename)
+		req.Header.Set("Message", mime.BEncoding.Encode("utf-8", p.Body))
+	}
+	if p.Attach != "" {
+		req.Header.Set("Attach", p.Attach)
+	}
+	for k, v := range p.Headers {
+		req.Header[k] = v
+	}
+	if p.Actions != "" {
+		req.Header.Set("Actions", p.Actions)
+	}
+	if len(p.Tags) > 0 {
+		req.Header.Set("Tags", strings.Join(p.Tags, ","))
+	}
+	if p.Markdown {
+		req.Header.Set("Markdown", "yes")
+	}
+	if p.Icon != "" {
+		req.Header.Set("Icon", p.Icon)
+	}
+
 	if cfg.NtfyAuthToken != "" {
 		req.Header.Set("Authorization", "Bearer "+cfg.NtfyAuthToken)
+		dbg(cfg, "Using auth token")
+	}
+	if tp := span.Traceparent(); tp != "" {
+		req.Header.Set("traceparent", tp)
+	}
+
+	if cfg.DryRun {
+		if p.Attachment != nil {
+			logDryRun(req, fmt.Sprintf("<%d bytes %s>", len(p.Attachment.Data), p.Attachment.ContentType))
+		} else {
+			logDryRun(req, p.Body)
+		}
+		return nil
 	}
-	resp, err := http.DefaultClient.Do(req)
+
+	resp, err := cfg.NtfyHTTP().Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
+
+	dbg(cfg, "ntfy response status: %s", resp.Status)
+	span.Set("http.response.status_code", resp.StatusCode)
+
 	if resp.StatusCode >= 300 {
 		b, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("ntfy error: %s: %s", resp.Status, string(b))
+		return &ntfyStatusError{Code: resp.StatusCode, Status: resp.Status, Body: string(b), RetryAfter: retryAfter(resp.Header)}
 	}
 	return nil
 }
 
-func syncTopics(cfg *Config, store *AppStore, interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	known, err := loadKnownApps(cfg.AppsDBPath)
-	if err != nil {
-		log.Printf("[SYNC ERROR] could not load known apps db: %v", err)
-		known = make(map[int64]GotifyApp)
+func sendNtfy(ctx context.Context, cfg *Config, topic, title, body string, priority int) error {
+	if cfg.dropsPriority(priority) {
+		dbg(cfg, "Not sending %q to %s: priority 0 is dropped (PRIORITY_ZERO)", title, topic)
+		return nil
 	}
+	return publishNtfy(ctx, cfg, ntfyPublish{
+		Topic:    topic,
+		Title:    title,
+		Body:     body,
+		Priority: mapGotifyToNtfyPriority(effectivePriority(cfg, priority)),
+	})
+}
 
-	// Seed from current Gotify
-	current, err := getApplications(cfg)
-	if err == nil {
-		for _, a := range current {
-			known[a.ID] = a
+// logDryRun prints the ntfy request that would have been sent.
+func logDryRun(req *http.Request, body string) {
+	var headers []string
+	for k, v := range req.Header {
+		val := strings.Join(v, ", ")
+		if k == "Authorization" {
+			val = "***"
 		}
-		_ = saveKnownApps(cfg.AppsDBPath, known)
-		store.SetAll(current)
-	} else {
-		log.Printf("[SYNC WARN] initial getApplications failed: %v", err)
+		headers = append(headers, k+": "+val)
 	}
+	sort.Strings(headers)
+	log.Printf("[DRY RUN] %s %s\n%s\n\n%s", req.Method, req.URL, strings.Join(headers, "\n"), body)
+}
 
+// syncTopics refreshes the apps every interval until ctx is done.
+func syncTopics(ctx context.Context, s *AppSync, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 	for {
-		cur, err := getApplications(cfg)
-		if err != nil {
+		if _, err := s.Run(ctx); err != nil && ctx.Err() == nil {
 			log.Printf("[SYNC ERROR] Could not load applications: %v", err)
-			<-ticker.C
-			continue
 		}
-
-		// Detect new or changed apps
-		for _, a := range cur {
-			old, ok := known[a.ID]
-			if !ok {
-				// New app detected
-				title := "New Gotify app detected"
-				body := fmt.Sprintf("Name: %s (ID=%d)\nDescription: %q", a.Name, a.ID, a.Description)
-
-				if err := sendNtfy(cfg, cfg.NtfyTopic, title, body, 4); err != nil {
-					log.Printf("[SYNC ERROR] failed to notify about new app %s (ID=%d): %v", a.Name, a.ID, err)
-				} else {
-					log.Printf("[SYNC] Notified about new app: %s (ID=%d)", a.Name, a.ID)
-				}
-
-				// Add the new app to the store and known apps
-				store.Upsert(a)
-				known[a.ID] = a
-			} else if old.Description != a.Description {
-				// Description changed
-				title := "Gotify app description updated"
-				body := fmt.Sprintf("App: %s (ID=%d)\nOld: %q\nNew: %q", a.Name, a.ID, old.Description, a.Description)
-				if err := sendNtfy(cfg, cfg.NtfyTopic, title, body, 3); err != nil {
-					log.Printf("[SYNC ERROR] failed to notify about description change for %s (ID=%d): %v", a.Name, a.ID, err)
-				} else {
-					log.Printf("[SYNC] Notified description change for app %s (ID=%d)", a.Name, a.ID)
-				}
-
-				store.Upsert(a)
-				known[a.ID] = a
-			}
-		}
-
-		if err := saveKnownApps(cfg.AppsDBPath, known); err != nil {
-			log.Printf("[SYNC ERROR] could not save known apps db: %v", err)
-		}
-
-		// Validate topics locally (no network)
-		for _, a := range cur {
-			topic := sanitizeTopic(a.Name)
-			if err := ensureTopic(cfg, topic); err != nil {
-				log.Printf("[SYNC ERROR] Could not validate topic %s: %v", topic, err)
-			} else {
-				dbg(cfg, "[SYNC] Topic ready: %s", topic)
-			}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
 		}
-
-		<-ticker.C
 	}
 }
 
 // Pass config pointer instead of multiple args
-func listenAndForward(cfg *Config, store *AppStore) error {
+func listenAndForward(ctx context.Context, b *Bridge) error {
+	cfg, stats := b.cfg, b.stats
 	headers := http.Header{}
 	headers.Set("X-Gotify-Key", cfg.GotifyToken)
+	headers.Set("User-Agent", cfg.UserAgent)
 
-	conn, _, err := websocket.DefaultDialer.Dial(cfg.GotifyURL, headers)
+	gotifyURL := cfg.ActiveGotifyURL()
+	conn, _, err := cfg.GotifyDialer().DialContext(ctx, gotifyURL, headers)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %w", errDial, err)
 	}
 	defer conn.Close()
 
-	log.Println("Connected to Gotify stream")
+	slog.Info("Connected to Gotify stream", "url", gotifyURL)
+
+	// While on a failover path, keep probing the primary and switch back once it answers
+	failback := make(chan struct{})
+	if gotifyURL != cfg.GotifyURL {
+		probeCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go probePrimary(probeCtx, cfg, conn, failback)
+	}
+	stats.SetConnected(true)
+	defer stats.SetConnected(false)
+	b.outage.Connected(b)
+
+	// Gotify pings the stream periodically; treat pings as a sign of life for the watchdog
+	b.watchdog.Ready()
+	b.watchdog.Beat()
+	b.lastRead.Store(time.Now().UnixNano())
+	conn.SetPingHandler(func(data string) error {
+		b.watchdog.Beat()
+		b.lastRead.Store(time.Now().UnixNano())
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		if errors.Is(err, websocket.ErrCloseSent) {
+			return nil
+		}
+		return err
+	})
 
 	// Channel to decouple WebSocket reads from HTTP posts
 	msgCh := make(chan GotifyMessage, 100)
-	defer close(msgCh)
+	b.queue.Store(&msgCh)
+	defer b.queue.Store(nil)
+
+	// Critical messages get their own queue and worker so a backlog of
+	// ordinary messages cannot delay them
+	criticalCh := make(chan GotifyMessage, 100)
+	b.criticalQueue.Store(&criticalCh)
+	defer b.criticalQueue.Store(nil)
+
+	// Workers finish the queued messages on shutdown, so their context
+	// outlives ctx until SHUTDOWN_TIMEOUT has passed and this returns
+	workCtx, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
 
 	// Start a few workers
-	workerCount := 4
 	var wg sync.WaitGroup
-	wg.Add(workerCount)
-	for i := 0; i < workerCount; i++ {
-		go func(id int) {
+	wg.Add(streamWorkers + 1)
+	for i := 0; i <= streamWorkers; i++ {
+		ch := msgCh
+		if i == streamWorkers {
+			ch = criticalCh
+		}
+		go func(id int, ch <-chan GotifyMessage) {
 			defer wg.Done()
-			for m := range msgCh {
-				if err := forwardToNtfy(cfg, store, m); err != nil {
-					log.Printf("[worker %d] forward error: %v", id, err)
+			ws := &b.workers[id-1]
+			for m := range ch {
+				queued := time.Since(m.received)
+				stats.RecordQueueWait(queued)
+				m.queueSpan.End(nil)
+				m.span.Set("worker", id)
+				ws.current.Store(&inFlight{MessageID: m.ID, AppID: m.AppID, Started: time.Now()})
+				err := forwardToNtfy(workCtx, b, m)
+				ws.current.Store(nil)
+				b.wal.Finished(m, err)
+				if errors.Is(err, errBuffered) {
+					m.span.Set("buffered", true)
+					m.span.End(nil)
 				} else {
-					dbg(cfg, "[worker %d] Forwarded to ntfy", id)
+					m.span.End(err)
+				}
+				if errors.Is(err, errBuffered) {
+					slog.Debug("Buffered message until ntfy is reachable", "worker", id, "app_id", m.AppID, "message_id", m.ID)
+					ws.buffered.Add(1)
+				} else if err != nil {
+					slog.Error("Forward failed", "worker", id, "app_id", m.AppID, "message_id", m.ID, "error", err)
+					stats.RecordForwardError(m.AppID)
+					ws.failed.Add(1)
+				} else {
+					total := time.Since(m.received)
+					slow := total > cfg.SlowForwardThreshold
+					if slow {
+						slog.Warn("Slow forward", "worker", id, "app_id", m.AppID, "message_id", m.ID,
+							"total", total.Round(time.Millisecond), "queued", queued.Round(time.Millisecond), "processing", (total - queued).Round(time.Millisecond))
+					}
+					stats.RecordForwardLatency(total, slow)
+					slog.Debug("Forwarded to ntfy", "worker", id, "app_id", m.AppID, "message_id", m.ID)
+					stats.RecordForward(m.AppID)
+					ws.forwarded.Add(1)
+					b.lastForwardedID.Store(m.ID)
+					b.backfill.Forwarded(gotifyURL, m.AppID, m.ID)
 				}
 			}
-		}(i + 1)
+		}(i+1, ch)
 	}
 
+	// On shutdown, close the stream so the read loop stops
+	readDone := make(chan struct{})
+	defer close(readDone)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+			_ = conn.Close()
+		case <-readDone:
+		}
+	}()
+
+	// handle queues a message read off the stream or fetched by backfill.
+	// Backfilled messages wait for room in the queue instead of being dropped.
+	handle := func(gotifyMsg GotifyMessage, message []byte, wait bool) {
+		if gotifyMsg.Extras != nil {
+			gotifyMsg.rawExtras = rawExtras(message)
+		}
+		b.backfill.Seen(gotifyURL, gotifyMsg.ID)
+		stats.RecordReceived()
+		b.deadman.Seen(gotifyMsg.AppID)
+		gotifyMsg.received = time.Now()
+		gotifyMsg.span = startMessageSpan(b, gotifyMsg)
+		gotifyMsg.span.Set("gotify.app_id", gotifyMsg.AppID)
+		gotifyMsg.span.Set("gotify.message_id", gotifyMsg.ID)
+		gotifyMsg.span.Set("gotify.priority", gotifyMsg.Priority)
+
+		if b.dedup.Seen(gotifyMsg.AppID, gotifyMsg.ID) {
+			slog.Debug("Skipping duplicate message", "app_id", gotifyMsg.AppID, "message_id", gotifyMsg.ID)
+			stats.RecordDuplicate()
+			gotifyMsg.span.Set("duplicate", true)
+			gotifyMsg.span.End(nil)
+			return
+		}
+		b.volume.Record(gotifyMsg.AppID, cfg.ntfyPriority(messageEnv(cfg, b.store, gotifyMsg)))
+		b.wal.Received(&gotifyMsg, message)
+
+		queue := msgCh
+		if isCritical(cfg, b.store, gotifyMsg) {
+			queue = criticalCh
+		}
+
+		gotifyMsg.queueSpan = gotifyMsg.span.Child("queue", spanKindInternal)
+		if wait {
+			select {
+			case queue <- gotifyMsg:
+			case <-ctx.Done():
+				// Still pending in the WAL, if any
+				gotifyMsg.queueSpan.End(ctx.Err())
+				gotifyMsg.span.End(ctx.Err())
+			}
+			return
+		}
+		// Non-blocking enqueue; drop if full (log and continue)
+		select {
+		case queue <- gotifyMsg:
+			// ok
+		default:
+			slog.Warn("Message channel full, dropping message", "app_id", gotifyMsg.AppID, "message_id", gotifyMsg.ID)
+			stats.RecordDrop()
+			b.recentDrops.Add(1)
+			gotifyMsg.queueSpan.End(errQueueFull)
+			gotifyMsg.span.End(errQueueFull)
+			app, _ := b.store.Get(gotifyMsg.AppID)
+			ev := forwardEvent{AppID: gotifyMsg.AppID, App: app.Name, Result: "dropped", Error: errQueueFull.Error()}
+			b.failures.Fire(gotifyMsg, ev)
+			b.deadLetters.Send(gotifyMsg, ev)
+			b.wal.Done(gotifyMsg)
+		}
+	}
+
+	backfill(ctx, b, gotifyURL, func(msg GotifyMessage, message []byte) { handle(msg, message, true) })
+
 	// Read loop
 	for {
 		_, message, err := conn.ReadMessage()
@@ -433,96 +1472,283 @@ func listenAndForward(cfg *Config, store *AppStore) error {
 			// Let workers drain then return to trigger reconnect in main
 			break
 		}
+		b.watchdog.Beat()
+		b.lastRead.Store(time.Now().UnixNano())
 
 		var gotifyMsg GotifyMessage
 		if err := json.Unmarshal(message, &gotifyMsg); err != nil {
 			log.Println("json error:", err)
 			continue
 		}
-
-		// Non-blocking enqueue; drop if full (log and continue)
-		select {
-		case msgCh <- gotifyMsg:
-			// ok
-		default:
-			log.Printf("[WARN] message channel full, dropping message appID=%d id=%d", gotifyMsg.AppID, gotifyMsg.ID)
-		}
+		handle(gotifyMsg, message, false)
 	}
 
 	// Close channel & wait workers before leaving
 	close(msgCh)
+	close(criticalCh)
+	if ctx.Err() != nil {
+		log.Printf("[SHUTDOWN] Waiting up to %v for %d queued messages", cfg.ShutdownTimeout, len(msgCh)+len(criticalCh))
+		if !waitTimeout(&wg, cfg.ShutdownTimeout) {
+			log.Printf("[SHUTDOWN] Timed out, %d queued messages were not forwarded", len(msgCh)+len(criticalCh))
+		}
+		return ctx.Err()
+	}
 	wg.Wait()
+
+	select {
+	case <-failback:
+		return errFailback
+	default:
+	}
 	return fmt.Errorf("websocket closed")
 }
 
-// Forward to ntfy.sh
-func forwardToNtfy(cfg *Config, store *AppStore, msg GotifyMessage) error {
-	appTopic := cfg.NtfyTopic
-	if cfg.SplitTopics {
-		appTopic = store.TopicFor(msg.AppID, cfg.NtfyTopic)
+// rawExtras returns the extras of a raw Gotify message exactly as sent, for
+// JSON outputs: decoding into a map reformats numbers and loses key order.
+func rawExtras(message []byte) json.RawMessage {
+	var raw struct {
+		Extras json.RawMessage `json:"extras"`
 	}
+	if json.Unmarshal(message, &raw) != nil {
+		return nil
+	}
+	return raw.Extras
+}
 
-	endpoint := strings.TrimRight(cfg.NtfyURL, "/") + "/" + url.PathEscape(strings.TrimLeft(appTopic, "/"))
-
-	// Use ONLY the message as the body, not including the title
-	payload := []byte(msg.Message) // fix issue display 2 titles ...
+// resolveTopic picks the ntfy topic for msg: the first matching TOPIC_RULES
+// rule, else the app's own topic with NTFY_SPLIT_TOPICS, else NTFY_TOPIC.
+func resolveTopic(cfg *Config, store *AppStore, msg GotifyMessage) string {
+	topic, _ := resolveWith(cfg, store, msg, cfg.Rules().active)
+	return topic
+}
 
-	dbg(cfg, "Forwarding to ntfy URL: %s", endpoint)
-	dbg(cfg, "Payload:\n%s", payload)
-	dbg(cfg, "Incoming priority (Gotify or default): %d", msg.Priority)
+// Forward to ntfy.sh
+func forwardToNtfy(ctx context.Context, b *Bridge, msg GotifyMessage) (err error) {
+	cfg, store := b.cfg, b.store
+	ctx, cancel := context.WithTimeout(ctx, cfg.ForwardTimeout)
+	defer cancel()
+	span := msg.span.Child("forward", spanKindInternal)
+	ev := forwardEvent{Time: time.Now(), AppID: msg.AppID, Result: "sent"}
+	defer func() {
+		switch {
+		case errors.Is(err, errHeld):
+			ev.Result = "held"
+		case errors.Is(err, errBuffered):
+			ev.Result = "buffered"
+		case err != nil:
+			ev.Result, ev.Error = "failed", err.Error()
+			b.sent.Release(msg.AppID, msg.ID)
+			b.failures.Fire(msg, ev)
+			b.deadLetters.Send(msg, ev)
+		}
+		b.recent.Add(ev)
+		b.messages.Record(msg, ev)
+		if errors.Is(err, errBuffered) {
+			span.Set("buffered", true)
+			span.End(nil)
+			return
+		}
+		span.End(err)
+	}()
+	if b.mutes.Muted(msg.AppID) {
+		slog.Debug("Skipping message from muted app", "app_id", msg.AppID, "message_id", msg.ID)
+		ev.Result = "muted"
+		return nil
+	}
+	if cfg.dropsPriority(msg.Priority) {
+		slog.Debug("Dropping message with priority 0", "app_id", msg.AppID, "message_id", msg.ID)
+		ev.Result = "zero_priority"
+		return nil
+	}
+	if !b.sent.Claim(msg.AppID, msg.ID) {
+		slog.Debug("Skipping message already forwarded", "app_id", msg.AppID, "message_id", msg.ID)
+		b.stats.RecordDuplicate()
+		ev.Result = "duplicate"
+		return nil
+	}
+	paused := b.paused.Load()
 
-	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewBuffer(payload))
-	if err != nil {
-		return err
+	appTopic, rule := resolveWith(cfg, store, msg, cfg.Rules().active)
+	if appTopic == "" {
+		if appTopic, rule, err = routeUnknownApp(ctx, b, msg); err != nil {
+			return err
+		}
 	}
+	compareCandidate(b, msg, appTopic)
 
-	// Set the Title header separately (this becomes the notification title)
-	if msg.Title != "" {
-		req.Header.Set("Title", msg.Title)
+	incoming := effectivePriority(cfg, msg.Priority)
+	mapped := cfg.ntfyPriority(messageEnv(cfg, store, msg))
+
+	app, _ := store.Get(msg.AppID)
+	app.ID = msg.AppID
+	profile, hasProfile := cfg.profiles.For(app)
+	if hasProfile && profile.Priority != 0 {
+		dbg(cfg, "Profile overrides ntfy priority: %d -> %d", mapped, profile.Priority)
+		mapped = profile.Priority
+	}
+	ev.App, ev.Topic, ev.Priority, ev.Rule = app.Name, appTopic, mapped, rule
+	critical := isCritical(cfg, store, msg)
+	span.Set("ntfy.topic", appTopic)
+	span.Set("ntfy.priority", mapped)
+	span.Set("critical", critical)
+	slog.Debug("Publishing message", "app_id", msg.AppID, "message_id", msg.ID, "topic", appTopic,
+		"gotify_priority", msg.Priority, "priority", mapped, "critical", critical)
+
+	// Critical messages ignore delivery windows like they ignore pausing
+	outsideWindow := !critical && !profile.window.Open(time.Now())
+	if outsideWindow && profile.OutsideWindow == "drop" {
+		slog.Debug("Dropping message outside the delivery window", "app_id", msg.AppID, "message_id", msg.ID, "window", profile.Window)
+		ev.Result = "outside_window"
+		return nil
 	}
 
-	incoming := msg.Priority
-	if incoming == 0 {
-		incoming = cfg.NtfyPriority
+	title, body := renderMessage(cfg, profile, msg, app, appTopic)
+	if cfg.Metered {
+		body = truncateBody(body, cfg.MeteredMaxBody)
+		if mapped <= meteredBatchMaxPriority && !critical && !paused && !outsideWindow {
+			if b.reserved.Reserved(appTopic) {
+				appTopic = cfg.NtfyTopic
+			}
+			slog.Debug("Batching low-priority message", "app_id", msg.AppID, "message_id", msg.ID, "topic", appTopic)
+			b.batcher.Add(appTopic, title, body, incoming)
+			ev.Result, ev.Topic = "batched", appTopic
+			return nil
+		}
 	}
-	mapped := mapGotifyToNtfyPriority(incoming)
-	req.Header.Set("Priority", fmt.Sprint(mapped))
-	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
-	dbg(cfg, "Mapped priority to ntfy: %d -> %d", incoming, mapped)
 
-	if cfg.NtfyAuthToken != "" {
-		req.Header.Set("Authorization", "Bearer "+cfg.NtfyAuthToken)
-		dbg(cfg, "Using auth token")
+	// Use ONLY the message as the body, not including the title
+	p := ntfyPublish{Topic: appTopic, Title: title, Body: body, Priority: mapped,
+		Actions: snoozeAction(cfg, msg.AppID), Icon: appIconURL(cfg, msg.AppID), TTL: cfg.MessageTTL}
+	if hasProfile {
+		profile.apply(&p)
+	}
+	ev.Topic, ev.Priority = p.Topic, p.Priority
+	p.Headers = gotifyHeaders(cfg, msg, app)
+	attach(ctx, cfg, &p, msg)
+	p.span = span
+	p.msg = &msg
+	if critical {
+		p.Timeout = cfg.CriticalTimeout
 	}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
+	if outsideWindow {
+		slog.Debug("Holding message until the delivery window opens", "app_id", msg.AppID, "message_id", msg.ID, "window", profile.Window)
+		received := msg.received
+		if received.IsZero() {
+			received = time.Now()
+		}
+		b.held.Add(msg.AppID, p, received, profile.window)
+		return errHeld
 	}
-	defer resp.Body.Close()
 
-	dbg(cfg, "ntfy response status: %s", resp.Status)
+	// Keep ordering: while older messages wait for connectivity or forwarding
+	// is paused, queue behind them. A rate limited topic only holds up its own
+	// messages. Critical messages try to go out right away regardless.
+	if (paused || b.buffer.Blocks(p.Topic, b.limited)) && !critical {
+		b.buffer.Add(msg.AppID, p)
+		return errBuffered
+	}
 
-	if resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		dbg(cfg, "ntfy.sh error body: %s", string(body))
-		return fmt.Errorf("ntfy.sh error: %s", resp.Status)
+	announceTopic(ctx, b, p.Topic, app)
+	err = publishTopic(ctx, b, p)
+	if err != nil && critical {
+		escalate(b, p, err)
 	}
-	return nil
+	if errors.Is(err, errCircuitOpen) {
+		slog.Debug("ntfy circuit open, buffering message", "app_id", msg.AppID, "message_id", msg.ID, "topic", appTopic)
+		b.buffer.Add(msg.AppID, p)
+		return errBuffered
+	}
+	if errors.Is(err, errRateLimited) {
+		slog.Debug("Topic rate limited, buffering message", "app_id", msg.AppID, "message_id", msg.ID, "topic", appTopic, "error", err)
+		b.buffer.Add(msg.AppID, p)
+		return errBuffered
+	}
+	if isOffline(err) {
+		slog.Warn("ntfy unreachable, buffering message", "app_id", msg.AppID, "message_id", msg.ID, "topic", appTopic, "error", err)
+		b.buffer.Add(msg.AppID, p)
+		return errBuffered
+	}
+	return err
 }
 
+// stringList is a flag.Value collecting repeated flags in order.
+type stringList []string
+
+func (s *stringList) String() string     { return strings.Join(*s, ",") }
+func (s *stringList) Set(v string) error { *s = append(*s, v); return nil }
+
 func main() {
-	cfg, err := loadConfig()
+	var envFiles stringList
+	flag.Var(&envFiles, "env-file", "load environment from `file` (repeatable, later files override earlier ones)")
+	dryRun := flag.Bool("dry-run", false, "log ntfy requests instead of sending them (same as NTFY_DRY_RUN=true)")
+	observe := flag.Bool("observe", false, "record and measure messages without publishing anything to ntfy (same as OBSERVE_ONLY=true)")
+	showVersion := flag.Bool("version", false, "print version information and exit")
+	chdir := flag.String("chdir", "", "change to `dir` before loading configuration (used by service managers)")
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Println(buildInfo())
+		return
+	}
+	if *chdir != "" {
+		if err := os.Chdir(*chdir); err != nil {
+			log.Fatal(err)
+		}
+	}
+	startServiceHandler()
+
+	// doctor diagnoses configs that loadConfig would reject
+	if args := flag.Args(); len(args) > 0 && args[0] == "doctor" {
+		os.Exit(runDoctor(os.Stdout, envFiles))
+	}
+
+	cfg, err := loadConfig(envFiles)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if *dryRun {
+		cfg.DryRun = true
+	}
+	if cfg.DryRun {
+		log.Printf("Dry-run mode: ntfy requests are logged, not sent")
+	}
+	if *observe {
+		cfg.ObserveOnly = true
+	}
+	if cfg.ObserveOnly {
+		log.Printf("Observer mode: messages are recorded, nothing is published to ntfy")
+	}
 
+	// config show must work offline and without triggering a login
+	if args := flag.Args(); len(args) > 0 && args[0] == "config" {
+		os.Exit(runCommand(context.Background(), cfg, args))
+	}
+
+	// Cancelled on SIGINT/SIGTERM, so shutdown also aborts pending requests
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.GotifyToken == "" {
+		token, err := loginClientToken(ctx, cfg)
+		if err != nil {
+			log.Fatalf("could not obtain Gotify client token for user %s: %v", cfg.GotifyUser, err)
+		}
+		cfg.GotifyToken = token
+		registerSecret(token)
+	}
+
+	if args := flag.Args(); len(args) > 0 {
+		os.Exit(runCommand(ctx, cfg, args))
+	}
+
+	log.Printf("Starting %s", buildInfo())
+	preflight(ctx, cfg)
 	log.Printf("Starting forwarder: Gotify=%s -> ntfy=%s/%s",
-		cfg.GotifyURL, cfg.NtfyURL, cfg.NtfyTopic)
+		redactURL(cfg.GotifyURL), cfg.NtfyURL, cfg.NtfyTopic)
 
 	// Seed apps (best effort)
-	initialApps, err := getApplications(cfg)
+	initialApps, err := getApplications(ctx, cfg)
 	if err != nil {
 		log.Printf("Could not load applications: %v", err)
 	} else {
@@ -531,12 +1757,7 @@ func main() {
 		// Prepare message body for ntfy
 		var lines []string
 		for _, app := range initialApps {
-			if cfg.Debug {
-				log.Printf("- ID=%d Name=%s Description=%s Token=%s", app.ID, app.Name, app.Description, app.Token)
-			} else {
-				masked := strings.Repeat("*", len(app.Token))
-				log.Printf("- ID=%d Name=%s Description=%s Token=%s", app.ID, app.Name, app.Description, masked)
-			}
+			log.Printf("- ID=%d Name=%s Description=%s", app.ID, app.Name, app.Description)
 			// Add name & description to ntfy message
 			lines = append(lines, fmt.Sprintf("- %s: %s", app.Name, app.Description))
 		}
@@ -544,7 +1765,7 @@ func main() {
 		// Send startup message to ntfy
 		body := "Gotify apps on startup:\n" + strings.Join(lines, "\n")
 		title := "Gotify Apps found on startup"
-		if err := sendNtfy(cfg, cfg.NtfyTopic, title, body, 3); err != nil {
+		if err := sendNtfy(ctx, cfg, cfg.NtfyAdminTopic, title, body, 3); err != nil {
 			log.Printf("[NTFY ERROR] failed to send startup message: %v", err)
 		} else {
 			log.Printf("[NTFY] Sent startup message with %d apps", len(initialApps))
@@ -552,24 +1773,121 @@ func main() {
 	}
 
 	store := NewAppStore(initialApps)
+	stats := NewStats(NewSLATracker(cfg.SLADBPath))
+	go runSLATracker(cfg, stats)
 
+	if cfg.StatusDir != "" {
+		go runStatusPage(cfg, store, stats)
+	}
+
+	bridge := NewBridge(ctx, cfg, store, stats)
+	wal, replay, err := OpenWAL(cfg.WALFile)
+	if err != nil {
+		log.Fatalf("could not open WAL_FILE %s: %v", cfg.WALFile, err)
+	}
+	bridge.wal = wal
+	replay = slices.DeleteFunc(replay, func(m GotifyMessage) bool { return bridge.buffer.buffered(m.walSeq) })
 	if cfg.SplitTopics {
-		go syncTopics(cfg, store, cfg.SyncInterval)
+		bridge.appSync = NewAppSync(cfg, store, stats)
+		go syncTopics(ctx, bridge.appSync, cfg.SyncInterval)
+	}
+	go handleSignals(bridge)
+	go runOfflineBuffer(bridge)
+	go runDeliveryWindows(bridge)
+	go runBackfillState(bridge)
+	go runDropAlerts(bridge)
+	go runErrorAlerts(bridge)
+	if cfg.StaleForwardAfter > 0 {
+		go runStaleCheck(bridge)
+	}
+	if len(cfg.ExpectRules) > 0 {
+		go runDeadMan(bridge)
+	}
+	if bridge.tracer != nil {
+		log.Printf("[TRACE] Exporting spans to %s", cfg.OTLPEndpoint)
+		go runTracer(ctx, bridge.tracer)
+	}
+	if cfg.HTTPListen != "" {
+		go runHTTPServer(bridge)
+	}
+	go runWeeklyReport(bridge)
+	if cfg.DailyDigest {
+		go runDailyDigest(bridge)
+	}
+	if cfg.ResourceMonitor {
+		go runResourceMonitor(bridge)
+	}
+	if cfg.HeartbeatInterval > 0 {
+		go runHeartbeat(bridge)
+	}
+	if cfg.ControlTopic != "" {
+		go runControlTopic(bridge)
+	}
+	if cfg.Metered {
+		go runBatcher(ctx, cfg, bridge.batcher)
 	}
+	go runWatchdog(bridge.watchdog)
+	if len(cfg.schedules) > 0 {
+		go runScheduler(bridge)
+	}
+
+	replayWAL(ctx, bridge, replay)
 
 	attempt := 0
+	failovers := 0
+	dialFailures := 0
 	for {
-		err := listenAndForward(cfg, store)
+		bridge.watchdog.Beat()
+		err := listenAndForward(ctx, bridge)
+		if ctx.Err() != nil {
+			log.Printf("Received shutdown signal, stopping")
+			shutdown(bridge)
+			return
+		}
+		if errors.Is(err, errFailback) {
+			log.Printf("Primary Gotify URL %s is reachable again, switching back", cfg.GotifyURL)
+			attempt, failovers, dialFailures = 0, 0, 0
+			bridge.reconnectAttempt.Store(0)
+			continue
+		}
 		if err != nil {
-			log.Printf("connection error: %v", err)
+			slog.Error("Connection error", "error", err)
+			stats.RecordConnectError()
 		}
 
+		if errors.Is(err, errDial) {
+			bridge.outage.Failed(bridge, err)
+		}
+		if !errors.Is(err, errDial) {
+			dialFailures = 0
+		} else if dialFailures++; cfg.MaxReconnectAttempts > 0 && dialFailures >= cfg.MaxReconnectAttempts {
+			log.Printf("Giving up after %d consecutive failed connection attempts (MAX_RECONNECT_ATTEMPTS)", dialFailures)
+			os.Exit(exitReconnectLimit)
+		}
+
+		// Try the remaining Gotify URLs right away before backing off
+		if failovers < len(cfg.GotifyURLs)-1 {
+			failovers++
+			log.Printf("Failing over to Gotify URL %s", cfg.FailoverGotifyURL())
+			continue
+		}
+		failovers = 0
+		cfg.ResetGotifyURL()
+
 		sleep := time.Duration(math.Min(float64(5*int(math.Pow(2, float64(attempt)))), 60)) * time.Second
 		log.Printf("Reconnecting in %v...", sleep)
-		time.Sleep(sleep)
+		select {
+		case <-time.After(sleep):
+		case <-ctx.Done():
+			log.Printf("Received shutdown signal, stopping")
+			shutdown(bridge)
+			return
+		}
+		bridge.watchdog.Beat()
 
 		if attempt < 6 {
 			attempt++
 		}
+		bridge.reconnectAttempt.Store(int32(attempt))
 	}
 }
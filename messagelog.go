@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// messageLogEntry is one line of the MESSAGE_LOG file. Like forwardEvent it
+// never contains message contents.
+type messageLogEntry struct {
+	Time        time.Time `json:"time"`
+	MessageID   int64     `json:"message_id"`
+	AppID       int64     `json:"app_id"`
+	App         string    `json:"app,omitempty"`
+	Topic       string    `json:"topic,omitempty"`
+	Rule        string    `json:"rule,omitempty"`
+	PriorityIn  int       `json:"priority_in"`            // Gotify priority
+	PriorityOut int       `json:"priority_out,omitempty"` // ntfy priority
+	Result      string    `json:"result"`                 // see forwardEvent, or delivered/expired/evicted for buffered messages
+	Error       string    `json:"error,omitempty"`
+	LatencyMS   int64     `json:"latency_ms"` // since the message was read off the stream
+}
+
+// MessageLog appends the outcome of every forwarded message to MESSAGE_LOG
+// as JSON lines, rotating the file once it reaches MESSAGE_LOG_MAX_SIZE.
+// A buffered message gets a second line once it was delivered or dropped.
+type MessageLog struct {
+	mu   sync.Mutex
+	cfg  *Config
+	f    *os.File
+	size int64
+}
+
+func NewMessageLog(cfg *Config) *MessageLog {
+	return &MessageLog{cfg: cfg}
+}
+
+// Record logs the forward result ev of msg.
+func (l *MessageLog) Record(msg GotifyMessage, ev forwardEvent) {
+	if l.cfg.MessageLogFile == "" {
+		return
+	}
+	e := messageLogEntry{
+		Time:        time.Now(),
+		MessageID:   msg.ID,
+		AppID:       msg.AppID,
+		App:         ev.App,
+		Topic:       ev.Topic,
+		Rule:        ev.Rule,
+		PriorityIn:  msg.Priority,
+		PriorityOut: ev.Priority,
+		Result:      ev.Result,
+		Error:       ev.Error,
+	}
+	if !msg.received.IsZero() {
+		e.LatencyMS = time.Since(msg.received).Milliseconds()
+	}
+	if err := l.append(e); err != nil {
+		log.Printf("[MESSAGE LOG ERROR] could not write %s: %v", l.cfg.MessageLogFile, err)
+	}
+}
+
+// recordBuffered logs what became of a message from the offline buffer.
+func (l *MessageLog) recordBuffered(b *Bridge, m bufferedMsg, result string, err error) {
+	if m.Publish.msg == nil {
+		return
+	}
+	l.Record(*m.Publish.msg, bufferedEvent(b, m, result, err))
+}
+
+// bufferedEvent describes the outcome of delivering a buffered message.
+func bufferedEvent(b *Bridge, m bufferedMsg, result string, err error) forwardEvent {
+	app, _ := b.store.Get(m.AppID)
+	ev := forwardEvent{Time: time.Now(), AppID: m.AppID, App: app.Name, Topic: m.Publish.Topic, Priority: m.Publish.Priority, Result: result}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	return ev
+}
+
+func (l *MessageLog) append(e messageLogEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		if err := l.open(); err != nil {
+			return err
+		}
+	}
+	if max := l.cfg.MessageLogMaxSize; max > 0 && l.size > 0 && l.size+int64(len(line)) > max {
+		if err := l.rotate(); err != nil {
+			return fmt.Errorf("rotate: %w", err)
+		}
+	}
+	n, err := l.f.Write(line)
+	l.size += int64(n)
+	return err
+}
+
+func (l *MessageLog) open() error {
+	f, err := os.OpenFile(l.cfg.MessageLogFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	l.f, l.size = f, info.Size()
+	return nil
+}
+
+// rotate renames the log to .1, shifting older files up to
+// MESSAGE_LOG_BACKUPS and removing the oldest, then starts a new file.
+func (l *MessageLog) rotate() error {
+	path := l.cfg.MessageLogFile
+	if err := l.f.Close(); err != nil {
+		log.Printf("[MESSAGE LOG ERROR] could not close %s: %v", path, err)
+	}
+	l.f = nil
+	backups := l.cfg.MessageLogBackups
+	if backups < 1 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return l.open()
+	}
+	_ = os.Remove(fmt.Sprintf("%s.%d", path, backups))
+	for i := backups - 1; i >= 1; i-- {
+		old := fmt.Sprintf("%s.%d", path, i)
+		if err := os.Rename(old, fmt.Sprintf("%s.%d", path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(path, path+".1"); err != nil {
+		return err
+	}
+	return l.open()
+}
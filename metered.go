@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// meteredBatchMaxPriority is the highest ntfy priority that is batched in
+// metered mode; anything above is sent immediately.
+const meteredBatchMaxPriority = 2
+
+// truncateBody shortens s to at most max bytes without splitting a UTF-8
+// sequence. A max of 0 disables truncation.
+func truncateBody(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "…"
+}
+
+type batchEntry struct {
+	at       time.Time
+	title    string
+	body     string
+	priority int // Gotify priority
+}
+
+// Batcher collects low-priority messages per topic and publishes them as a
+// single combined notification, trading latency for data usage.
+type Batcher struct {
+	mu      sync.Mutex
+	pending map[string][]batchEntry
+}
+
+func NewBatcher() *Batcher {
+	return &Batcher{pending: make(map[string][]batchEntry)}
+}
+
+func (b *Batcher) Add(topic, title, body string, priority int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending[topic] = append(b.pending[topic], batchEntry{at: time.Now(), title: title, body: body, priority: priority})
+}
+
+// Flush publishes one combined message per topic with pending entries.
+func (b *Batcher) Flush(ctx context.Context, cfg *Config) {
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = make(map[string][]batchEntry)
+	b.mu.Unlock()
+
+	topics := make([]string, 0, len(pending))
+	for topic := range pending {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	for _, topic := range topics {
+		entries := pending[topic]
+		priority := 0
+		lines := make([]string, 0, len(entries))
+		for _, e := range entries {
+			if e.priority > priority {
+				priority = e.priority
+			}
+			line := e.at.Format("15:04")
+			if e.title != "" {
+				line += " " + e.title + ":"
+			}
+			lines = append(lines, line+" "+e.body)
+		}
+
+		title := fmt.Sprintf("%d low-priority messages", len(entries))
+		if err := sendNtfy(ctx, cfg, topic, title, strings.Join(lines, "\n"), priority); err != nil {
+			log.Printf("[METERED ERROR] failed to send batch of %d messages to %s: %v", len(entries), topic, err)
+		} else {
+			dbg(cfg, "[METERED] Sent batch of %d messages to %s", len(entries), topic)
+		}
+	}
+}
+
+func runBatcher(ctx context.Context, cfg *Config, b *Batcher) {
+	ticker := time.NewTicker(cfg.MeteredBatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return // shutdown flushes what is left
+		}
+		b.Flush(ctx, cfg)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Upper bounds in seconds of the latency histograms
+var (
+	ntfyLatencyBuckets    = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	queueWaitBuckets      = []float64{0.001, 0.01, 0.1, 0.5, 1, 5, 10, 30}
+	forwardLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+)
+
+// histogram is a cumulative Prometheus-style histogram. It is not safe for
+// concurrent use; Stats guards it with its mutex.
+type histogram struct {
+	bounds []float64
+	counts []int64 // per bucket, not cumulative
+	count  int64
+	sum    float64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]int64, len(bounds))}
+}
+
+func (h *histogram) Observe(v float64) {
+	h.count++
+	h.sum += v
+	for i, b := range h.bounds {
+		if v <= b {
+			h.counts[i]++
+			return
+		}
+	}
+}
+
+type topicStats struct {
+	published int64
+	failed    int64
+	observed  int64 // not published, see Config.ObserveOnly
+}
+
+// metricsWriter renders the Prometheus text exposition format.
+type metricsWriter struct {
+	w io.Writer
+}
+
+func (m metricsWriter) header(name, typ, help string) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (m metricsWriter) value(name, labels string, v float64) {
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(m.w, "%s%s %s\n", name, labels, strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+func (m metricsWriter) single(name, typ, help string, v float64) {
+	m.header(name, typ, help)
+	m.value(name, "", v)
+}
+
+func (m metricsWriter) histogram(name, help string, h *histogram) {
+	m.header(name, "histogram", help)
+	var cumulative int64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		m.value(name+"_bucket", label("le", strconv.FormatFloat(bound, 'g', -1, 64)), float64(cumulative))
+	}
+	m.value(name+"_bucket", label("le", "+Inf"), float64(h.count))
+	m.value(name+"_sum", "", h.sum)
+	m.value(name+"_count", "", float64(h.count))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func label(k, v string) string {
+	return k + `="` + labelEscaper.Replace(v) + `"`
+}
+
+// writeMetrics writes the bridge's counters in the Prometheus text format.
+func writeMetrics(w io.Writer, b *Bridge) {
+	s := b.stats
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := metricsWriter{w}
+
+	m.single("gotify_ntfy_messages_received_total", "counter", "Messages read from the Gotify stream.", float64(s.received))
+	m.single("gotify_ntfy_messages_forwarded_total", "counter", "Messages forwarded to ntfy.", float64(s.forwarded))
+	m.single("gotify_ntfy_messages_failed_total", "counter", "Messages ntfy rejected or that could not be forwarded.", float64(s.forwardErrs))
+	m.single("gotify_ntfy_messages_dropped_total", "counter", "Messages dropped because the stream queue was full.", float64(s.dropped))
+	m.single("gotify_ntfy_messages_expired_total", "counter", "Buffered messages dropped after their TTL.", float64(s.expired))
+	m.single("gotify_ntfy_messages_duplicate_total", "counter", "Duplicate messages suppressed.", float64(s.duplicates))
+	m.single("gotify_ntfy_candidate_rules_evaluated_total", "counter", "Messages also resolved with the candidate topic rules.", float64(s.candidateEvals))
+	m.single("gotify_ntfy_candidate_rules_differed_total", "counter", "Messages the candidate topic rules would send to another topic.", float64(s.candidateDiffs))
+	m.single("gotify_ntfy_offline_buffer_messages", "gauge", "Messages waiting in the offline buffer.", float64(b.buffer.Len()))
+	oldestAge := 0.0
+	if oldest, ok := b.buffer.Oldest(); ok {
+		oldestAge = time.Since(oldest).Seconds()
+	}
+	m.single("gotify_ntfy_offline_buffer_oldest_age_seconds", "gauge", "Age of the oldest message in the offline buffer, 0 when empty.", oldestAge)
+	lastForward := 0.0
+	if !s.lastDeliver.IsZero() {
+		lastForward = float64(s.lastDeliver.UnixMilli()) / 1000
+	}
+	m.single("gotify_ntfy_last_forward_timestamp_seconds", "gauge", "Unix time of the last message forwarded to ntfy, 0 if none yet.", lastForward)
+
+	connected := 0.0
+	if s.connected {
+		connected = 1
+	}
+	m.single("gotify_ntfy_gotify_connected", "gauge", "Whether the Gotify stream is connected.", connected)
+	m.single("gotify_ntfy_reconnects_total", "counter", "Gotify stream connections after the first one.", float64(max(s.connects-1, 0)))
+	m.single("gotify_ntfy_connect_errors_total", "counter", "Gotify stream connection errors.", float64(s.connectErrs))
+
+	m.header("gotify_ntfy_app_sync_total", "counter", "Gotify application syncs by result.")
+	m.value("gotify_ntfy_app_sync_total", label("result", "success"), float64(s.syncs))
+	m.value("gotify_ntfy_app_sync_total", label("result", "error"), float64(s.syncErrs))
+
+	topics := make([]string, 0, len(s.topics))
+	for t := range s.topics {
+		topics = append(topics, t)
+	}
+	sort.Strings(topics)
+	m.header("gotify_ntfy_topic_messages_total", "counter", "Messages per ntfy topic by result (success, error, or observed in observer mode).")
+	for _, t := range topics {
+		m.value("gotify_ntfy_topic_messages_total", label("topic", t)+","+label("result", "success"), float64(s.topics[t].published))
+		m.value("gotify_ntfy_topic_messages_total", label("topic", t)+","+label("result", "error"), float64(s.topics[t].failed))
+		if s.topics[t].observed > 0 {
+			m.value("gotify_ntfy_topic_messages_total", label("topic", t)+","+label("result", "observed"), float64(s.topics[t].observed))
+		}
+	}
+
+	m.histogram("gotify_ntfy_ntfy_request_duration_seconds", "Duration of ntfy publish requests.", s.ntfyLatency)
+	m.histogram("gotify_ntfy_queue_wait_seconds", "Time stream messages waited for a worker.", s.queueWait)
+	m.histogram("gotify_ntfy_forward_duration_seconds", "Time from reading a message off the stream until ntfy accepted it.", s.forwardLatency)
+	m.single("gotify_ntfy_slow_forwards_total", "counter", "Messages forwarded slower than SLOW_FORWARD_THRESHOLD.", float64(s.slowForwards))
+}
+
+func handleMetrics(b *Bridge) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeMetrics(w, b)
+	}
+}
+
+// topicCounts copies the per-topic publish counters for /debug/vars.
+func (s *Stats) topicCounts() map[string]map[string]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]map[string]int64, len(s.topics))
+	for t, c := range s.topics {
+		out[t] = map[string]int64{"published": c.published, "failed": c.failed, "observed": c.observed}
+	}
+	return out
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// httpTimeout bounds every outbound REST call to Gotify and ntfy.
+const httpTimeout = 10 * time.Second
+
+// parseProxy validates an optional proxy URL (http, https, socks5 or socks5h).
+func parseProxy(name, raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return u, nil
+	default:
+		return nil, fmt.Errorf("invalid %s: unsupported proxy scheme %q", name, u.Scheme)
+	}
+}
+
+// proxyFunc routes through p when set. Otherwise .onion hosts go through the
+// Tor SOCKS proxy and everything else honors the standard
+// HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment variables.
+func proxyFunc(p, tor *url.URL) func(*http.Request) (*url.URL, error) {
+	if p != nil {
+		return http.ProxyURL(p)
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		if tor != nil && strings.HasSuffix(req.URL.Hostname(), ".onion") {
+			return tor, nil
+		}
+		return http.ProxyFromEnvironment(req)
+	}
+}
+
+// wsProxyFunc adapts proxyFunc for the websocket dialer, which only knows the
+// "socks5" scheme. Like net/http, it passes hostnames to the proxy unresolved,
+// so "socks5h" can safely be mapped to it.
+func wsProxyFunc(p, tor *url.URL) func(*http.Request) (*url.URL, error) {
+	proxy := proxyFunc(p, tor)
+	return func(req *http.Request) (*url.URL, error) {
+		u, err := proxy(req)
+		if err != nil || u == nil || u.Scheme != "socks5h" {
+			return u, err
+		}
+		c := *u
+		c.Scheme = "socks5"
+		return &c, nil
+	}
+}
+
+// defaultUserAgent names the bridge and its version, plus the instance if
+// INSTANCE_NAME is set, e.g. "gotify-to-ntfy-push/1.4.0 (nas)".
+func defaultUserAgent(instance string) string {
+	ua := serviceName + "/" + version
+	if instance != "" {
+		ua += " (" + instance + ")"
+	}
+	return ua
+}
+
+// userAgentTransport sets the User-Agent on requests that do not set one.
+type userAgentTransport struct {
+	base http.RoundTripper
+	ua   string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") != "" {
+		return t.base.RoundTrip(req)
+	}
+	// RoundTrippers must not modify the caller's request
+	r := req.Clone(req.Context())
+	r.Header.Set("User-Agent", t.ua)
+	return t.base.RoundTrip(r)
+}
+
+func newHTTPClient(p, tor *url.URL, ua string) *http.Client {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.Proxy = proxyFunc(p, tor)
+	return &http.Client{Timeout: httpTimeout, Transport: &userAgentTransport{base: tr, ua: ua}}
+}
+
+// setupClients builds the outbound HTTP clients and websocket dialer once,
+// so connections are pooled and proxy settings apply everywhere.
+func (c *Config) setupClients() {
+	c.gotifyHTTP = newHTTPClient(c.GotifyProxy, c.TorProxy, c.UserAgent)
+	c.ntfyHTTP = newHTTPClient(c.NtfyProxy, c.TorProxy, c.UserAgent)
+	c.gotifyDialer = &websocket.Dialer{
+		Proxy:            wsProxyFunc(c.GotifyProxy, c.TorProxy),
+		HandshakeTimeout: 45 * time.Second,
+	}
+}
+
+// GotifyHTTP returns the client used for Gotify REST calls.
+func (c *Config) GotifyHTTP() *http.Client { return c.gotifyHTTP }
+
+// NtfyHTTP returns the client used for ntfy publishes.
+func (c *Config) NtfyHTTP() *http.Client { return c.ntfyHTTP }
+
+// GotifyDialer returns the dialer used for the Gotify websocket stream.
+func (c *Config) GotifyDialer() *websocket.Dialer { return c.gotifyDialer }
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ntfyUnavailable reports whether a publish error means ntfy itself is down
+// or overloaded, as opposed to refusing this particular message. A 429 only
+// pauses its topic (see TopicPauses) and never opens the circuit for all.
+func ntfyUnavailable(err error) bool {
+	var statusErr *ntfyStatusError
+	if !errors.As(err, &statusErr) {
+		return err != nil
+	}
+	return statusErr.Code >= 500
+}
+
+// errCircuitOpen is returned instead of publishing while ntfy is down and
+// the circuit breaker waits for the next probe.
+var errCircuitOpen = errors.New("ntfy circuit open, not publishing until the next probe")
+
+// NtfyMonitor tracks consecutive failed publishes. After NTFY_DOWN_ATTEMPTS
+// of them ntfy counts as down: the outage is logged, /readyz fails, and once
+// a publish succeeds again a summary of the messages queued for retry and
+// lost meanwhile goes to the error topic.
+//
+// While ntfy is down the monitor is also a circuit breaker: messages are not
+// published but buffered, except for one probe every NTFY_CIRCUIT_COOLDOWN.
+// A successful probe closes the circuit.
+type NtfyMonitor struct {
+	mu        sync.Mutex
+	failures  int
+	since     time.Time // first failure of the current streak
+	lastErr   error
+	down      bool
+	base      ntfyOutageCounters
+	threshold int
+
+	cooldown  time.Duration // 0 disables the circuit breaker
+	openUntil time.Time
+	probing   bool
+}
+
+// ntfyOutageCounters are the totals an outage summary is computed from.
+type ntfyOutageCounters struct {
+	buffered, evicted uint64
+	failed, expired   int64
+}
+
+func NewNtfyMonitor(threshold int, cooldown time.Duration) *NtfyMonitor {
+	return &NtfyMonitor{threshold: threshold, cooldown: cooldown}
+}
+
+// Allow returns errCircuitOpen if a publish must not go to ntfy now. Once
+// the cooldown has passed it lets a single probe through.
+func (m *NtfyMonitor) Allow() error {
+	if m.cooldown <= 0 {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.down {
+		return nil
+	}
+	if m.probing || time.Now().Before(m.openUntil) {
+		return errCircuitOpen
+	}
+	m.probing = true
+	return nil
+}
+
+func currentOutageCounters(b *Bridge) ntfyOutageCounters {
+	var c ntfyOutageCounters
+	c.buffered, c.evicted = b.buffer.Totals()
+	c.failed, c.expired = b.stats.lossCounts()
+	return c
+}
+
+// Published records the outcome of a publish to ntfy. An error that ntfy
+// answered itself, such as a 403, shows it is reachable and counts as a
+// success here.
+func (m *NtfyMonitor) Published(b *Bridge, err error) {
+	if m.threshold <= 0 {
+		return
+	}
+	if !ntfyUnavailable(err) {
+		err = nil
+	}
+	m.mu.Lock()
+	if err != nil {
+		m.failures++
+		m.lastErr = err
+		if m.since.IsZero() {
+			m.since = time.Now()
+			m.base = currentOutageCounters(b)
+		}
+		if m.down {
+			m.openUntil, m.probing = time.Now().Add(m.cooldown), false
+		}
+		if m.down || m.failures < m.threshold {
+			m.mu.Unlock()
+			return
+		}
+		m.down = true
+		m.openUntil, m.probing = time.Now().Add(m.cooldown), false
+		since, failures := m.since, m.failures
+		m.mu.Unlock()
+		circuit := ""
+		if m.cooldown > 0 {
+			circuit = fmt.Sprintf(", probing every %s", formatDuration(m.cooldown))
+		}
+		log.Printf("[NTFY ERROR] ===== ntfy unavailable: %d consecutive publishes failed since %s, messages are buffered for retry%s (last error: %v) =====",
+			failures, since.Format("15:04:05"), circuit, err)
+		return
+	}
+
+	wasDown, since, failures, base := m.down, m.since, m.failures, m.base
+	m.failures, m.since, m.lastErr, m.down, m.probing = 0, time.Time{}, nil, false, false
+	m.mu.Unlock()
+	if !wasDown {
+		return
+	}
+
+	now := currentOutageCounters(b)
+	retried := now.buffered - base.buffered
+	lost := int64(now.evicted-base.evicted) + (now.failed - base.failed) + (now.expired - base.expired)
+	duration := formatDuration(time.Since(since).Round(time.Second))
+	log.Printf("[NTFY] ntfy available again after %s (%d failed publishes): %d messages queued for retry, %d lost",
+		duration, failures, retried, lost)
+	body := fmt.Sprintf("ntfy was unavailable for %s (%d failed publishes, since %s).\n"+
+		"Queued for retry: %d\nLost (failed, expired or evicted from a full buffer): %d",
+		duration, failures, since.Format("2006-01-02 15:04:05 MST"), retried, lost)
+	if err := sendNtfy(b.ctx, b.cfg, b.cfg.NtfyErrorTopic, "ntfy available again", body, 4); err != nil {
+		log.Printf("[NTFY ERROR] failed to send ntfy recovery summary: %v", err)
+	}
+}
+
+// Down reports whether ntfy is considered unavailable, and why.
+func (m *NtfyMonitor) Down() (bool, string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.down {
+		return false, ""
+	}
+	return true, fmt.Sprintf("ntfy unavailable for %s (%d consecutive publishes failed: %v)",
+		time.Since(m.since).Round(time.Second), m.failures, m.lastErr)
+}
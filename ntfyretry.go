@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// ntfyRetryable reports whether a failed publish is worth repeating right
+// away: the request did not get through, or a reverse proxy in front of
+// ntfy answered for it (502, 503, 504). Other statuses come from ntfy
+// itself and would fail again.
+func ntfyRetryable(err error) bool {
+	var statusErr *ntfyStatusError
+	if !errors.As(err, &statusErr) {
+		return err != nil
+	}
+	switch statusErr.Code {
+	case 502, 503, 504:
+		return true
+	}
+	return false
+}
+
+// ntfyRetryDelay is the wait before retry number attempt (1 for the first):
+// NTFY_RETRY_BASE_DELAY doubled per attempt, randomly shifted by up to
+// NTFY_RETRY_JITTER of itself so publishes failing together do not retry
+// in lockstep.
+func ntfyRetryDelay(cfg *Config, attempt int) time.Duration {
+	d := cfg.NtfyRetryBaseDelay << (attempt - 1)
+	if cfg.NtfyRetryJitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * cfg.NtfyRetryJitter * float64(d))
+	}
+	return d
+}
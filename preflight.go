@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// preflightResult is one row of the preflight table.
+type preflightResult struct {
+	Check  string
+	Status string // PASS, FAIL or SKIP
+	Detail string
+}
+
+// preflightGet performs a GET and describes the outcome; ok is true on 200.
+func preflightGet(ctx context.Context, client *http.Client, endpoint string, header http.Header) (ok bool, detail string) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return false, err.Error()
+	}
+	req.Header = header
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err.Error()
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, endpoint + ": " + resp.Status
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return false, endpoint + ": " + resp.Status + " (token rejected)"
+	default:
+		return false, endpoint + ": " + resp.Status
+	}
+}
+
+// runPreflight checks that Gotify and ntfy are reachable and accept the
+// configured credentials, before the bridge enters its reconnect loop.
+func runPreflight(ctx context.Context, cfg *Config) []preflightResult {
+	var results []preflightResult
+	add := func(check string, ok bool, detail string) {
+		status := "PASS"
+		if !ok {
+			status = "FAIL"
+		}
+		results = append(results, preflightResult{check, status, detail})
+	}
+
+	if u, err := gotifyAPIURL(cfg, "/health"); err != nil {
+		add("Gotify REST", false, err.Error())
+	} else {
+		ok, detail := preflightGet(ctx, cfg.GotifyHTTP(), u, http.Header{})
+		add("Gotify REST", ok, detail)
+	}
+
+	if u, err := gotifyAPIURL(cfg, "/application"); err != nil {
+		add("Gotify token", false, err.Error())
+	} else {
+		ok, detail := preflightGet(ctx, cfg.GotifyHTTP(), u, http.Header{"X-Gotify-Key": {cfg.GotifyToken}})
+		add("Gotify token", ok, detail)
+	}
+
+	ntfyBase := strings.TrimRight(cfg.NtfyURL, "/")
+	ok, detail := preflightGet(ctx, cfg.NtfyHTTP(), ntfyBase+"/v1/health", http.Header{})
+	add("ntfy server", ok, detail)
+
+	if cfg.NtfyAuthToken == "" {
+		results = append(results, preflightResult{"ntfy auth", "SKIP", "NTFY_AUTH_TOKEN not set"})
+	} else {
+		ok, detail := preflightGet(ctx, cfg.NtfyHTTP(), ntfyBase+"/v1/account",
+			http.Header{"Authorization": {"Bearer " + cfg.NtfyAuthToken}})
+		add("ntfy auth", ok, detail)
+	}
+
+	return results
+}
+
+// printPreflight writes the results as a table and reports whether all passed.
+func printPreflight(w io.Writer, results []preflightResult) bool {
+	passed := true
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tRESULT\tDETAIL")
+	for _, r := range results {
+		if r.Status == "FAIL" {
+			passed = false
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Check, r.Status, r.Detail)
+	}
+	_ = tw.Flush()
+	return passed
+}
+
+// preflight runs the checks when PREFLIGHT is enabled and exits on failure
+// if PREFLIGHT_STRICT is set.
+func preflight(ctx context.Context, cfg *Config) {
+	if !cfg.Preflight {
+		return
+	}
+	if printPreflight(os.Stderr, runPreflight(ctx, cfg)) || !cfg.PreflightStrict {
+		return
+	}
+	fmt.Fprintln(os.Stderr, "preflight checks failed, exiting (PREFLIGHT_STRICT=true)")
+	os.Exit(1)
+}
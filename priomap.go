@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PRIORITY_ZERO policies for messages sent with Gotify priority 0, which
+// many clients use when no priority is set.
+const (
+	zeroPriorityDefault = "default" // replaced by NTFY_PRIORITY, then mapped
+	zeroPriorityMin     = "min"     // ntfy priority 1, whatever the mapping
+	zeroPriorityDrop    = "drop"    // not forwarded
+)
+
+func parseZeroPriority(s string) (string, error) {
+	switch policy := strings.ToLower(strings.TrimSpace(s)); policy {
+	case "":
+		return zeroPriorityDefault, nil
+	case zeroPriorityDefault, zeroPriorityMin, zeroPriorityDrop:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid PRIORITY_ZERO %q (want default, min or drop)", s)
+}
+
+// dropsPriority reports whether messages with Gotify priority p are dropped
+// under PRIORITY_ZERO=drop.
+func (c *Config) dropsPriority(p int) bool {
+	return p <= 0 && c.ZeroPriority == zeroPriorityDrop
+}
+
+// priorityMapping turns a message into an ntfy priority 1–5. The Gotify
+// priority in the env has PRIORITY_ZERO applied already. PRIORITY_MAPPING
+// selects the implementation.
+type priorityMapping interface {
+	Map(e topicEnv) int
+}
+
+// linearMapping scales Gotify's 0–10 evenly onto ntfy's 1–5, the default.
+type linearMapping struct{}
+
+func (linearMapping) Map(e topicEnv) int { return mapGotifyToNtfyPriority(e.Priority) }
+
+// passthroughMapping uses the Gotify priority as the ntfy priority, clamped
+// to 1–5, for senders that already use ntfy's scale.
+type passthroughMapping struct{}
+
+func (passthroughMapping) Map(e topicEnv) int { return min(max(e.Priority, 1), 5) }
+
+// bandedMapping looks the Gotify priority up in a table of ranges
+// (PRIORITY_BANDS). Priorities outside every band are mapped linearly.
+type bandedMapping struct {
+	bands [11]int // Gotify 0–10 -> ntfy priority, 0 if unset
+}
+
+func (m bandedMapping) Map(e topicEnv) int {
+	if e.Priority >= 0 && e.Priority < len(m.bands) && m.bands[e.Priority] != 0 {
+		return m.bands[e.Priority]
+	}
+	return mapGotifyToNtfyPriority(e.Priority)
+}
+
+// parsePriorityBands parses "from-to:ntfy" entries separated by commas,
+// e.g. "0-3:2, 4-7:3, 8-10:5". A single priority may omit the range.
+func parsePriorityBands(s string) (bandedMapping, error) {
+	var m bandedMapping
+	for _, band := range strings.Split(s, ",") {
+		band = strings.TrimSpace(band)
+		if band == "" {
+			continue
+		}
+		span, target, ok := strings.Cut(band, ":")
+		if !ok {
+			return m, fmt.Errorf("band %q: missing \":ntfy priority\"", band)
+		}
+		fromStr, toStr, isRange := strings.Cut(span, "-")
+		if !isRange {
+			toStr = fromStr
+		}
+		from, err1 := strconv.Atoi(strings.TrimSpace(fromStr))
+		to, err2 := strconv.Atoi(strings.TrimSpace(toStr))
+		if err1 != nil || err2 != nil || from < 0 || to > 10 || from > to {
+			return m, fmt.Errorf("band %q: invalid Gotify priority range, expected e.g. 4-7 within 0-10", band)
+		}
+		p, err := parseNtfyPriority(target)
+		if err != nil {
+			return m, fmt.Errorf("band %q: %w", band, err)
+		}
+		for i := from; i <= to; i++ {
+			m.bands[i] = p
+		}
+	}
+	return m, nil
+}
+
+// expressionMapping picks the priority with rules in the TOPIC_RULES syntax
+// whose target is an ntfy priority (PRIORITY_EXPRESSION), e.g.
+// "app == Backup -> 2; priority >= 8 -> 5; * -> 3". Messages no rule
+// matches are mapped linearly.
+type expressionMapping struct {
+	rules TopicRules
+}
+
+func (m expressionMapping) Map(e topicEnv) int {
+	if target, ok := m.rules.Resolve(e); ok {
+		if p, err := strconv.Atoi(target); err == nil {
+			return p
+		}
+	}
+	return mapGotifyToNtfyPriority(e.Priority)
+}
+
+func parsePriorityExpression(s string) (expressionMapping, error) {
+	rules, err := parseTopicRules(s)
+	if err != nil {
+		return expressionMapping{}, err
+	}
+	for _, r := range rules {
+		if _, err := parseNtfyPriority(r.Topic); err != nil {
+			return expressionMapping{}, fmt.Errorf("rule %q: %w", r.Source, err)
+		}
+	}
+	return expressionMapping{rules: rules}, nil
+}
+
+func parseNtfyPriority(s string) (int, error) {
+	p, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || p < 1 || p > 5 {
+		return 0, fmt.Errorf("invalid ntfy priority %q, expected 1-5", strings.TrimSpace(s))
+	}
+	return p, nil
+}
+
+// parsePriorityMapping builds the PRIORITY_MAPPING strategy, reading its
+// table or rules from PRIORITY_BANDS or PRIORITY_EXPRESSION.
+func parsePriorityMapping(name, bands, expression string) (priorityMapping, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "linear":
+		return linearMapping{}, nil
+	case "passthrough":
+		return passthroughMapping{}, nil
+	case "banded":
+		if bands == "" {
+			return nil, fmt.Errorf("PRIORITY_MAPPING=banded needs PRIORITY_BANDS")
+		}
+		m, err := parsePriorityBands(bands)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PRIORITY_BANDS: %w", err)
+		}
+		return m, nil
+	case "expression":
+		if expression == "" {
+			return nil, fmt.Errorf("PRIORITY_MAPPING=expression needs PRIORITY_EXPRESSION")
+		}
+		m, err := parsePriorityExpression(expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PRIORITY_EXPRESSION: %w", err)
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("invalid PRIORITY_MAPPING %q (want linear, passthrough, banded or expression)", name)
+}
+
+// ntfyPriority maps a message's priority with the configured strategy.
+func (c *Config) ntfyPriority(e topicEnv) int {
+	if e.Priority <= 0 && c.ZeroPriority == zeroPriorityMin {
+		return 1
+	}
+	if c.prioMapper == nil {
+		return mapGotifyToNtfyPriority(e.Priority)
+	}
+	return c.prioMapper.Map(e)
+}
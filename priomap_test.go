@@ -0,0 +1,130 @@
+package main
+
+import "testing"
+
+func TestLinearMapping(t *testing.T) {
+	// Gotify 0–10 onto ntfy 1–5
+	want := []int{1, 1, 2, 2, 3, 3, 3, 4, 4, 5, 5}
+	for p, w := range want {
+		if got := (linearMapping{}).Map(topicEnv{Priority: p}); got != w {
+			t.Errorf("priority %d: got %d, want %d", p, got, w)
+		}
+	}
+	for _, p := range []int{-3, 15} {
+		if got := (linearMapping{}).Map(topicEnv{Priority: p}); got < 1 || got > 5 {
+			t.Errorf("priority %d: got %d, want within 1-5", p, got)
+		}
+	}
+}
+
+func TestPassthroughMapping(t *testing.T) {
+	tests := []struct{ in, want int }{
+		{-1, 1}, {0, 1}, {1, 1}, {3, 3}, {5, 5}, {6, 5}, {10, 5},
+	}
+	for _, tt := range tests {
+		if got := (passthroughMapping{}).Map(topicEnv{Priority: tt.in}); got != tt.want {
+			t.Errorf("priority %d: got %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBandedMapping(t *testing.T) {
+	m, err := parsePriorityBands("0-3:2, 4-7:3, 9:5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct{ in, want int }{
+		{0, 2}, {3, 2}, {4, 3}, {7, 3},
+		{8, 4}, // outside every band: linear
+		{9, 5},
+		{10, 5}, // linear
+		{-1, 1}, // out of table range: linear
+	}
+	for _, tt := range tests {
+		if got := m.Map(topicEnv{Priority: tt.in}); got != tt.want {
+			t.Errorf("priority %d: got %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParsePriorityBandsErrors(t *testing.T) {
+	for _, s := range []string{
+		"0-3",    // no target
+		"0-3:6",  // ntfy priority out of range
+		"0-3:x",  // not a number
+		"3-0:2",  // reversed range
+		"0-11:2", // beyond Gotify's scale
+		"a-3:2",
+	} {
+		if _, err := parsePriorityBands(s); err == nil {
+			t.Errorf("parsePriorityBands(%q): want an error", s)
+		}
+	}
+}
+
+func TestExpressionMapping(t *testing.T) {
+	m, err := parsePriorityExpression(`app == Backup -> 2; priority >= 8 -> 5; title contains disk -> 4`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		env  topicEnv
+		want int
+	}{
+		{topicEnv{App: "Backup", Priority: 9}, 2}, // first match wins
+		{topicEnv{App: "Proxmox", Priority: 8}, 5},
+		{topicEnv{App: "Proxmox", Priority: 3, Title: "Disk full"}, 4},
+		{topicEnv{App: "Proxmox", Priority: 4}, 3}, // no rule: linear
+	}
+	for _, tt := range tests {
+		if got := m.Map(tt.env); got != tt.want {
+			t.Errorf("%+v: got %d, want %d", tt.env, got, tt.want)
+		}
+	}
+
+	if _, err := parsePriorityExpression("* -> 7"); err == nil {
+		t.Error("target outside 1-5: want an error")
+	}
+}
+
+func TestParsePriorityMapping(t *testing.T) {
+	tests := []struct {
+		name, bands, expression string
+		want                    priorityMapping
+		wantErr                 bool
+	}{
+		{name: "", want: linearMapping{}},
+		{name: "Linear", want: linearMapping{}},
+		{name: "passthrough", want: passthroughMapping{}},
+		{name: "banded", wantErr: true}, // needs PRIORITY_BANDS
+		{name: "expression", wantErr: true},
+		{name: "logarithmic", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parsePriorityMapping(tt.name, tt.bands, tt.expression)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("%q: got %T, want %T", tt.name, got, tt.want)
+		}
+	}
+	if m, err := parsePriorityMapping("banded", "0-10:3", ""); err != nil || m.Map(topicEnv{Priority: 10}) != 3 {
+		t.Errorf("banded with bands: got %v, %v", m, err)
+	}
+	if m, err := parsePriorityMapping("expression", "", "* -> 1"); err != nil || m.Map(topicEnv{Priority: 10}) != 1 {
+		t.Errorf("expression with rules: got %v, %v", m, err)
+	}
+}
+
+func TestZeroPriority(t *testing.T) {
+	cfg := &Config{ZeroPriority: zeroPriorityMin, prioMapper: passthroughMapping{}}
+	if got := cfg.ntfyPriority(topicEnv{Priority: 0}); got != 1 {
+		t.Errorf("PRIORITY_ZERO=min: got %d, want 1", got)
+	}
+	cfg.ZeroPriority = zeroPriorityDrop
+	if !cfg.dropsPriority(0) || cfg.dropsPriority(1) {
+		t.Error("PRIORITY_ZERO=drop should drop priority 0 only")
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Profile is a named set of ntfy presentation settings shared by several apps.
+type Profile struct {
+	Tags     []string `json:"tags,omitempty"`
+	Priority int      `json:"priority,omitempty"` // ntfy priority 1–5, overrides the mapped one
+	Markdown bool     `json:"markdown,omitempty"`
+	Icon     string   `json:"icon,omitempty"`
+	TTL      string   `json:"ttl,omitempty"` // e.g. "30m", overrides MESSAGE_TTL
+
+	// Override NTFY_TITLE_TEMPLATE and NTFY_BODY_TEMPLATE, see templates.go
+	TitleTemplate string `json:"title_template,omitempty"`
+	BodyTemplate  string `json:"body_template,omitempty"`
+
+	// Deliver only within e.g. "09:00-21:00", local time; outside it messages
+	// are held until it opens, or dropped with OutsideWindow "drop"
+	Window        string `json:"window,omitempty"`
+	OutsideWindow string `json:"outside_window,omitempty"` // hold (default) or drop
+
+	ttl               time.Duration
+	titleTpl, bodyTpl *template.Template
+	window            *deliveryWindow
+}
+
+// ProfileSet is the content of NTFY_PROFILES_FILE. Apps are keyed by Gotify
+// app ID or by app name; "*" applies to apps without an entry.
+type ProfileSet struct {
+	Profiles map[string]Profile `json:"profiles"`
+	Apps     map[string]string  `json:"apps"`
+}
+
+func loadProfiles(path string) (*ProfileSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ps ProfileSet
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&ps); err != nil {
+		return nil, err
+	}
+	for name, p := range ps.Profiles {
+		if p.Priority != 0 && (p.Priority < 1 || p.Priority > 5) {
+			return nil, fmt.Errorf("profile %q: priority must be 1-5", name)
+		}
+		if p.TTL != "" {
+			d, err := time.ParseDuration(p.TTL)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("profile %q: invalid ttl %q, expected e.g. 30m", name, p.TTL)
+			}
+			p.ttl = d
+		}
+		var err error
+		if p.titleTpl, err = parseMessageTemplate("title", p.TitleTemplate); err != nil {
+			return nil, fmt.Errorf("profile %q: title_template: %w", name, err)
+		}
+		if p.bodyTpl, err = parseMessageTemplate("body", p.BodyTemplate); err != nil {
+			return nil, fmt.Errorf("profile %q: body_template: %w", name, err)
+		}
+		if p.Window != "" {
+			if p.window, err = parseDeliveryWindow(p.Window); err != nil {
+				return nil, fmt.Errorf("profile %q: %w", name, err)
+			}
+		}
+		switch p.OutsideWindow {
+		case "", "hold", "drop":
+		default:
+			return nil, fmt.Errorf("profile %q: invalid outside_window %q, expected hold or drop", name, p.OutsideWindow)
+		}
+		ps.Profiles[name] = p
+	}
+	for app, name := range ps.Apps {
+		if _, ok := ps.Profiles[name]; !ok {
+			return nil, fmt.Errorf("app %q refers to unknown profile %q", app, name)
+		}
+	}
+	return &ps, nil
+}
+
+// For returns the profile assigned to app, by ID first, then by name
+// (case-insensitive), then the "*" default.
+func (ps *ProfileSet) For(app GotifyApp) (Profile, bool) {
+	if ps == nil {
+		return Profile{}, false
+	}
+	name, ok := ps.Apps[strconv.FormatInt(app.ID, 10)]
+	if !ok && app.Name != "" {
+		for key, n := range ps.Apps {
+			if strings.EqualFold(key, app.Name) {
+				name, ok = n, true
+				break
+			}
+		}
+	}
+	if !ok {
+		name, ok = ps.Apps["*"]
+	}
+	if !ok {
+		return Profile{}, false
+	}
+	return ps.Profiles[name], true
+}
+
+// apply copies the profile's settings onto p.
+func (pr Profile) apply(p *ntfyPublish) {
+	if pr.Priority != 0 {
+		p.Priority = pr.Priority
+	}
+	p.Tags = pr.Tags
+	p.Markdown = pr.Markdown
+	if pr.Icon != "" {
+		p.Icon = pr.Icon
+	}
+	if pr.ttl > 0 {
+		p.TTL = pr.ttl
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// runQueue inspects or empties the offline buffer kept in OFFLINE_BUFFER_FILE.
+// A running bridge holds the buffer in memory and rewrites the file whenever
+// it changes, so purge is meant for a stopped bridge.
+func runQueue(cfg *Config, args []string) int {
+	if cfg.OfflineBufferFile == "" {
+		fmt.Fprintln(os.Stderr, "OFFLINE_BUFFER_FILE is not set, the offline buffer is kept in memory only")
+		return 1
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: queue ls | queue purge [-older-than 24h] [-topic name]")
+		return 2
+	}
+	switch args[0] {
+	case "ls":
+		return runQueueList(cfg)
+	case "purge":
+		return runQueuePurge(cfg, args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown queue command %q (available: ls, purge)\n", args[0])
+		return 2
+	}
+}
+
+// runQueueList prints the buffered messages, oldest first.
+func runQueueList(cfg *Config) int {
+	o := NewOfflineBuffer(cfg.OfflineBufferSize, cfg.OfflineBufferFile)
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RECEIVED\tAGE\tAPP ID\tTOPIC\tPRIORITY\tTITLE")
+	for _, m := range o.items {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d\t%s\n", m.Received.Format("2006-01-02 15:04:05"),
+			formatDuration(now.Sub(m.Received).Round(time.Second)), m.AppID, m.Publish.Topic, m.Publish.Priority, m.Publish.Title)
+	}
+	_ = w.Flush()
+	fmt.Printf("%d messages in %s\n", len(o.items), cfg.OfflineBufferFile)
+	return 0
+}
+
+// runQueuePurge drops buffered messages, all of them or those matching the
+// flags, and completes them in the WAL so they are not replayed either.
+func runQueuePurge(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("queue purge", flag.ExitOnError)
+	olderThan := fs.Duration("older-than", 0, "only purge messages received longer ago than this")
+	topic := fs.String("topic", "", "only purge messages for this ntfy topic")
+	_ = fs.Parse(args)
+
+	o := NewOfflineBuffer(cfg.OfflineBufferSize, cfg.OfflineBufferFile)
+	wal, _, err := OpenWAL(cfg.WALFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not open WAL_FILE %s: %v\n", cfg.WALFile, err)
+		return 1
+	}
+	defer wal.Close()
+
+	now := time.Now()
+	var kept, purged []bufferedMsg
+	for _, m := range o.items {
+		if (*olderThan > 0 && now.Sub(m.Received) < *olderThan) || (*topic != "" && m.Publish.Topic != *topic) {
+			kept = append(kept, m)
+			continue
+		}
+		purged = append(purged, m)
+	}
+	o.items = kept
+	o.save()
+	for _, m := range purged {
+		wal.doneBuffered(m)
+	}
+	fmt.Printf("purged %d of %d messages from %s\n", len(purged), len(purged)+len(kept), cfg.OfflineBufferFile)
+	return 0
+}
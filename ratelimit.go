@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimitDefaultPause is how long a topic is paused after a 429 without
+// a usable Retry-After header.
+const rateLimitDefaultPause = time.Minute
+
+// errRateLimited is returned for publishes ntfy answered with 429 Too Many
+// Requests, and for publishes to a topic paused after one. The message is
+// buffered and delivered once the pause is over.
+var errRateLimited = errors.New("rate limited by ntfy")
+
+// retryAfter parses a Retry-After header, given in seconds or as an HTTP
+// date, or returns 0.
+func retryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+// rateLimitPause returns how long to pause publishing after err, and false
+// unless err is a 429.
+func rateLimitPause(err error) (time.Duration, bool) {
+	var statusErr *ntfyStatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if statusErr.RetryAfter > 0 {
+		return statusErr.RetryAfter, true
+	}
+	return rateLimitDefaultPause, true
+}
+
+// TopicPauses tracks topics ntfy rate-limited and until when nothing is
+// published to them.
+type TopicPauses struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+func NewTopicPauses() *TopicPauses {
+	return &TopicPauses{until: make(map[string]time.Time)}
+}
+
+// Pause stops publishing to topic for d. It returns the end of the pause
+// and whether it was extended, false if the topic was already paused longer.
+func (t *TopicPauses) Pause(topic string, d time.Duration) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	until := time.Now().Add(d)
+	if until.Before(t.until[topic]) {
+		return t.until[topic], false
+	}
+	t.until[topic] = until
+	return until, true
+}
+
+// Paused returns errRateLimited while topic is paused, dropping expired pauses.
+func (t *TopicPauses) Paused(topic string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	until, ok := t.until[topic]
+	if !ok {
+		return nil
+	}
+	if !time.Now().Before(until) {
+		delete(t.until, topic)
+		return nil
+	}
+	return fmt.Errorf("%w, topic %s paused until %s", errRateLimited, topic, until.Format("15:04:05"))
+}
+
+// All returns the paused topics and the end of their pause.
+func (t *TopicPauses) All() map[string]time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	out := make(map[string]time.Time, len(t.until))
+	for topic, until := range t.until {
+		if now.Before(until) {
+			out[topic] = until
+		}
+	}
+	return out
+}
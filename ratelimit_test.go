@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		header   string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"120", 2 * time.Minute, 2 * time.Minute},
+		{"0", 0, 0},
+		{"-5", 0, 0},
+		{"soon", 0, 0},
+		{"1.5", 0, 0},
+		{time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat), 80 * time.Second, 90 * time.Second},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, 0}, // in the past
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		if tt.header != "" {
+			h.Set("Retry-After", tt.header)
+		}
+		if got := retryAfter(h); got < tt.min || got > tt.max {
+			t.Errorf("Retry-After %q: got %s, want %s-%s", tt.header, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestRateLimitPause(t *testing.T) {
+	tests := []struct {
+		err    error
+		want   time.Duration
+		wantOK bool
+	}{
+		{&ntfyStatusError{Code: 429, RetryAfter: 30 * time.Second}, 30 * time.Second, true},
+		{fmt.Errorf("publish: %w", &ntfyStatusError{Code: 429}), rateLimitDefaultPause, true},
+		{&ntfyStatusError{Code: 503, RetryAfter: 30 * time.Second}, 0, false},
+		{errors.New("connection refused"), 0, false},
+		{nil, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := rateLimitPause(tt.err)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("%v: got %s, %v, want %s, %v", tt.err, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestTopicPauses(t *testing.T) {
+	p := NewTopicPauses()
+	if err := p.Paused("a"); err != nil {
+		t.Fatalf("unpaused topic: %v", err)
+	}
+	p.Pause("a", time.Minute)
+	if err := p.Paused("a"); !errors.Is(err, errRateLimited) {
+		t.Errorf("paused topic: got %v, want errRateLimited", err)
+	}
+	if err := p.Paused("b"); err != nil {
+		t.Errorf("other topic: %v", err)
+	}
+	if _, extended := p.Pause("a", time.Second); extended {
+		t.Error("a shorter pause must not shorten the current one")
+	}
+	p.Pause("c", -time.Second) // already over
+	if err := p.Paused("c"); err != nil {
+		t.Errorf("expired pause: %v", err)
+	}
+	if all := p.All(); len(all) != 1 || all["a"].IsZero() {
+		t.Errorf("All() = %v, want only a", all)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// recentForwardsSize is how many forward results seed a new web UI feed.
+const recentForwardsSize = 50
+
+// feedBuffer is how many events a slow feed subscriber may lag behind
+// before events are skipped for it.
+const feedBuffer = 64
+
+// forwardEvent is the outcome of one forwardToNtfy call. Like AppStats it
+// never contains message contents.
+type forwardEvent struct {
+	Time     time.Time `json:"time"`
+	AppID    int64     `json:"app_id"`
+	App      string    `json:"app,omitempty"`
+	Topic    string    `json:"topic,omitempty"`
+	Priority int       `json:"priority,omitempty"` // ntfy priority
+	Rule     string    `json:"rule,omitempty"`     // the TOPIC_RULES rule that picked Topic
+	Result   string    `json:"result"`             // sent, batched, buffered, held, outside_window, muted, zero_priority, duplicate or failed
+	Error    string    `json:"error,omitempty"`
+}
+
+// RecentForwards is a fixed-size ring of the latest forward results that
+// also fans new results out to live feed subscribers.
+type RecentForwards struct {
+	mu    sync.Mutex
+	items [recentForwardsSize]forwardEvent
+	next  int
+	count int
+	subs  map[chan forwardEvent]struct{}
+}
+
+func NewRecentForwards() *RecentForwards {
+	return &RecentForwards{subs: make(map[chan forwardEvent]struct{})}
+}
+
+func (r *RecentForwards) Add(e forwardEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.items[r.next] = e
+	r.next = (r.next + 1) % len(r.items)
+	r.count = min(r.count+1, len(r.items))
+	for ch := range r.subs {
+		select {
+		case ch <- e:
+		default: // the subscriber is not keeping up
+		}
+	}
+}
+
+func (r *RecentForwards) all() []forwardEvent {
+	out := make([]forwardEvent, 0, r.count)
+	for i := 1; i <= r.count; i++ {
+		out = append(out, r.items[(r.next-i+len(r.items))%len(r.items)])
+	}
+	return out
+}
+
+// Subscribe returns the recorded results (newest first) and a channel
+// receiving every later one, with no gap in between. cancel must be called
+// once the subscriber is done.
+func (r *RecentForwards) Subscribe() (seed []forwardEvent, events <-chan forwardEvent, cancel func()) {
+	ch := make(chan forwardEvent, feedBuffer)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subs[ch] = struct{}{}
+	return r.all(), ch, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.subs, ch)
+	}
+}
+
+// Recent returns the recorded results, newest first.
+func (r *RecentForwards) Recent() []forwardEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.all()
+}
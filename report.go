@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// volumeRetention is how many days of per-app message counts are kept.
+const volumeRetention = 14
+
+var ntfyPriorityNames = [5]string{"min", "low", "default", "high", "max"}
+
+type volumeState struct {
+	// Days maps YYYY-MM-DD (local time) -> app ID -> counts per ntfy priority 1–5.
+	Days     map[string]map[int64]*[5]int64 `json:"days"`
+	LastSent string                         `json:"last_sent,omitempty"`
+	// Day the daily digest was last sent, see digest.go
+	LastDigest string `json:"last_digest,omitempty"`
+	// Hourly resource samples, see stability.go
+	Resources []resourceSample `json:"resources,omitempty"`
+}
+
+// VolumeTracker counts incoming messages per app, day and priority so the
+// weekly report can rank the noisiest senders. Counts are persisted.
+type VolumeTracker struct {
+	mu    sync.Mutex
+	path  string
+	state volumeState
+}
+
+func NewVolumeTracker(path string) *VolumeTracker {
+	v := &VolumeTracker{path: path}
+	f, err := os.Open(path)
+	if err == nil {
+		defer f.Close()
+		if err := json.NewDecoder(f).Decode(&v.state); err != nil {
+			log.Printf("[REPORT ERROR] could not load %s: %v", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		log.Printf("[REPORT ERROR] could not open %s: %v", path, err)
+	}
+	if v.state.Days == nil {
+		v.state.Days = make(map[string]map[int64]*[5]int64)
+	}
+	return v
+}
+
+// Record counts one message of appID with the given ntfy priority (1–5).
+func (v *VolumeTracker) Record(appID int64, ntfyPriority int) {
+	if ntfyPriority < 1 || ntfyPriority > 5 {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	day := time.Now().Format("2006-01-02")
+	apps, ok := v.state.Days[day]
+	if !ok {
+		apps = make(map[int64]*[5]int64)
+		v.state.Days[day] = apps
+	}
+	c, ok := apps[appID]
+	if !ok {
+		c = &[5]int64{}
+		apps[appID] = c
+	}
+	c[ntfyPriority-1]++
+}
+
+// SentOn reports whether the report was already sent on day (YYYY-MM-DD).
+func (v *VolumeTracker) SentOn(day string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.state.LastSent == day
+}
+
+func (v *VolumeTracker) MarkSent(day string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.state.LastSent = day
+}
+
+// DigestSentOn reports whether the daily digest was already sent on day (YYYY-MM-DD).
+func (v *VolumeTracker) DigestSentOn(day string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.state.LastDigest == day
+}
+
+func (v *VolumeTracker) MarkDigestSent(day string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.state.LastDigest = day
+}
+
+func (v *VolumeTracker) Save() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	cutoff := time.Now().AddDate(0, 0, -volumeRetention).Format("2006-01-02")
+	for day := range v.state.Days {
+		if day < cutoff {
+			delete(v.state.Days, day)
+		}
+	}
+	return writeFileAtomic(v.path, func(f *os.File) error {
+		return json.NewEncoder(f).Encode(v.state)
+	})
+}
+
+// appVolume is the per-app total over a report period.
+type appVolume struct {
+	AppID  int64
+	Total  int64
+	Counts [5]int64
+}
+
+// Totals sums the counts of days in [from, to] (YYYY-MM-DD, inclusive),
+// sorted by volume, highest first.
+func (v *VolumeTracker) Totals(from, to string) []appVolume {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	byApp := make(map[int64]*appVolume)
+	for day, apps := range v.state.Days {
+		if day < from || day > to {
+			continue
+		}
+		for id, c := range apps {
+			a, ok := byApp[id]
+			if !ok {
+				a = &appVolume{AppID: id}
+				byApp[id] = a
+			}
+			for i, n := range c {
+				a.Counts[i] += n
+				a.Total += n
+			}
+		}
+	}
+	out := make([]appVolume, 0, len(byApp))
+	for _, a := range byApp {
+		out = append(out, *a)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Total != out[j].Total {
+			return out[i].Total > out[j].Total
+		}
+		return out[i].AppID < out[j].AppID
+	})
+	return out
+}
+
+// weeklyReport renders the ranking for the seven days before now.
+func weeklyReport(store *AppStore, v *VolumeTracker, now time.Time) (title, body string) {
+	from := now.AddDate(0, 0, -7).Format("2006-01-02")
+	to := now.AddDate(0, 0, -1).Format("2006-01-02")
+	title = fmt.Sprintf("Weekly alert report %s – %s", from, to)
+
+	totals := v.Totals(from, to)
+	if len(totals) == 0 {
+		return title, "No messages received this week."
+	}
+
+	var lines []string
+	var sum int64
+	for i, a := range totals {
+		name := fmt.Sprintf("#%d", a.AppID)
+		if app, ok := store.Get(a.AppID); ok {
+			name = app.Name
+		}
+		var dist []string
+		for p := 4; p >= 0; p-- {
+			if a.Counts[p] > 0 {
+				dist = append(dist, fmt.Sprintf("%s %d", ntfyPriorityNames[p], a.Counts[p]))
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%d. %s: %d (%s)", i+1, name, a.Total, strings.Join(dist, ", ")))
+		sum += a.Total
+	}
+	lines = append(lines, "", fmt.Sprintf("Total: %d messages from %d apps", sum, len(totals)))
+	return title, strings.Join(lines, "\n")
+}
+
+// runWeeklyReport persists message volume and publishes the report to the
+// admin topic at the configured weekday and time (local time, see TZ).
+func runWeeklyReport(b *Bridge) {
+	cfg := b.cfg
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+		today := now.Format("2006-01-02")
+		due := cfg.WeeklyReport && now.Weekday() == cfg.WeeklyReportDay &&
+			now.Format("15:04") >= cfg.WeeklyReportTime
+
+		if due && !b.volume.SentOn(today) {
+			title, body := weeklyReport(b.store, b.volume, now)
+			if err := sendNtfy(b.ctx, cfg, cfg.NtfyAdminTopic, title, body, 3); err != nil {
+				log.Printf("[REPORT ERROR] failed to send weekly report: %v", err)
+			} else {
+				log.Printf("[REPORT] Sent weekly report to %s", cfg.NtfyAdminTopic)
+				b.volume.MarkSent(today)
+			}
+		}
+
+		if err := b.volume.Save(); err != nil {
+			log.Printf("[REPORT ERROR] could not save %s: %v", cfg.ReportDBPath, err)
+		}
+	}
+}
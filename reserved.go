@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ReservedTopics remembers split topics that ntfy refused with 403 Forbidden
+// because another user reserved them. Their messages go to the default topic
+// for the rest of the process lifetime instead of failing every time.
+type ReservedTopics struct {
+	mu     sync.Mutex
+	topics map[string]bool
+}
+
+func NewReservedTopics() *ReservedTopics {
+	return &ReservedTopics{topics: make(map[string]bool)}
+}
+
+func (r *ReservedTopics) Reserved(topic string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.topics[topic]
+}
+
+// Mark records topic as reserved and reports whether it was not known yet.
+func (r *ReservedTopics) Mark(topic string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.topics[topic] {
+		return false
+	}
+	r.topics[topic] = true
+	return true
+}
+
+// All returns the reserved topics, sorted.
+func (r *ReservedTopics) All() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]string, 0, len(r.topics))
+	for t := range r.topics {
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func isForbidden(err error) bool {
+	var statusErr *ntfyStatusError
+	return errors.As(err, &statusErr) && statusErr.Code == http.StatusForbidden
+}
+
+// reservedFallback redirects p to the default topic, noting the topic it was meant for.
+func reservedFallback(cfg *Config, p ntfyPublish) ntfyPublish {
+	note := fmt.Sprintf("(topic %s is reserved on the ntfy server, delivered to %s)", p.Topic, cfg.NtfyTopic)
+	p.Topic = cfg.NtfyTopic
+	if p.Body == "" {
+		p.Body = note
+	} else {
+		p.Body += "\n\n" + note
+	}
+	return p
+}
+
+// publishTopic publishes p like publishNtfy, falling back to the default
+// topic when its split topic is reserved by another ntfy user. The first
+// time a topic is found reserved, the error topic is alerted.
+func publishTopic(ctx context.Context, b *Bridge, p ntfyPublish) error {
+	cfg := b.cfg
+	publish := func(p ntfyPublish) error {
+		if cfg.ObserveOnly {
+			b.stats.RecordObserved(p.Topic)
+			return publishNtfy(ctx, cfg, p)
+		}
+		// Paused topics are checked first: Allow may hand out the half-open
+		// probe, which only Published gives back
+		if err := b.limited.Paused(p.Topic); err != nil {
+			return err
+		}
+		if err := b.ntfyDown.Allow(); err != nil {
+			return err
+		}
+		start := time.Now()
+		err := publishNtfy(ctx, cfg, p)
+		b.stats.RecordPublish(p.Topic, time.Since(start), err)
+		b.ntfyDown.Published(b, err)
+		if d, limited := rateLimitPause(err); limited {
+			if until, extended := b.limited.Pause(p.Topic, d); extended {
+				log.Printf("[NTFY WARN] rate limited on topic %s, pausing it until %s", p.Topic, until.Format("15:04:05"))
+				// Deliver what was buffered meanwhile as soon as the pause ends
+				time.AfterFunc(d, b.buffer.Kick)
+			}
+			return fmt.Errorf("%w: %w", errRateLimited, err)
+		}
+		return err
+	}
+	// Without NTFY_TOPIC there is nothing to fall back to
+	if p.Topic == cfg.NtfyTopic || cfg.NtfyTopic == "" {
+		return publish(p)
+	}
+	if b.reserved.Reserved(p.Topic) {
+		return publish(reservedFallback(cfg, p))
+	}
+
+	err := publish(p)
+	if !isForbidden(err) {
+		return err
+	}
+	// A 403 on the default topic too means the token lacks access, not a reservation
+	if fallbackErr := publish(reservedFallback(cfg, p)); fallbackErr != nil {
+		return err
+	}
+	if b.reserved.Mark(p.Topic) {
+		log.Printf("[NTFY WARN] topic %s is reserved by another user, delivering to %s instead", p.Topic, cfg.NtfyTopic)
+		body := fmt.Sprintf("The ntfy server refused to publish to %s (403 Forbidden), it is probably reserved by another user.\n"+
+			"Messages for this topic are delivered to %s until the bridge restarts.", p.Topic, cfg.NtfyTopic)
+		if err := sendNtfy(ctx, cfg, cfg.NtfyErrorTopic, "Reserved ntfy topic: "+p.Topic, body, 4); err != nil {
+			log.Printf("[NTFY ERROR] failed to send reserved topic alert: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// ruleSets holds the active TOPIC_RULES and an optional candidate set from
+// TOPIC_RULES_CANDIDATE. The candidate is evaluated for every message but
+// only logged where it picks a different topic, until it is promoted.
+type ruleSets struct {
+	active       TopicRules
+	activeSrc    string
+	candidate    TopicRules
+	candidateSrc string
+	hasCandidate bool // an empty candidate means "no rules"
+}
+
+// Rules returns the current rule sets; they are replaced, never modified.
+func (c *Config) Rules() *ruleSets {
+	return c.rules.Load()
+}
+
+// resolveWith resolves msg like resolveTopic, using rules instead of the
+// active set. It also returns the source of the matching rule, if any.
+func resolveWith(cfg *Config, store *AppStore, msg GotifyMessage, rules TopicRules) (topic, rule string) {
+	if len(rules) > 0 {
+		if r, topic, ok := rules.Match(messageEnv(cfg, store, msg)); ok {
+			return topic, r.Source
+		}
+	}
+	if cfg.SplitTopics {
+		return store.TopicFor(msg.AppID, cfg.unknownAppTopic(msg.AppID)), ""
+	}
+	return cfg.NtfyTopic, ""
+}
+
+// compareCandidate logs messages the candidate rules would send to a
+// different topic than the active ones.
+func compareCandidate(b *Bridge, msg GotifyMessage, activeTopic string) {
+	rs := b.cfg.Rules()
+	if !rs.hasCandidate {
+		return
+	}
+	topic, rule := resolveWith(b.cfg, b.store, msg, rs.candidate)
+	differs := topic != activeTopic
+	b.stats.RecordCandidate(differs)
+	if differs {
+		if rule == "" {
+			rule = "(no match)"
+		}
+		slog.Info("Candidate rules differ", "app_id", msg.AppID, "message_id", msg.ID,
+			"active", activeTopic, "candidate", topic, "candidate_rule", rule)
+	}
+}
+
+type rulesStatus struct {
+	Active    string  `json:"active"`
+	Candidate *string `json:"candidate"` // null without candidate rules
+	Evaluated int64   `json:"evaluated"`
+	Differed  int64   `json:"differed"`
+}
+
+func handleRules(b *Bridge) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rs := b.cfg.Rules()
+		evaluated, differed := b.stats.candidateCounts()
+		s := rulesStatus{Active: rs.activeSrc, Evaluated: evaluated, Differed: differed}
+		if rs.hasCandidate {
+			s.Candidate = &rs.candidateSrc
+		}
+		writeJSON(w, http.StatusOK, s)
+	}
+}
+
+// handleSetCandidate replaces the candidate rules with the request body in
+// TOPIC_RULES syntax. An empty body is a valid candidate, "no rules".
+func handleSetCandidate(b *Bridge) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 64<<10))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		src := strings.TrimSpace(string(body))
+		rules, err := parseTopicRules(src)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		for {
+			old := b.cfg.Rules()
+			next := &ruleSets{active: old.active, activeSrc: old.activeSrc, candidate: rules, candidateSrc: src, hasCandidate: true}
+			if b.cfg.rules.CompareAndSwap(old, next) {
+				break
+			}
+		}
+		b.audit.Record(r.Context(), requestActor(b.cfg, r), "set-candidate-rules", map[string]any{"rules": src})
+		writeJSON(w, http.StatusOK, map[string]string{"candidate": src})
+	}
+}
+
+// handleDropCandidate stops evaluating candidate rules.
+func handleDropCandidate(b *Bridge) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for {
+			old := b.cfg.Rules()
+			if b.cfg.rules.CompareAndSwap(old, &ruleSets{active: old.active, activeSrc: old.activeSrc}) {
+				break
+			}
+		}
+		b.audit.Record(r.Context(), requestActor(b.cfg, r), "drop-candidate-rules", nil)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handlePromote swaps the candidate and active rules, so promoting again
+// rolls back.
+func handlePromote(b *Bridge) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var next *ruleSets
+		for {
+			old := b.cfg.Rules()
+			if !old.hasCandidate {
+				writeJSON(w, http.StatusConflict, map[string]string{"error": "no candidate rules loaded"})
+				return
+			}
+			next = &ruleSets{active: old.candidate, activeSrc: old.candidateSrc,
+				candidate: old.active, candidateSrc: old.activeSrc, hasCandidate: true}
+			if b.cfg.rules.CompareAndSwap(old, next) {
+				break
+			}
+		}
+		b.audit.Record(r.Context(), requestActor(b.cfg, r), "promote-rules", map[string]any{"rules": next.activeSrc})
+		slog.Info("Promoted candidate topic rules", "active", next.activeSrc, "candidate", next.candidateSrc)
+		writeJSON(w, http.StatusOK, map[string]string{"active": next.activeSrc, "candidate": next.candidateSrc})
+	}
+}
+
+// parseRuleSets parses TOPIC_RULES and TOPIC_RULES_CANDIDATE.
+func parseRuleSets(active, candidate string) (*ruleSets, error) {
+	rs := &ruleSets{activeSrc: active, candidateSrc: candidate, hasCandidate: candidate != ""}
+	var err error
+	if rs.active, err = parseTopicRules(active); err != nil {
+		return nil, fmt.Errorf("invalid TOPIC_RULES: %w", err)
+	}
+	if rs.candidate, err = parseTopicRules(candidate); err != nil {
+		return nil, fmt.Errorf("invalid TOPIC_RULES_CANDIDATE: %w", err)
+	}
+	return rs, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Schedule is a recurring message defined in SCHEDULES_FILE.
+type Schedule struct {
+	Name     string `json:"name"`
+	Cron     string `json:"cron"`
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority,omitempty"` // Gotify priority 0–10
+	AppID    int64  `json:"app_id,omitempty"`   // app whose topic and profile are used
+
+	cron                 *cronSchedule
+	titleTpl, messageTpl *template.Template
+}
+
+// scheduleData is passed to the title and message templates.
+type scheduleData struct {
+	Name string
+	Time time.Time
+	Date string
+}
+
+func loadSchedules(path string) ([]*Schedule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var schedules []*Schedule
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&schedules); err != nil {
+		return nil, err
+	}
+	for i, s := range schedules {
+		if s.Name == "" {
+			s.Name = fmt.Sprintf("schedule %d", i+1)
+		}
+		if s.Message == "" {
+			return nil, fmt.Errorf("%s: message is required", s.Name)
+		}
+		if s.cron, err = parseCron(s.Cron); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.Name, err)
+		}
+		if s.cron.Next(time.Now()).IsZero() {
+			return nil, fmt.Errorf("%s: cron expression %q never fires", s.Name, s.Cron)
+		}
+		if s.titleTpl, err = template.New("title").Parse(s.Title); err != nil {
+			return nil, fmt.Errorf("%s: title: %w", s.Name, err)
+		}
+		if s.messageTpl, err = template.New("message").Parse(s.Message); err != nil {
+			return nil, fmt.Errorf("%s: message: %w", s.Name, err)
+		}
+	}
+	return schedules, nil
+}
+
+func (s *Schedule) render(now time.Time) (title, message string, err error) {
+	data := scheduleData{Name: s.Name, Time: now, Date: now.Format("2006-01-02")}
+	var t, m strings.Builder
+	if err := s.titleTpl.Execute(&t, data); err != nil {
+		return "", "", err
+	}
+	if err := s.messageTpl.Execute(&m, data); err != nil {
+		return "", "", err
+	}
+	return t.String(), m.String(), nil
+}
+
+// runScheduler sends the configured recurring messages through the same
+// pipeline as Gotify messages, checking the schedules once a minute.
+func runScheduler(b *Bridge) {
+	cfg := b.cfg
+	for _, s := range cfg.schedules {
+		log.Printf("[SCHEDULE] %s (%s), next run %s", s.Name, s.Cron, s.cron.Next(time.Now()).Format("2006-01-02 15:04"))
+	}
+	for {
+		now := time.Now()
+		time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		now = time.Now().Truncate(time.Minute)
+
+		for _, s := range cfg.schedules {
+			if !s.cron.Matches(now) {
+				continue
+			}
+			title, message, err := s.render(now)
+			if err != nil {
+				log.Printf("[SCHEDULE ERROR] %s: %v", s.Name, err)
+				continue
+			}
+			msg := GotifyMessage{AppID: s.AppID, Title: title, Message: message, Priority: s.Priority}
+			if err := forwardToNtfy(b.ctx, b, msg); err != nil && !errors.Is(err, errBuffered) {
+				log.Printf("[SCHEDULE ERROR] %s: %v", s.Name, err)
+			} else {
+				log.Printf("[SCHEDULE] Sent %s", s.Name)
+			}
+		}
+	}
+}
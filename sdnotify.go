@@ -0,0 +1,83 @@
+package main
+
+import (
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// watchdogStaleAfter is how long the stream may be silent before the bridge
+// stops feeding the systemd watchdog. Gotify pings every 45 seconds.
+const watchdogStaleAfter = 2 * time.Minute
+
+// sdNotify sends state to the systemd notification socket. It is a no-op
+// when the bridge was not started by systemd with Type=notify.
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:] // abstract namespace
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// Watchdog tracks whether the stream read loop and the reconnect loop are
+// making progress, and reports readiness and liveness to systemd.
+type Watchdog struct {
+	last  atomic.Int64 // unix nanos of the last sign of life
+	ready sync.Once
+}
+
+func NewWatchdog() *Watchdog {
+	w := &Watchdog{}
+	w.Beat()
+	return w
+}
+
+// Beat records that the read or reconnect loop is alive.
+func (w *Watchdog) Beat() { w.last.Store(time.Now().UnixNano()) }
+
+// Ready sends READY=1 once, after the first successful Gotify connection.
+func (w *Watchdog) Ready() {
+	w.ready.Do(func() {
+		if err := sdNotify("READY=1"); err != nil {
+			log.Printf("[SYSTEMD ERROR] could not send readiness: %v", err)
+		}
+	})
+}
+
+// runWatchdog pings the systemd watchdog at half of WATCHDOG_USEC as long
+// as the bridge showed a sign of life recently, so systemd restarts a hung bridge.
+func runWatchdog(w *Watchdog) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 || os.Getenv("NOTIFY_SOCKET") == "" {
+		return
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(usec) * time.Microsecond / 2)
+	defer ticker.Stop()
+	for range ticker.C {
+		if time.Since(time.Unix(0, w.last.Load())) > watchdogStaleAfter {
+			log.Printf("[SYSTEMD] No stream activity for %v, withholding watchdog ping", watchdogStaleAfter)
+			continue
+		}
+		if err := sdNotify("WATCHDOG=1"); err != nil {
+			log.Printf("[SYSTEMD ERROR] could not ping watchdog: %v", err)
+		}
+	}
+}
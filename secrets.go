@@ -0,0 +1,88 @@
+package main
+
+import (
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const secretMask = "********"
+
+// logSecrets are the credential values masked in every log line: the
+// secret Config fields, a client token obtained by logging in, and the app
+// tokens returned by Gotify. Masking happens in the log handlers, so it
+// covers log.Printf, slog and debug output alike.
+var logSecrets struct {
+	sync.RWMutex
+	values   map[string]bool
+	disabled bool // UNSAFE_LOG_SECRETS=true
+}
+
+// secretPatterns catch credentials the bridge never saw as values, such as
+// tokens in URLs or headers of dumped requests.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)([?&]token=)[^&\s"]+`),
+	regexp.MustCompile(`(?i)(Authorization:\s*(?:Bearer|Basic)\s+)[^\s"]+`),
+	regexp.MustCompile(`(?i)(X-Gotify-Key:\s*)[^\s"]+`),
+	regexp.MustCompile(`(//[^/\s:@"]+:)[^/\s@"]+(@)`), // user:password@host
+}
+
+// registerConfigSecrets adds the secret fields of cfg, see isSecretField.
+func registerConfigSecrets(cfg *Config) {
+	logSecrets.Lock()
+	logSecrets.disabled = cfg.UnsafeLogSecrets
+	logSecrets.Unlock()
+
+	rv := reflect.ValueOf(cfg).Elem()
+	rt := rv.Type()
+	for i := range rt.NumField() {
+		if f := rt.Field(i); f.IsExported() && f.Type.Kind() == reflect.String && isSecretField(f.Name) {
+			registerSecret(rv.Field(i).String())
+		}
+	}
+}
+
+func registerSecret(s string) {
+	// Very short values would mask unrelated text
+	if len(s) < 6 {
+		return
+	}
+	logSecrets.Lock()
+	defer logSecrets.Unlock()
+	if logSecrets.values == nil {
+		logSecrets.values = make(map[string]bool)
+	}
+	logSecrets.values[s] = true
+}
+
+// maskSecrets replaces the registered secrets and secret-looking
+// substrings of s, unless UNSAFE_LOG_SECRETS is set.
+func maskSecrets(s string) string {
+	logSecrets.RLock()
+	defer logSecrets.RUnlock()
+	if logSecrets.disabled {
+		return s
+	}
+	for v := range logSecrets.values {
+		s = strings.ReplaceAll(s, v, secretMask)
+	}
+	for _, re := range secretPatterns {
+		s = re.ReplaceAllString(s, "${1}"+secretMask+"${2}")
+	}
+	return s
+}
+
+// maskAttr masks string attributes and values such as errors that print
+// as text.
+func maskAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	switch a.Value.Kind() {
+	case slog.KindString, slog.KindAny:
+		if s := a.Value.String(); maskSecrets(s) != s {
+			a.Value = slog.StringValue(maskSecrets(s))
+		}
+	}
+	return a
+}
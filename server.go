@@ -0,0 +1,93 @@
+//go:build !minimal
+
+package main
+
+import (
+	"expvar"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+func newHTTPMux(b *Bridge) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /version", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, buildInfo())
+	})
+	mux.HandleFunc("GET /healthz", handleHealthz)
+	mux.HandleFunc("GET /readyz", handleReadyz(b))
+	mux.HandleFunc("POST /apps/{id}/snooze", requireSnooze(b.cfg, handleSnooze(b)))
+	mux.HandleFunc("DELETE /apps/{id}/snooze", requireSnooze(b.cfg, handleSnooze(b)))
+	mux.HandleFunc("GET /icons/{appID}", handleIcon(b))
+	registerUI(mux, b)
+	mux.HandleFunc("GET /metrics", handleMetrics(b))
+	handleSync := func(w http.ResponseWriter, r *http.Request) {
+		b.audit.Record(r.Context(), requestActor(b.cfg, r), "sync", nil)
+		summary, err := b.SyncNow(r.Context())
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, summary)
+	}
+	mux.HandleFunc("POST /sync", requireAdmin(b.cfg, handleSync))
+
+	mux.HandleFunc("GET /status", requireAdmin(b.cfg, handleAdminStatus(b)))
+	// The command line, queue state and per-topic counts (topic names) are not public
+	mux.HandleFunc("GET /debug/vars", requireAdmin(b.cfg, expvar.Handler().ServeHTTP))
+	mux.HandleFunc("GET /queue", requireAdmin(b.cfg, handleQueue(b)))
+	mux.HandleFunc("GET /apps", requireAdmin(b.cfg, handleAdminApps(b)))
+	mux.HandleFunc("POST /pause", requireAdmin(b.cfg, handlePause(b, true)))
+	mux.HandleFunc("POST /resume", requireAdmin(b.cfg, handlePause(b, false)))
+	mux.HandleFunc("POST /test", requireAdmin(b.cfg, handleTestMessage(b)))
+	mux.HandleFunc("POST /test-message", requireAdmin(b.cfg, handleTestMessage(b)))
+	mux.HandleFunc("GET /config", requireAdmin(b.cfg, handleAdminConfig(b)))
+	mux.HandleFunc("GET /rules", requireAdmin(b.cfg, handleRules(b)))
+	mux.HandleFunc("PUT /rules/candidate", requireAdmin(b.cfg, handleSetCandidate(b)))
+	mux.HandleFunc("DELETE /rules/candidate", requireAdmin(b.cfg, handleDropCandidate(b)))
+	mux.HandleFunc("POST /rules/promote", requireAdmin(b.cfg, handlePromote(b)))
+	if b.cfg.Pprof {
+		// Heap dumps and the command line are for admins only
+		mux.HandleFunc("GET /debug/pprof/", requireAdmin(b.cfg, pprof.Index))
+		mux.HandleFunc("GET /debug/pprof/cmdline", requireAdmin(b.cfg, pprof.Cmdline))
+		mux.HandleFunc("GET /debug/pprof/profile", requireAdmin(b.cfg, pprof.Profile))
+		mux.HandleFunc("GET /debug/pprof/symbol", requireAdmin(b.cfg, pprof.Symbol))
+		mux.HandleFunc("GET /debug/pprof/trace", requireAdmin(b.cfg, pprof.Trace))
+	}
+	return mux
+}
+
+// runHTTPServer serves the bridge's HTTP endpoints on cfg.HTTPListen.
+func runHTTPServer(b *Bridge) {
+	publishExpvars(b)
+	srv := &http.Server{
+		Addr:              b.cfg.HTTPListen,
+		Handler:           guardHTTP(b.cfg, newHTTPMux(b)),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("HTTP server listening on %s", b.cfg.HTTPListen)
+	if b.cfg.Pprof {
+		log.Printf("[HTTP] pprof enabled at /debug/pprof/")
+	}
+	if err := srv.ListenAndServe(); err != nil {
+		log.Printf("[HTTP ERROR] server stopped: %v", err)
+	}
+}
+
+// publishExpvars registers the bridge's runtime state with expvar, served at
+// /debug/vars next to the standard cmdline and memstats variables.
+func publishExpvars(b *Bridge) {
+	expvar.Publish("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+	expvar.Publish("stream_queue", expvar.Func(func() any {
+		if q := b.queue.Load(); q != nil {
+			return len(*q)
+		}
+		return 0
+	}))
+	expvar.Publish("offline_buffer", expvar.Func(func() any { return b.buffer.Len() }))
+	expvar.Publish("reconnect_attempt", expvar.Func(func() any { return b.reconnectAttempt.Load() }))
+	expvar.Publish("connected", expvar.Func(func() any { return b.stats.Connected() }))
+	expvar.Publish("topics", expvar.Func(func() any { return b.stats.topicCounts() }))
+}
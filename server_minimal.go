@@ -0,0 +1,11 @@
+//go:build minimal
+
+package main
+
+import "log"
+
+// The minimal build leaves out the HTTP server with the admin API, web UI,
+// /metrics and pprof, see server.go.
+func runHTTPServer(b *Bridge) {
+	log.Printf("[HTTP WARN] HTTP_LISTEN=%s is ignored: this is a minimal build without the HTTP server", b.cfg.HTTPListen)
+}
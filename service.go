@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// serviceName identifies the bridge in systemd, launchd and the Windows SCM.
+const serviceName = "gotify-to-ntfy-push"
+
+// serviceArgs returns the command line a service manager should start the
+// bridge with: the working directory and env files of the installing
+// invocation, as absolute paths, since services start elsewhere.
+func serviceArgs(cfg *Config) ([]string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"-chdir", wd}
+	for _, f := range cfg.envFiles {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-env-file", abs)
+	}
+	return args, nil
+}
+
+// runService implements `service install|uninstall|start|stop|print`.
+func runService(cfg *Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: service install|uninstall|start|stop|print")
+		return 2
+	}
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not locate executable: %v\n", err)
+		return 1
+	}
+	svcArgs, err := serviceArgs(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not resolve service arguments: %v\n", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "install":
+		err = serviceInstall(exe, svcArgs)
+	case "uninstall":
+		err = serviceUninstall()
+	case "start":
+		err = serviceStart()
+	case "stop":
+		err = serviceStop()
+	case "print":
+		err = servicePrint(exe, svcArgs)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown service command %q (available: install, uninstall, start, stop, print)\n", args[0])
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "service %s failed: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const launchdLabel = "com.github.itxworks." + serviceName
+
+func launchdPlistPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", launchdLabel+".plist"), nil
+}
+
+func launchdPlist(exe string, args []string) string {
+	var b strings.Builder
+	for _, a := range append([]string{exe}, args...) {
+		fmt.Fprintf(&b, "\t\t<string>%s</string>\n", html.EscapeString(a))
+	}
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+%s	</array>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+</dict>
+</plist>
+`, launchdLabel, b.String())
+}
+
+func launchctl(args ...string) error {
+	cmd := exec.Command("launchctl", args...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+func servicePrint(exe string, args []string) error {
+	fmt.Print(launchdPlist(exe, args))
+	return nil
+}
+
+func serviceInstall(exe string, args []string) error {
+	p, err := launchdPlistPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(p, []byte(launchdPlist(exe, args)), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s\n", p)
+	return nil
+}
+
+func serviceUninstall() error {
+	p, err := launchdPlistPath()
+	if err != nil {
+		return err
+	}
+	_ = launchctl("unload", p)
+	return os.Remove(p)
+}
+
+func serviceStart() error {
+	p, err := launchdPlistPath()
+	if err != nil {
+		return err
+	}
+	return launchctl("load", "-w", p)
+}
+
+func serviceStop() error {
+	p, err := launchdPlistPath()
+	if err != nil {
+		return err
+	}
+	return launchctl("unload", p)
+}
+
+// startServiceHandler is only needed on Windows.
+func startServiceHandler() {}
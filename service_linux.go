@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const systemdUnitPath = "/etc/systemd/system/" + serviceName + ".service"
+
+func systemdUnit(exe string, args []string) string {
+	quoted := make([]string, 0, len(args)+1)
+	for _, a := range append([]string{exe}, args...) {
+		if strings.ContainsAny(a, " \t\"\\") {
+			a = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(a) + `"`
+		}
+		quoted = append(quoted, a)
+	}
+	return fmt.Sprintf(`[Unit]
+Description=Gotify to ntfy bridge
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=notify
+ExecStart=%s
+Restart=on-failure
+RestartSec=10
+WatchdogSec=5min
+
+[Install]
+WantedBy=multi-user.target
+`, strings.Join(quoted, " "))
+}
+
+func systemctl(args ...string) error {
+	cmd := exec.Command("systemctl", args...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+func servicePrint(exe string, args []string) error {
+	fmt.Print(systemdUnit(exe, args))
+	return nil
+}
+
+func serviceInstall(exe string, args []string) error {
+	if err := os.WriteFile(systemdUnitPath, []byte(systemdUnit(exe, args)), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s\n", systemdUnitPath)
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return systemctl("enable", serviceName)
+}
+
+func serviceUninstall() error {
+	_ = systemctl("disable", "--now", serviceName)
+	if err := os.Remove(systemdUnitPath); err != nil {
+		return err
+	}
+	return systemctl("daemon-reload")
+}
+
+func serviceStart() error { return systemctl("start", serviceName) }
+func serviceStop() error  { return systemctl("stop", serviceName) }
+
+// startServiceHandler is only needed on Windows.
+func startServiceHandler() {}
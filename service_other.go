@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import (
+	"errors"
+	"runtime"
+)
+
+var errServiceUnsupported = errors.New("service management is not supported on " + runtime.GOOS)
+
+func servicePrint(string, []string) error   { return errServiceUnsupported }
+func serviceInstall(string, []string) error { return errServiceUnsupported }
+func serviceUninstall() error               { return errServiceUnsupported }
+func serviceStart() error                   { return errServiceUnsupported }
+func serviceStop() error                    { return errServiceUnsupported }
+
+func startServiceHandler() {}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+func servicePrint(exe string, args []string) error {
+	fmt.Printf("%s %s\n", exe, strings.Join(args, " "))
+	return nil
+}
+
+func serviceInstall(exe string, args []string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName: "Gotify to ntfy bridge",
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	return s.SetRecoveryActions([]mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 10 * time.Second},
+	}, 86400)
+}
+
+func withService(fn func(s *mgr.Service) error) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	return fn(s)
+}
+
+func serviceUninstall() error {
+	return withService(func(s *mgr.Service) error { return s.Delete() })
+}
+
+func serviceStart() error {
+	return withService(func(s *mgr.Service) error { return s.Start() })
+}
+
+func serviceStop() error {
+	return withService(func(s *mgr.Service) error {
+		_, err := s.Control(svc.Stop)
+		return err
+	})
+}
+
+type windowsService struct{}
+
+func (windowsService) Execute(_ []string, req <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for c := range req {
+		switch c.Cmd {
+		case svc.Interrogate:
+			status <- c.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			status <- svc.Status{State: svc.StopPending}
+			return false, 0
+		}
+	}
+	return false, 0
+}
+
+// startServiceHandler reports to the Windows SCM when started as a service
+// and exits the process once the SCM asks the service to stop.
+func startServiceHandler() {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return
+	}
+	go func() {
+		if err := svc.Run(serviceName, windowsService{}); err != nil {
+			log.Printf("[SERVICE ERROR] %v", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}()
+}
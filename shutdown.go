@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// waitTimeout waits for wg and reports whether it finished within d.
+func waitTimeout(wg *sync.WaitGroup, d time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(d):
+		return false
+	}
+}
+
+// flushOnExit keeps retrying the offline buffer until it is empty or d has
+// passed, for setups where nothing survives a restart anyway. Forwarding is
+// resumed if it was paused, since held messages would be lost otherwise.
+func flushOnExit(b *Bridge, d time.Duration) {
+	n := b.buffer.Len()
+	if n == 0 {
+		return
+	}
+	if b.paused.Swap(false) {
+		log.Printf("[SHUTDOWN] Resuming forwarding to deliver held messages")
+	}
+	log.Printf("[SHUTDOWN] Retrying %d buffered messages for up to %v", n, d)
+	deadline := time.Now().Add(d)
+	// runOfflineBuffer does the delivery, so messages are never sent twice
+	for b.buffer.Len() > 0 && time.Now().Before(deadline) {
+		b.buffer.Kick()
+		time.Sleep(time.Second)
+	}
+	if b.buffer.Len() == 0 {
+		log.Printf("[SHUTDOWN] Offline buffer drained")
+	}
+}
+
+// shutdown flushes pending batches and persists state before the process exits.
+func shutdown(b *Bridge) {
+	cfg, stats := b.cfg, b.stats
+	_ = sdNotify("STOPPING=1")
+
+	// b.ctx is already cancelled here, the last batch and spans still go out
+	if cfg.Metered {
+		b.batcher.Flush(context.Background(), cfg)
+	}
+	if cfg.ShutdownFlushTimeout > 0 {
+		flushOnExit(b, cfg.ShutdownFlushTimeout)
+	}
+	if n := b.buffer.Len(); n > 0 && cfg.OfflineBufferFile != "" {
+		b.buffer.save()
+		log.Printf("[SHUTDOWN] %d buffered messages are kept in %s and retried on the next start", n, cfg.OfflineBufferFile)
+	} else if n > 0 {
+		log.Printf("[SHUTDOWN] %d buffered messages were not delivered to ntfy", n)
+	}
+	if n := b.held.Len(); n > 0 {
+		log.Printf("[SHUTDOWN] %d messages held for their delivery window were not delivered", n)
+	}
+	b.tracer.Flush(context.Background())
+	if n := b.wal.Pending(); n > 0 {
+		log.Printf("[SHUTDOWN] %d messages are kept in %s and will be replayed on the next start", n, cfg.WALFile)
+	}
+	if err := b.wal.Close(); err != nil {
+		log.Printf("[WAL ERROR] could not close %s: %v", cfg.WALFile, err)
+	}
+
+	stats.SLA.Sample(false)
+	if err := stats.SLA.Save(); err != nil {
+		log.Printf("[SLA ERROR] could not save %s: %v", cfg.SLADBPath, err)
+	}
+	if err := b.volume.Save(); err != nil {
+		log.Printf("[REPORT ERROR] could not save %s: %v", cfg.ReportDBPath, err)
+	}
+	if err := b.backfill.Save(); err != nil {
+		log.Printf("[BACKFILL ERROR] could not save %s: %v", cfg.StateDBPath, err)
+	}
+	log.Printf("Shutdown complete")
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handleSignals forces an application resync on SIGUSR2 and logs a state
+// dump on SIGUSR1.
+func handleSignals(b *Bridge) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1, syscall.SIGUSR2)
+	for sig := range ch {
+		switch sig {
+		case syscall.SIGUSR1:
+			dumpState(b)
+		case syscall.SIGUSR2:
+			b.audit.Record(b.ctx, "signal SIGUSR2", "sync", nil)
+			if _, err := b.SyncNow(b.ctx); err != nil {
+				log.Printf("[SYNC ERROR] Could not load applications: %v", err)
+			}
+		}
+	}
+}
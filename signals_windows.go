@@ -0,0 +1,4 @@
+package main
+
+// handleSignals is a no-op: Windows has no SIGUSR1/SIGUSR2, use POST /sync instead.
+func handleSignals(*Bridge) {}
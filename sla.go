@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// slaWindow is the rolling window availability figures are computed over.
+const slaWindow = 30 * 24 * time.Hour
+
+// SLABucket accumulates one day of availability data.
+type SLABucket struct {
+	Day           string `json:"day"` // YYYY-MM-DD in local time
+	UpSeconds     int64  `json:"up_seconds"`
+	GotifySeconds int64  `json:"gotify_seconds"`
+	NtfyOK        int64  `json:"ntfy_ok"`
+	NtfyFailed    int64  `json:"ntfy_failed"`
+}
+
+// SLAReport summarizes the rolling window. Percentages are -1 when there is no data.
+type SLAReport struct {
+	WindowDays         int     `json:"window_days"`
+	BridgeUptimePct    float64 `json:"bridge_uptime_pct"`
+	GotifyAvailablePct float64 `json:"gotify_available_pct"`
+	NtfySuccessPct     float64 `json:"ntfy_success_pct"`
+	NtfyOK             int64   `json:"ntfy_ok"`
+	NtfyFailed         int64   `json:"ntfy_failed"`
+}
+
+type slaState struct {
+	FirstSeen time.Time             `json:"first_seen"`
+	Buckets   map[string]*SLABucket `json:"buckets"`
+}
+
+// SLATracker persists daily uptime/availability buckets so the figures survive restarts.
+type SLATracker struct {
+	mu         sync.Mutex
+	path       string
+	state      slaState
+	lastSample time.Time
+}
+
+func NewSLATracker(path string) *SLATracker {
+	t := &SLATracker{path: path, lastSample: time.Now()}
+	t.state.Buckets = make(map[string]*SLABucket)
+
+	f, err := os.Open(path)
+	if err == nil {
+		defer f.Close()
+		if err := json.NewDecoder(f).Decode(&t.state); err != nil {
+			log.Printf("[SLA ERROR] could not load %s: %v", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		log.Printf("[SLA ERROR] could not open %s: %v", path, err)
+	}
+	if t.state.Buckets == nil {
+		t.state.Buckets = make(map[string]*SLABucket)
+	}
+	if t.state.FirstSeen.IsZero() {
+		t.state.FirstSeen = time.Now()
+	}
+	return t
+}
+
+func (t *SLATracker) bucket(now time.Time) *SLABucket {
+	day := now.Format("2006-01-02")
+	b, ok := t.state.Buckets[day]
+	if !ok {
+		b = &SLABucket{Day: day}
+		t.state.Buckets[day] = b
+	}
+	return b
+}
+
+// Sample credits the time since the previous sample as bridge uptime and,
+// if connected, as Gotify availability.
+func (t *SLATracker) Sample(connected bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	elapsed := int64(now.Sub(t.lastSample).Seconds())
+	if elapsed <= 0 {
+		return
+	}
+	t.lastSample = t.lastSample.Add(time.Duration(elapsed) * time.Second)
+	b := t.bucket(now)
+	b.UpSeconds += elapsed
+	if connected {
+		b.GotifySeconds += elapsed
+	}
+	t.prune(now)
+}
+
+func (t *SLATracker) RecordNtfy(ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := t.bucket(time.Now())
+	if ok {
+		b.NtfyOK++
+	} else {
+		b.NtfyFailed++
+	}
+}
+
+func (t *SLATracker) prune(now time.Time) {
+	cutoff := now.Add(-slaWindow).Format("2006-01-02")
+	for day := range t.state.Buckets {
+		if day < cutoff {
+			delete(t.state.Buckets, day)
+		}
+	}
+}
+
+func (t *SLATracker) Report() SLAReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Walk the window day by day so each bucket is measured against the
+	// part of its day the tracker could have seen: from FirstSeen, up to now
+	now := time.Now()
+	days := int(slaWindow.Hours() / 24)
+	y, m, d := now.Date()
+	first := time.Date(y, m, d-days+1, 0, 0, 0, 0, now.Location())
+
+	var up, gotify, possible int64
+	r := SLAReport{WindowDays: days}
+	for day := first; day.Before(now); day = day.AddDate(0, 0, 1) {
+		from, to := day, day.AddDate(0, 0, 1)
+		if from.Before(t.state.FirstSeen) {
+			from = t.state.FirstSeen
+		}
+		if to.After(now) {
+			to = now
+		}
+		if to.After(from) {
+			possible += int64(to.Sub(from).Seconds())
+		}
+		b, ok := t.state.Buckets[day.Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+		up += b.UpSeconds
+		gotify += b.GotifySeconds
+		r.NtfyOK += b.NtfyOK
+		r.NtfyFailed += b.NtfyFailed
+	}
+
+	r.BridgeUptimePct = pct(up, possible)
+	r.GotifyAvailablePct = pct(gotify, up)
+	r.NtfySuccessPct = pct(r.NtfyOK, r.NtfyOK+r.NtfyFailed)
+	return r
+}
+
+func pct(part, total int64) float64 {
+	if total <= 0 {
+		return -1
+	}
+	p := float64(part) / float64(total) * 100
+	if p > 100 {
+		p = 100
+	}
+	return p
+}
+
+func (t *SLATracker) Save() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return writeFileAtomic(t.path, func(f *os.File) error {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return enc.Encode(t.state)
+	})
+}
+
+// runSLATracker samples availability once a minute and persists the result.
+func runSLATracker(cfg *Config, stats *Stats) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		stats.SLA.Sample(stats.Connected())
+		if err := stats.SLA.Save(); err != nil {
+			log.Printf("[SLA ERROR] could not save %s: %v", cfg.SLADBPath, err)
+		}
+	}
+}
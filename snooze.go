@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// snoozeMaxDuration caps how long a single snooze can mute an app.
+const snoozeMaxDuration = 24 * time.Hour
+
+// MuteStore tracks apps that are temporarily muted via the snooze action.
+type MuteStore struct {
+	mu    sync.Mutex
+	until map[int64]time.Time
+}
+
+func NewMuteStore() *MuteStore {
+	return &MuteStore{until: make(map[int64]time.Time)}
+}
+
+func (m *MuteStore) Mute(appID int64, d time.Duration) time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	until := time.Now().Add(d)
+	m.until[appID] = until
+	return until
+}
+
+func (m *MuteStore) Unmute(appID int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.until, appID)
+}
+
+// Muted reports whether appID is currently muted, dropping expired entries.
+func (m *MuteStore) Muted(appID int64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	until, ok := m.until[appID]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(m.until, appID)
+		return false
+	}
+	return true
+}
+
+// snoozeAction builds the ntfy Actions header value offering to mute appID
+// through the bridge's snooze endpoint, or "" when snoozing is not available.
+func snoozeAction(cfg *Config, appID int64) string {
+	if cfg.PublicURL == "" || cfg.HTTPListen == "" || cfg.SnoozeDuration <= 0 {
+		return ""
+	}
+	d := formatDuration(cfg.SnoozeDuration)
+	endpoint := fmt.Sprintf("%s/apps/%d/snooze?duration=%s&token=%s",
+		strings.TrimRight(cfg.PublicURL, "/"), appID, d, snoozeToken(cfg, appID))
+	return fmt.Sprintf("http, Snooze %s, %s, method=POST, clear=true", d, endpoint)
+}
+
+// snoozeToken authorizes the snooze action of appID without admin
+// credentials: an HMAC of the app ID keyed by SNOOZE_SECRET, so a link taken
+// from one notification only snoozes that app.
+func snoozeToken(cfg *Config, appID int64) string {
+	mac := hmac.New(sha256.New, []byte(cfg.SnoozeSecret))
+	fmt.Fprintf(mac, "snooze:%d", appID)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func validSnoozeToken(cfg *Config, appID int64, token string) bool {
+	return token != "" && hmac.Equal([]byte(token), []byte(snoozeToken(cfg, appID)))
+}
+
+// formatDuration drops the zero units time.Duration.String prints, e.g. 1h0m0s -> 1h.
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+func handleSnooze(b *Bridge) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid app id", http.StatusBadRequest)
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			b.mutes.Unmute(appID)
+			log.Printf("[SNOOZE] App %d unmuted", appID)
+			b.audit.Record(r.Context(), requestActor(b.cfg, r), "unmute", map[string]any{"app_id": appID})
+			writeJSON(w, http.StatusOK, map[string]any{"app_id": appID, "muted": false})
+			return
+		}
+
+		d := b.cfg.SnoozeDuration
+		if v := r.URL.Query().Get("duration"); v != "" {
+			if d, err = time.ParseDuration(v); err != nil || d <= 0 || d > snoozeMaxDuration {
+				http.Error(w, "invalid duration, expected up to "+formatDuration(snoozeMaxDuration), http.StatusBadRequest)
+				return
+			}
+		}
+		until := b.mutes.Mute(appID, d)
+		log.Printf("[SNOOZE] App %d muted until %s", appID, until.Format(time.RFC3339))
+		b.audit.Record(r.Context(), requestActor(b.cfg, r), "mute", map[string]any{"app_id": appID, "duration": formatDuration(d)})
+		writeJSON(w, http.StatusOK, map[string]any{"app_id": appID, "muted": true, "until": until})
+	}
+}
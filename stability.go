@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	resourceSampleInterval = time.Hour
+
+	// Leaks are judged over the last day of samples, and only once at
+	// least half of it has been sampled.
+	leakWindow     = 24 * time.Hour
+	leakMinSamples = 12
+)
+
+// resourceSample is one hourly reading of the bridge's own resource usage.
+// FDs is -1 where open files cannot be counted (outside Linux).
+type resourceSample struct {
+	Time       time.Time `json:"time"`
+	Goroutines int       `json:"goroutines"`
+	HeapBytes  uint64    `json:"heap_bytes"`
+	FDs        int       `json:"fds"`
+}
+
+func sampleResources() resourceSample {
+	// Collect first so the heap reading is the live heap, not garbage
+	// waiting for the next cycle; hourly, the cost does not matter.
+	runtime.GC()
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	return resourceSample{
+		Time:       time.Now(),
+		Goroutines: runtime.NumGoroutine(),
+		HeapBytes:  mem.HeapAlloc,
+		FDs:        countOpenFiles(),
+	}
+}
+
+func countOpenFiles() int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	return len(entries) - 1 // the directory being read
+}
+
+// RecordResources appends s to the persisted series, see volumeRetention.
+func (v *VolumeTracker) RecordResources(s resourceSample) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	cutoff := s.Time.AddDate(0, 0, -volumeRetention)
+	kept := v.state.Resources[:0]
+	for _, r := range v.state.Resources {
+		if r.Time.After(cutoff) {
+			kept = append(kept, r)
+		}
+	}
+	v.state.Resources = append(kept, s)
+}
+
+// ResourcesSince returns the samples taken after t, oldest first.
+func (v *VolumeTracker) ResourcesSince(t time.Time) []resourceSample {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	var out []resourceSample
+	for _, r := range v.state.Resources {
+		if r.Time.After(t) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// resourceMetric describes one sampled value and how much it may grow over
+// leakWindow before it counts as a leak.
+type resourceMetric struct {
+	name       string
+	value      func(resourceSample) float64
+	format     func(float64) string
+	minGrowth  float64 // absolute
+	minPercent float64
+}
+
+var resourceMetrics = []resourceMetric{
+	{"goroutines", func(s resourceSample) float64 { return float64(s.Goroutines) }, formatCount, 10, 20},
+	{"heap", func(s resourceSample) float64 { return float64(s.HeapBytes) }, formatMB, 5 << 20, 25},
+	{"open files", func(s resourceSample) float64 { return float64(s.FDs) }, formatCount, 10, 20},
+}
+
+func formatCount(v float64) string { return fmt.Sprintf("%.0f", v) }
+func formatMB(v float64) string    { return fmt.Sprintf("%.1f MB", v/(1<<20)) }
+
+// leakWarnings inspects samples (oldest first) for values that keep
+// growing. A metric is flagged when even the lowest reading of the last
+// third of the window is above the highest of the first third, so a single
+// busy hour is not mistaken for a trend, and by more than the metric's
+// thresholds.
+func leakWarnings(samples []resourceSample) []string {
+	if len(samples) < leakMinSamples {
+		return nil
+	}
+	third := len(samples) / 3
+	first, last := samples[:third], samples[len(samples)-third:]
+	span := formatDuration(samples[len(samples)-1].Time.Sub(samples[0].Time).Round(time.Hour))
+
+	var warnings []string
+	for _, m := range resourceMetrics {
+		if m.value(samples[0]) < 0 {
+			continue // not available on this platform
+		}
+		high, low := m.value(first[0]), m.value(last[0])
+		for _, s := range first {
+			high = max(high, m.value(s))
+		}
+		for _, s := range last {
+			low = min(low, m.value(s))
+		}
+		growth := low - high
+		if growth <= 0 || growth < m.minGrowth || (high > 0 && growth*100/high < m.minPercent) {
+			continue
+		}
+		from, to := m.value(samples[0]), m.value(samples[len(samples)-1])
+		warnings = append(warnings, fmt.Sprintf("%s grew from %s to %s over %s", m.name, m.format(from), m.format(to), span))
+	}
+	return warnings
+}
+
+// resourceSummary renders the latest sample and any leak warnings for the
+// daily digest, or nil if nothing was sampled yet.
+func resourceSummary(b *Bridge, now time.Time) []string {
+	samples := b.volume.ResourcesSince(now.Add(-leakWindow))
+	if len(samples) == 0 {
+		return nil
+	}
+	latest := samples[len(samples)-1]
+	usage := fmt.Sprintf("Resources: %d goroutines, %s heap", latest.Goroutines, formatMB(float64(latest.HeapBytes)))
+	if latest.FDs >= 0 {
+		usage += fmt.Sprintf(", %d open files", latest.FDs)
+	}
+	lines := []string{usage}
+	for _, w := range leakWarnings(samples) {
+		lines = append(lines, "Possible leak: "+w)
+	}
+	return lines
+}
+
+// runResourceMonitor samples goroutines, heap and open files every hour
+// into REPORT_DB for the daily digest, and logs when a metric starts to
+// look like a leak. The first sample is taken an hour after startup, once
+// connections and caches have settled.
+func runResourceMonitor(b *Bridge) {
+	ticker := time.NewTicker(resourceSampleInterval)
+	defer ticker.Stop()
+
+	warned := false
+	for range ticker.C {
+		s := sampleResources()
+		b.volume.RecordResources(s)
+		if err := b.volume.Save(); err != nil {
+			log.Printf("[REPORT ERROR] could not save %s: %v", b.cfg.ReportDBPath, err)
+		}
+
+		warnings := leakWarnings(b.volume.ResourcesSince(s.Time.Add(-leakWindow)))
+		if len(warnings) > 0 && !warned {
+			log.Printf("[RESOURCE WARN] possible leak: %s", strings.Join(warnings, "; "))
+		}
+		warned = len(warnings) > 0
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// staleCheckInterval is how often STALE_FORWARD_AFTER is checked.
+const staleCheckInterval = time.Minute
+
+// runStaleCheck alerts the error topic when nothing was forwarded for
+// STALE_FORWARD_AFTER while the stream claims to be connected: a
+// half-dead connection that never errors looks exactly like that. It
+// alerts once per stale period and again when forwarding resumes.
+func runStaleCheck(b *Bridge) {
+	cfg := b.cfg
+	ticker := time.NewTicker(staleCheckInterval)
+	defer ticker.Stop()
+
+	stale := false
+	for now := range ticker.C {
+		snap := b.stats.Snapshot(b.store)
+		connected, since, _ := b.stats.ConnectionState()
+		// Measure from the last forward, or from the connect if none came since
+		last := snap.LastDelivery
+		if since.After(last) {
+			last = since
+		}
+		age := now.Sub(last)
+
+		switch {
+		case connected && !stale && age >= cfg.StaleForwardAfter:
+			stale = true
+			lastRead := time.Unix(0, b.lastRead.Load())
+			log.Printf("[GOTIFY WARN] connected but nothing forwarded for %s", formatDuration(age.Round(time.Minute)))
+			body := fmt.Sprintf("The Gotify stream is connected, but nothing was forwarded for %s (STALE_FORWARD_AFTER=%s).\n",
+				formatDuration(age.Round(time.Minute)), formatDuration(cfg.StaleForwardAfter))
+			if snap.LastDelivery.IsZero() {
+				body += "Nothing was forwarded since the bridge started.\n"
+			} else {
+				body += fmt.Sprintf("Last forward: %s\n", snap.LastDelivery.Format("2006-01-02 15:04 MST"))
+			}
+			body += fmt.Sprintf("Last data from Gotify (message or ping): %s ago.\n"+
+				"If Gotify did receive messages meanwhile, the connection is half-dead; restart the bridge.",
+				formatDuration(now.Sub(lastRead).Round(time.Second)))
+			if err := sendNtfy(b.ctx, cfg, cfg.NtfyErrorTopic, "No messages forwarded", body, 7); err != nil {
+				log.Printf("[NTFY ERROR] failed to send stale forward alert: %v", err)
+				stale = false
+			}
+		case stale && age < cfg.StaleForwardAfter:
+			stale = false
+			log.Printf("[GOTIFY] forwarding resumed")
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// AppStats holds per-app delivery counters. It never contains message contents.
+type AppStats struct {
+	AppID        int64     `json:"app_id"`
+	Name         string    `json:"name,omitempty"`
+	Forwarded    int64     `json:"forwarded"`
+	Failed       int64     `json:"failed"`
+	LastDelivery time.Time `json:"last_delivery,omitzero"`
+	LastFailure  time.Time `json:"last_failure,omitzero"`
+}
+
+// StatsSnapshot is a point-in-time copy of Stats, safe to serialize.
+type StatsSnapshot struct {
+	Started      time.Time  `json:"started"`
+	Uptime       string     `json:"uptime"`
+	Connected    bool       `json:"connected"`
+	Forwarded    int64      `json:"forwarded"`
+	ForwardErrs  int64      `json:"forward_errors"`
+	ConnectErrs  int64      `json:"connect_errors"`
+	SyncErrs     int64      `json:"sync_errors"`
+	Dropped      int64      `json:"dropped"`
+	Duplicates   int64      `json:"duplicates_suppressed"`
+	LastDelivery time.Time  `json:"last_delivery,omitzero"`
+	SLA          SLAReport  `json:"sla"`
+	Apps         []AppStats `json:"apps"`
+}
+
+// Stats collects runtime counters shared by the forwarder, the sync loop and
+// the status page.
+type Stats struct {
+	mu          sync.Mutex
+	started     time.Time
+	connected   bool
+	changed     time.Time // when connected last changed
+	forwarded   int64
+	forwardErrs int64
+	connectErrs int64
+	syncErrs    int64
+	dropped     int64
+	duplicates  int64
+	lastDeliver time.Time
+	apps        map[int64]*AppStats
+
+	// Only exported on /metrics
+	received    int64
+	expired     int64
+	connects    int64
+	syncs       int64
+	topics      map[string]*topicStats
+	ntfyLatency *histogram
+
+	candidateEvals int64 // messages resolved with the candidate topic rules
+	candidateDiffs int64
+
+	queueWait      *histogram
+	forwardLatency *histogram
+	slowForwards   int64
+
+	SLA *SLATracker
+}
+
+func NewStats(sla *SLATracker) *Stats {
+	return &Stats{started: time.Now(), apps: make(map[int64]*AppStats), SLA: sla,
+		topics: make(map[string]*topicStats), ntfyLatency: newHistogram(ntfyLatencyBuckets),
+		queueWait: newHistogram(queueWaitBuckets), forwardLatency: newHistogram(forwardLatencyBuckets)}
+}
+
+func (s *Stats) app(appID int64) *AppStats {
+	a, ok := s.apps[appID]
+	if !ok {
+		a = &AppStats{AppID: appID}
+		s.apps[appID] = a
+	}
+	return a
+}
+
+func (s *Stats) RecordForward(appID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.forwarded++
+	s.lastDeliver = now
+	a := s.app(appID)
+	a.Forwarded++
+	a.LastDelivery = now
+	s.SLA.RecordNtfy(true)
+}
+
+func (s *Stats) RecordForwardError(appID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.forwardErrs++
+	a := s.app(appID)
+	a.Failed++
+	a.LastFailure = time.Now()
+	s.SLA.RecordNtfy(false)
+}
+
+func (s *Stats) RecordReceived() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.received++
+}
+
+// RecordPublish records one ntfy request for topic and how long it took.
+func (s *Stats) RecordPublish(topic string, d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.topics[topic]
+	if !ok {
+		t = &topicStats{}
+		s.topics[topic] = t
+	}
+	if err != nil {
+		t.failed++
+	} else {
+		t.published++
+	}
+	s.ntfyLatency.Observe(d.Seconds())
+}
+
+// RecordObserved counts a message observer mode would have published to topic.
+func (s *Stats) RecordObserved(topic string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.topics[topic]
+	if !ok {
+		t = &topicStats{}
+		s.topics[topic] = t
+	}
+	t.observed++
+}
+
+func (s *Stats) RecordCandidate(differs bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.candidateEvals++
+	if differs {
+		s.candidateDiffs++
+	}
+}
+
+func (s *Stats) candidateCounts() (evaluated, differed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.candidateEvals, s.candidateDiffs
+}
+
+// connectCount returns how often the stream connected, reconnects included.
+func (s *Stats) connectCount() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connects
+}
+
+func (s *Stats) RecordQueueWait(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queueWait.Observe(d.Seconds())
+}
+
+// RecordForwardLatency records the end-to-end time of a forwarded message.
+func (s *Stats) RecordForwardLatency(d time.Duration, slow bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.forwardLatency.Observe(d.Seconds())
+	if slow {
+		s.slowForwards++
+	}
+}
+
+func (s *Stats) RecordDrop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropped++
+}
+
+func (s *Stats) RecordExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expired++
+}
+
+// lossCounts returns the messages that failed to forward or expired unsent.
+func (s *Stats) lossCounts() (failed, expired int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.forwardErrs, s.expired
+}
+
+func (s *Stats) RecordDuplicate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.duplicates++
+}
+
+func (s *Stats) RecordConnectError() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connectErrs++
+}
+
+func (s *Stats) RecordSync() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.syncs++
+}
+
+func (s *Stats) RecordSyncError() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.syncErrs++
+}
+
+func (s *Stats) SetConnected(connected bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if connected != s.connected {
+		s.changed = time.Now()
+	}
+	s.connected = connected
+	if connected {
+		s.connects++
+	}
+}
+
+func (s *Stats) Connected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connected
+}
+
+// ConnectionState reports whether the stream is connected, since when, and
+// whether it ever was.
+func (s *Stats) ConnectionState() (connected bool, since time.Time, ever bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connected, s.changed, s.connects > 0
+}
+
+// Snapshot copies the current counters. App names are resolved from store.
+func (s *Stats) Snapshot(store *AppStore) StatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap := StatsSnapshot{
+		Started:      s.started,
+		Uptime:       time.Since(s.started).Round(time.Second).String(),
+		Connected:    s.connected,
+		Forwarded:    s.forwarded,
+		ForwardErrs:  s.forwardErrs,
+		ConnectErrs:  s.connectErrs,
+		SyncErrs:     s.syncErrs,
+		Dropped:      s.dropped,
+		Duplicates:   s.duplicates,
+		LastDelivery: s.lastDeliver,
+		SLA:          s.SLA.Report(),
+	}
+	for _, a := range s.apps {
+		c := *a
+		if app, ok := store.Get(a.AppID); ok {
+			c.Name = app.Name
+		}
+		snap.Apps = append(snap.Apps, c)
+	}
+	sort.Slice(snap.Apps, func(i, j int) bool { return snap.Apps[i].AppID < snap.Apps[j].AppID })
+	return snap
+}
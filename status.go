@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var statusTmpl = template.Must(template.New("status").Funcs(template.FuncMap{"pct": formatPct}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="60">
+<title>Gotify to ntfy status</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 4px 12px; border-bottom: 1px solid #ddd; text-align: left; }
+.ok { color: #2a7d2a; }
+.down { color: #b22222; }
+</style>
+</head>
+<body>
+<h1>Gotify to ntfy</h1>
+<p>Gotify connection: {{if .Connected}}<span class="ok">connected</span>{{else}}<span class="down">disconnected</span>{{end}}</p>
+<p>Up since {{.Started.Format "2006-01-02 15:04:05 MST"}} ({{.Uptime}})</p>
+<p>Forwarded: {{.Forwarded}} &middot; Forward errors: {{.ForwardErrs}} &middot; Connection errors: {{.ConnectErrs}} &middot; Sync errors: {{.SyncErrs}} &middot; Dropped: {{.Dropped}} &middot; Duplicates suppressed: {{.Duplicates}}</p>
+<h2>Last {{.SLA.WindowDays}} days</h2>
+<p>Bridge uptime: {{pct .SLA.BridgeUptimePct}} &middot; Gotify availability: {{pct .SLA.GotifyAvailablePct}} &middot; ntfy success rate: {{pct .SLA.NtfySuccessPct}}</p>
+<table>
+<tr><th>App</th><th>Forwarded</th><th>Failed</th><th>Last delivery</th></tr>
+{{range .Apps}}<tr><td>{{if .Name}}{{.Name}}{{else}}#{{.AppID}}{{end}}</td><td>{{.Forwarded}}</td><td>{{.Failed}}</td><td>{{if not .LastDelivery.IsZero}}{{.LastDelivery.Format "2006-01-02 15:04:05"}}{{else}}-{{end}}</td></tr>
+{{end}}</table>
+<p><small>Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}}</small></p>
+</body>
+</html>
+`))
+
+// formatPct renders an SLAReport percentage, where -1 means no data.
+func formatPct(p float64) string {
+	if p < 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.3f%%", p)
+}
+
+// writeStatusPage renders status.json and status.html into cfg.StatusDir.
+// Both files are replaced atomically so a web server never serves a partial file.
+func writeStatusPage(cfg *Config, store *AppStore, stats *Stats) error {
+	snap := stats.Snapshot(store)
+
+	if err := writeFileAtomic(filepath.Join(cfg.StatusDir, "status.json"), func(f *os.File) error {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return enc.Encode(snap)
+	}); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filepath.Join(cfg.StatusDir, "status.html"), func(f *os.File) error {
+		return statusTmpl.Execute(f, struct {
+			StatsSnapshot
+			Generated time.Time
+		}{snap, time.Now()})
+	})
+}
+
+func writeFileAtomic(path string, write func(f *os.File) error) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func runStatusPage(cfg *Config, store *AppStore, stats *Stats) {
+	if err := os.MkdirAll(cfg.StatusDir, 0o755); err != nil {
+		log.Printf("[STATUS ERROR] could not create status dir %s: %v", cfg.StatusDir, err)
+		return
+	}
+
+	ticker := time.NewTicker(cfg.StatusInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := writeStatusPage(cfg, store, stats); err != nil {
+			log.Printf("[STATUS ERROR] could not write status page: %v", err)
+		} else {
+			dbg(cfg, "[STATUS] Wrote status page to %s", cfg.StatusDir)
+		}
+		<-ticker.C
+	}
+}
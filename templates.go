@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// messageData is passed to the title and body templates of
+// NTFY_TITLE_TEMPLATE, NTFY_BODY_TEMPLATE and profiles.
+type messageData struct {
+	App      string
+	AppID    int64
+	ID       int64
+	Title    string
+	Message  string
+	Priority int // Gotify priority
+	Topic    string
+	Extras   map[string]any
+	Received time.Time
+}
+
+// parseMessageTemplate parses a title or body template; "" means none.
+func parseMessageTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	return template.New(name).Option("missingkey=zero").Parse(text)
+}
+
+// renderMessage returns the ntfy title and body for msg. Title and body each
+// use the app's profile template, else the global one, else the Gotify text
+// unchanged. A template that fails to render falls back to the Gotify text.
+func renderMessage(cfg *Config, profile Profile, msg GotifyMessage, app GotifyApp, topic string) (title, body string) {
+	title, body = msg.Title, msg.Message
+	titleTpl, bodyTpl := cfg.titleTpl, cfg.bodyTpl
+	if profile.titleTpl != nil {
+		titleTpl = profile.titleTpl
+	}
+	if profile.bodyTpl != nil {
+		bodyTpl = profile.bodyTpl
+	}
+	if titleTpl == nil && bodyTpl == nil {
+		return title, body
+	}
+
+	data := messageData{App: app.Name, AppID: msg.AppID, ID: msg.ID, Title: msg.Title, Message: msg.Message,
+		Priority: msg.Priority, Topic: topic, Extras: msg.Extras, Received: msg.received}
+	render := func(tpl *template.Template, fallback string) string {
+		if tpl == nil {
+			return fallback
+		}
+		var sb strings.Builder
+		if err := tpl.Execute(&sb, data); err != nil {
+			log.Printf("[TEMPLATE WARN] %s template failed for message %d, using the Gotify text: %v", tpl.Name(), msg.ID, err)
+			return fallback
+		}
+		return sb.String()
+	}
+	return render(titleTpl, title), render(bodyTpl, body)
+}
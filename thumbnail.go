@@ -0,0 +1,70 @@
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+)
+
+// thumbnailTypes are the image types that can be downscaled. GIFs are left
+// alone so animations survive.
+var thumbnailTypes = map[string]bool{"image/jpeg": true, "image/png": true}
+
+// thumbnail scales img down to fit in maxDim×maxDim (if larger) and
+// re-encodes it, as JPEG with the given quality when opaque, as PNG otherwise.
+func thumbnail(data []byte, maxDim, quality int) ([]byte, string, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+
+	img := src
+	b := src.Bounds()
+	if maxDim > 0 && (b.Dx() > maxDim || b.Dy() > maxDim) {
+		w, h := maxDim, b.Dy()*maxDim/b.Dx()
+		if b.Dy() > b.Dx() {
+			w, h = b.Dx()*maxDim/b.Dy(), maxDim
+		}
+		img = downscale(src, max(w, 1), max(h, 1))
+	}
+
+	var buf bytes.Buffer
+	if o, ok := img.(interface{ Opaque() bool }); ok && o.Opaque() {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+		return buf.Bytes(), "image/jpeg", err
+	}
+	err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&buf, img)
+	return buf.Bytes(), "image/png", err
+}
+
+// downscale resizes src to w×h by averaging the source pixels covered by
+// each destination pixel.
+func downscale(src image.Image, w, h int) *image.NRGBA {
+	sb := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		y0 := sb.Min.Y + y*sb.Dy()/h
+		y1 := max(sb.Min.Y+(y+1)*sb.Dy()/h, y0+1)
+		for x := range w {
+			x0 := sb.Min.X + x*sb.Dx()/w
+			x1 := max(sb.Min.X+(x+1)*sb.Dx()/w, x0+1)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			// Averaged premultiplied values; convert back to non-premultiplied
+			c := color.RGBA64{uint16(r / n), uint16(g / n), uint16(bl / n), uint16(a / n)}
+			dst.Set(x, y, c)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,13 @@
+//go:build minimal
+
+package main
+
+import "errors"
+
+// The minimal build has no image codecs, so attachments are never
+// downscaled, see thumbnail.go.
+var thumbnailTypes = map[string]bool{}
+
+func thumbnail(data []byte, maxDim, quality int) ([]byte, string, error) {
+	return nil, "", errors.New("image downscaling is not included in the minimal build")
+}
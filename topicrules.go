@@ -0,0 +1,398 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// topicEnv holds the message fields TOPIC_RULES conditions can refer to.
+type topicEnv struct {
+	App      string
+	AppID    int64
+	Priority int
+	Title    string
+	Message  string
+}
+
+func (e topicEnv) field(name string) string {
+	switch name {
+	case "app":
+		return e.App
+	case "app_id":
+		return strconv.FormatInt(e.AppID, 10)
+	case "priority":
+		return strconv.Itoa(e.Priority)
+	case "title":
+		return e.Title
+	case "message":
+		return e.Message
+	}
+	return ""
+}
+
+var topicFields = map[string]bool{"app": true, "app_id": true, "priority": true, "title": true, "message": true}
+
+// topicCond is a parsed rule condition.
+type topicCond interface {
+	eval(e topicEnv) bool
+}
+
+type alwaysCond struct{}
+type andCond struct{ l, r topicCond }
+type orCond struct{ l, r topicCond }
+type notCond struct{ c topicCond }
+
+type cmpCond struct {
+	field  string
+	op     string // == != < <= > >= in contains matches
+	values []string
+	re     *regexp.Regexp
+}
+
+func (alwaysCond) eval(topicEnv) bool   { return true }
+func (c andCond) eval(e topicEnv) bool  { return c.l.eval(e) && c.r.eval(e) }
+func (c orCond) eval(e topicEnv) bool   { return c.l.eval(e) || c.r.eval(e) }
+func (c notCond) eval(e topicEnv) bool  { return !c.c.eval(e) }
+func (c *cmpCond) eval(e topicEnv) bool { return c.compare(e.field(c.field)) }
+
+func (c *cmpCond) compare(v string) bool {
+	switch c.op {
+	case "in":
+		for _, want := range c.values {
+			if equalValues(v, want) {
+				return true
+			}
+		}
+		return false
+	case "contains":
+		return strings.Contains(strings.ToLower(v), strings.ToLower(c.values[0]))
+	case "matches":
+		return c.re.MatchString(v)
+	case "==":
+		return equalValues(v, c.values[0])
+	case "!=":
+		return !equalValues(v, c.values[0])
+	}
+
+	// Ordering compares numbers numerically and everything else as text
+	want := c.values[0]
+	var cmp int
+	a, errA := strconv.ParseFloat(v, 64)
+	b, errB := strconv.ParseFloat(want, 64)
+	if errA == nil && errB == nil {
+		cmp = compareFloat(a, b)
+	} else {
+		cmp = strings.Compare(strings.ToLower(v), strings.ToLower(want))
+	}
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default: // ">="
+		return cmp >= 0
+	}
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func equalValues(a, b string) bool {
+	if x, err := strconv.ParseFloat(a, 64); err == nil {
+		if y, err := strconv.ParseFloat(b, 64); err == nil {
+			return x == y
+		}
+	}
+	return strings.EqualFold(a, b)
+}
+
+// TopicRule sends messages matching Cond to Topic, a template that may use
+// {app}, {app_id} and {priority}.
+type TopicRule struct {
+	Source string
+	Cond   topicCond
+	Topic  string
+}
+
+// TopicRules is an ordered rule list; the first matching rule wins.
+type TopicRules []TopicRule
+
+// Resolve returns the topic of the first matching rule, if any.
+func (rs TopicRules) Resolve(e topicEnv) (string, bool) {
+	_, topic, ok := rs.Match(e)
+	return topic, ok
+}
+
+// Match returns the first matching rule and the topic it expands to.
+func (rs TopicRules) Match(e topicEnv) (TopicRule, string, bool) {
+	for _, r := range rs {
+		if r.Cond.eval(e) {
+			topic := strings.NewReplacer(
+				"{app}", sanitizeTopic(e.App),
+				"{app_id}", strconv.FormatInt(e.AppID, 10),
+				"{priority}", strconv.Itoa(e.Priority),
+			).Replace(r.Topic)
+			return r, topic, true
+		}
+	}
+	return TopicRule{}, "", false
+}
+
+// parseTopicRules parses rules of the form "condition -> topic", separated
+// by ";" or newlines, e.g.
+//
+//	priority >= 8 -> critical; app in [Proxmox, "Uptime Kuma"] -> infra; * -> {app}
+func parseTopicRules(src string) (TopicRules, error) {
+	var rules TopicRules
+	for _, line := range splitOutsideQuotes(src, func(r rune) bool { return r == ';' || r == '\n' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := lastIndexOutsideQuotes(line, "->")
+		if i < 0 {
+			return nil, fmt.Errorf("rule %q: missing \"-> topic\"", line)
+		}
+		topic := strings.TrimSpace(line[i+2:])
+		if topic == "" || strings.ContainsFunc(topic, unicode.IsSpace) {
+			return nil, fmt.Errorf("rule %q: invalid topic %q", line, topic)
+		}
+		cond, err := parseTopicCond(strings.TrimSpace(line[:i]))
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", line, err)
+		}
+		rules = append(rules, TopicRule{Source: line, Cond: cond, Topic: topic})
+	}
+	return rules, nil
+}
+
+func splitOutsideQuotes(s string, sep func(rune) bool) []string {
+	var parts []string
+	var quote rune
+	start := 0
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case sep(r):
+			parts = append(parts, s[start:i])
+			start = i + len(string(r))
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func lastIndexOutsideQuotes(s, sub string) int {
+	last := -1
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case strings.HasPrefix(s[i:], sub):
+			last = i
+		}
+	}
+	return last
+}
+
+// condParser is a recursive descent parser for rule conditions:
+//
+//	or   = and { "or" and }
+//	and  = not { "and" not }
+//	not  = "not" not | "(" or ")" | "*" | cmp
+//	cmp  = field op value | field "in" "[" value { "," value } "]"
+type condParser struct {
+	toks []string
+	pos  int
+}
+
+func parseTopicCond(src string) (topicCond, error) {
+	toks, err := tokenizeCond(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &condParser{toks: toks}
+	c, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected %q", p.toks[p.pos])
+	}
+	return c, nil
+}
+
+func (p *condParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *condParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *condParser) or() (topicCond, error) {
+	l, err := p.and()
+	for err == nil && strings.EqualFold(p.peek(), "or") {
+		p.next()
+		var r topicCond
+		if r, err = p.and(); err == nil {
+			l = orCond{l, r}
+		}
+	}
+	return l, err
+}
+
+func (p *condParser) and() (topicCond, error) {
+	l, err := p.not()
+	for err == nil && strings.EqualFold(p.peek(), "and") {
+		p.next()
+		var r topicCond
+		if r, err = p.not(); err == nil {
+			l = andCond{l, r}
+		}
+	}
+	return l, err
+}
+
+func (p *condParser) not() (topicCond, error) {
+	switch t := p.peek(); {
+	case strings.EqualFold(t, "not"):
+		p.next()
+		c, err := p.not()
+		return notCond{c}, err
+	case t == "(":
+		p.next()
+		c, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		return c, nil
+	case t == "*":
+		p.next()
+		return alwaysCond{}, nil
+	}
+	return p.cmp()
+}
+
+func (p *condParser) cmp() (topicCond, error) {
+	field := strings.ToLower(p.next())
+	if !topicFields[field] {
+		return nil, fmt.Errorf("unknown field %q (want app, app_id, priority, title or message)", field)
+	}
+	c := &cmpCond{field: field, op: strings.ToLower(p.next())}
+	switch c.op {
+	case "==", "!=", "<", "<=", ">", ">=", "contains", "matches":
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		c.values = []string{v}
+		if c.op == "matches" {
+			if c.re, err = regexp.Compile(v); err != nil {
+				return nil, err
+			}
+		}
+	case "in":
+		if p.next() != "[" {
+			return nil, fmt.Errorf("expected [ after in")
+		}
+		for {
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			c.values = append(c.values, v)
+			if sep := p.next(); sep == "]" {
+				break
+			} else if sep != "," {
+				return nil, fmt.Errorf("expected , or ] in list")
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown operator %q after %s", c.op, field)
+	}
+	return c, nil
+}
+
+func (p *condParser) value() (string, error) {
+	t := p.next()
+	switch {
+	case t == "":
+		return "", fmt.Errorf("missing value")
+	case t[0] == '"' || t[0] == '\'':
+		return t[1 : len(t)-1], nil
+	case strings.ContainsAny(t, "[](),"):
+		return "", fmt.Errorf("unexpected %q", t)
+	}
+	return t, nil
+}
+
+// tokenizeCond splits a condition into quoted strings, operators,
+// punctuation and barewords. Quoted strings keep their quotes.
+func tokenizeCond(s string) ([]string, error) {
+	var toks []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"' || c == '\'':
+			j := strings.IndexByte(s[i+1:], c)
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			toks = append(toks, s[i:i+j+2])
+			i += j + 2
+		case strings.ContainsRune("[](),*", rune(c)):
+			toks = append(toks, string(c))
+			i++
+		case strings.ContainsRune("=!<>", rune(c)):
+			if i+1 < len(s) && s[i+1] == '=' {
+				toks = append(toks, s[i:i+2])
+				i += 2
+			} else if c == '<' || c == '>' {
+				toks = append(toks, string(c))
+				i++
+			} else {
+				return nil, fmt.Errorf("unexpected %q", c)
+			}
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\"'[](),=!<>", rune(s[j])) {
+				j++
+			}
+			toks = append(toks, s[i:j])
+			i = j
+		}
+	}
+	return toks, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import "testing"
+
+func TestTopicRulesResolve(t *testing.T) {
+	rules, err := parseTopicRules(`
+# critical first
+priority >= 8 -> critical
+app in [Proxmox, "Uptime Kuma"] and not title contains test -> infra
+message matches "^backup (ok|done)" -> backups; app_id == 7 or app == 'Home Assistant' -> home
+* -> {app}_{priority}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 5 {
+		t.Fatalf("got %d rules, want 5", len(rules))
+	}
+
+	tests := []struct {
+		env  topicEnv
+		want string
+	}{
+		{topicEnv{App: "Proxmox", Priority: 9}, "critical"},
+		{topicEnv{App: "proxmox", Priority: 5, Title: "Disk"}, "infra"}, // case-insensitive
+		{topicEnv{App: "Uptime Kuma", Priority: 5}, "infra"},
+		{topicEnv{App: "Proxmox", Priority: 5, Title: "Test alert"}, "proxmox_5"},
+		{topicEnv{App: "Backup", Message: "backup done"}, "backups"},
+		{topicEnv{App: "Backup", Message: "Backup done"}, "backup_0"}, // regexes are case-sensitive
+		{topicEnv{App: "Lights", AppID: 7}, "home"},
+		{topicEnv{App: "Home Assistant", AppID: 3, Priority: 2}, "home"},
+		{topicEnv{App: "Uptime Kuma", Priority: 5, Title: "test"}, "uptime_kuma_5"},
+	}
+	for _, tt := range tests {
+		got, ok := rules.Resolve(tt.env)
+		if !ok || got != tt.want {
+			t.Errorf("%+v: got %q (%v), want %q", tt.env, got, ok, tt.want)
+		}
+	}
+}
+
+func TestTopicRulesComparisons(t *testing.T) {
+	tests := []struct {
+		cond string
+		env  topicEnv
+		want bool
+	}{
+		{"priority > 4", topicEnv{Priority: 10}, true}, // numeric, not "10" < "4"
+		{"priority < 4", topicEnv{Priority: 10}, false},
+		{"priority <= 4", topicEnv{Priority: 4}, true},
+		{"priority != 4", topicEnv{Priority: 4}, false},
+		{"app >= m", topicEnv{App: "Proxmox"}, true}, // text compares case-insensitively
+		{"app == 'uptime kuma'", topicEnv{App: "Uptime Kuma"}, true},
+		{"not (app == a or app == b)", topicEnv{App: "b"}, false},
+		{"app == a or app == b and priority > 5", topicEnv{App: "a"}, true}, // and binds tighter
+		{"(app == a or app == b) and priority > 5", topicEnv{App: "a"}, false},
+		{"title contains DISK", topicEnv{Title: "disk full"}, true},
+		{"app_id in [1, 2, 3]", topicEnv{AppID: 2}, true},
+	}
+	for _, tt := range tests {
+		rules, err := parseTopicRules(tt.cond + " -> t")
+		if err != nil {
+			t.Errorf("%q: %v", tt.cond, err)
+			continue
+		}
+		if _, got := rules.Resolve(tt.env); got != tt.want {
+			t.Errorf("%q with %+v: got %v, want %v", tt.cond, tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestParseTopicRulesErrors(t *testing.T) {
+	for _, src := range []string{
+		"priority >= 8",              // no target
+		"priority >= 8 -> ",          // empty target
+		"priority >= 8 -> two words", // target with a space
+		"severity > 3 -> t",          // unknown field
+		"priority >> 3 -> t",
+		"(priority > 3 -> t",   // unbalanced
+		"app in Proxmox -> t",  // in needs a list
+		"title matches ( -> t", // bad regexp
+		"app == 'unterminated -> t",
+		"priority > 3 extra -> t",
+	} {
+		if _, err := parseTopicRules(src); err == nil {
+			t.Errorf("%q: want an error", src)
+		}
+	}
+
+	// A target after "->" inside quotes is part of the value
+	rules, err := parseTopicRules(`title == "a -> b" -> arrows`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := rules.Resolve(topicEnv{Title: "a -> b"}); got != "arrows" {
+		t.Errorf("quoted arrow: got %q", got)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+// sampleTrace reports whether msg's pipeline should be logged span by span:
+// its app is listed in TRACE_APPS, or it falls into the TRACE_SAMPLE_RATE
+// share of all messages.
+func sampleTrace(cfg *Config, store *AppStore, msg GotifyMessage) bool {
+	if len(cfg.TraceApps) > 0 {
+		app, _ := store.Get(msg.AppID)
+		for _, want := range cfg.TraceApps {
+			if id, err := strconv.ParseInt(want, 10, 64); err == nil && id == msg.AppID {
+				return true
+			}
+			if app.Name != "" && strings.EqualFold(app.Name, want) {
+				return true
+			}
+		}
+	}
+	return cfg.TraceSampleRate > 0 && rand.Float64() < cfg.TraceSampleRate
+}
+
+// startMessageSpan begins the root span of msg's pipeline. Without OTLP
+// tracing, only sampled messages get spans, which are just logged; the
+// rest of the pipeline sees nil spans and records nothing.
+func startMessageSpan(b *Bridge, msg GotifyMessage) *Span {
+	span := b.tracer.Start("gotify.message", spanKindConsumer)
+	if sampleTrace(b.cfg, b.store, msg) {
+		if span == nil {
+			span = newSpan(nil, "gotify.message", spanKindConsumer)
+		}
+		span.logged = true
+	}
+	return span
+}
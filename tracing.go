@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	traceBatchSize     = 512
+	traceFlushInterval = 5 * time.Second
+)
+
+// OTLP span kinds and status codes
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+	spanKindConsumer = 5
+
+	statusOK    = 1
+	statusError = 2
+)
+
+// Tracer records spans of the forward pipeline and exports them in batches
+// as OTLP/HTTP JSON, so no OpenTelemetry SDK is needed. Spans of sampled
+// messages are logged as well, see startMessageSpan.
+type Tracer struct {
+	endpoint string
+	headers  http.Header
+	service  string
+	client   *http.Client
+
+	mu    sync.Mutex
+	spans []otlpSpan
+	kick  chan struct{}
+}
+
+// NewTracer returns nil when no OTLP endpoint is configured; all span
+// methods are no-ops on a nil tracer and nil spans.
+func NewTracer(cfg *Config) *Tracer {
+	if cfg.OTLPEndpoint == "" {
+		return nil
+	}
+	return &Tracer{
+		endpoint: cfg.OTLPEndpoint,
+		headers:  cfg.otlpHeaders,
+		service:  cfg.OTLPServiceName,
+		client:   &http.Client{Timeout: httpTimeout, Transport: &userAgentTransport{base: http.DefaultTransport, ua: cfg.UserAgent}},
+		kick:     make(chan struct{}, 1),
+	}
+}
+
+// Span is one timed step of the pipeline.
+type Span struct {
+	tracer  *Tracer
+	traceID [16]byte
+	id      [8]byte
+	parent  [8]byte
+	name    string
+	kind    int
+	start   time.Time
+	logged  bool // also written to the log when it ends, see logEnd
+
+	mu    sync.Mutex
+	attrs map[string]any
+	err   error
+	ended bool
+}
+
+// Start begins a new trace.
+func (t *Tracer) Start(name string, kind int) *Span {
+	if t == nil {
+		return nil
+	}
+	return newSpan(t, name, kind)
+}
+
+// newSpan begins a trace on t, which may be nil for a span that is only logged.
+func newSpan(t *Tracer, name string, kind int) *Span {
+	s := &Span{tracer: t, name: name, kind: kind, start: time.Now()}
+	_, _ = rand.Read(s.traceID[:])
+	_, _ = rand.Read(s.id[:])
+	return s
+}
+
+// Child begins a span within s's trace.
+func (s *Span) Child(name string, kind int) *Span {
+	if s == nil {
+		return nil
+	}
+	c := &Span{tracer: s.tracer, traceID: s.traceID, parent: s.id, name: name, kind: kind, start: time.Now(), logged: s.logged}
+	_, _ = rand.Read(c.id[:])
+	return c
+}
+
+func (s *Span) Set(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attrs == nil {
+		s.attrs = make(map[string]any)
+	}
+	s.attrs[key] = value
+}
+
+// End finishes the span with err as its status and queues it for export.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended, s.err = true, err
+	s.mu.Unlock()
+	end := time.Now()
+	if s.logged {
+		s.logEnd(end)
+	}
+	if s.tracer != nil {
+		s.tracer.queue(s.otlp(end))
+	}
+}
+
+// Traceparent returns the W3C trace context header value for s.
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	return "00-" + hex.EncodeToString(s.traceID[:]) + "-" + hex.EncodeToString(s.id[:]) + "-01"
+}
+
+type otlpSpan struct {
+	TraceID      string          `json:"traceId"`
+	SpanID       string          `json:"spanId"`
+	ParentSpanID string          `json:"parentSpanId,omitempty"`
+	Name         string          `json:"name"`
+	Kind         int             `json:"kind"`
+	Start        string          `json:"startTimeUnixNano"`
+	End          string          `json:"endTimeUnixNano"`
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+	Status       otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// otlpValue encodes v as an OTLP AnyValue; 64-bit integers are strings in OTLP JSON.
+func otlpValue(v any) map[string]any {
+	switch v := v.(type) {
+	case int:
+		return map[string]any{"intValue": strconv.Itoa(v)}
+	case int64:
+		return map[string]any{"intValue": strconv.FormatInt(v, 10)}
+	case bool:
+		return map[string]any{"boolValue": v}
+	case string:
+		return map[string]any{"stringValue": v}
+	default:
+		return map[string]any{"stringValue": fmt.Sprint(v)}
+	}
+}
+
+func (s *Span) otlp(end time.Time) otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := otlpSpan{
+		TraceID: hex.EncodeToString(s.traceID[:]),
+		SpanID:  hex.EncodeToString(s.id[:]),
+		Name:    s.name,
+		Kind:    s.kind,
+		Start:   strconv.FormatInt(s.start.UnixNano(), 10),
+		End:     strconv.FormatInt(end.UnixNano(), 10),
+		Status:  otlpStatus{Code: statusOK},
+	}
+	if s.parent != [8]byte{} {
+		o.ParentSpanID = hex.EncodeToString(s.parent[:])
+	}
+	for k, v := range s.attrs {
+		o.Attributes = append(o.Attributes, otlpAttribute{Key: k, Value: otlpValue(v)})
+	}
+	if s.err != nil {
+		o.Status = otlpStatus{Code: statusError, Message: s.err.Error()}
+	}
+	return o
+}
+
+func (t *Tracer) queue(o otlpSpan) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.spans) >= 4*traceBatchSize {
+		// The collector is not keeping up; drop rather than grow without bound
+		return
+	}
+	t.spans = append(t.spans, o)
+	if len(t.spans) >= traceBatchSize {
+		select {
+		case t.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush exports all queued spans.
+func (t *Tracer) Flush(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+
+	payload := map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": []otlpAttribute{
+				{Key: "service.name", Value: otlpValue(t.service)},
+				{Key: "service.version", Value: otlpValue(version)},
+			}},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": serviceName},
+				"spans": spans,
+			}},
+		}},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("[TRACE ERROR] could not encode spans: %v", err)
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("[TRACE ERROR] %v", err)
+		return
+	}
+	for k, v := range t.headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		log.Printf("[TRACE ERROR] could not export %d spans: %v", len(spans), err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("[TRACE ERROR] collector answered %s for %d spans", resp.Status, len(spans))
+	}
+}
+
+// runTracer exports spans until ctx is done; shutdown flushes the rest.
+func runTracer(ctx context.Context, t *Tracer) {
+	ticker := time.NewTicker(traceFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-t.kick:
+		case <-ctx.Done():
+			return
+		}
+		t.Flush(ctx)
+	}
+}
+
+// parseOTLPHeaders parses OTEL_EXPORTER_OTLP_HEADERS ("k1=v1,k2=v2", values URL-encoded).
+func parseOTLPHeaders(s string) (http.Header, error) {
+	h := http.Header{}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected key=value, got %q", pair)
+		}
+		v, err := url.PathUnescape(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %w", k, err)
+		}
+		h.Set(strings.TrimSpace(k), v)
+	}
+	return h, nil
+}
+
+// logEnd writes s as one log line, prefixed with the start of its trace ID
+// so the spans of a message can be grepped together.
+func (s *Span) logEnd(end time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.attrs))
+	for k := range s.attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var line strings.Builder
+	fmt.Fprintf(&line, "[TRACE %s] %s %s", hex.EncodeToString(s.traceID[:4]), s.name, end.Sub(s.start).Round(time.Microsecond))
+	for _, k := range keys {
+		fmt.Fprintf(&line, " %s=%v", k, s.attrs[k])
+	}
+	if s.err != nil {
+		fmt.Fprintf(&line, " error=%q", s.err.Error())
+	}
+	log.Print(line.String())
+}
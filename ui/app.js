@@ -0,0 +1,129 @@
+// Polls /ui/state and renders the dashboard; forward results arrive live
+// from /ui/events. Text is only ever set via textContent, never as HTML.
+"use strict";
+
+const REFRESH_MS = 5000;
+const MAX_FEED_ROWS = 200;
+
+function text(id, value) {
+  document.getElementById(id).textContent = value;
+}
+
+function cell(row, value, cls) {
+  const td = row.insertCell();
+  td.textContent = value;
+  if (cls) td.className = cls;
+}
+
+function formatTime(ts) {
+  if (!ts) return "-";
+  return new Date(ts).toLocaleString();
+}
+
+function render(s) {
+  const conn = document.getElementById("connection");
+  conn.textContent = s.connected ? "connected" : "disconnected";
+  conn.className = "badge " + (s.connected ? "ok" : "down");
+  conn.title = s.gotify + (s.connected_since ? " since " + formatTime(s.connected_since) : "");
+
+  const mode = document.getElementById("mode");
+  const modes = [];
+  if (s.paused) modes.push("paused");
+  if (s.observe_only) modes.push("observer mode");
+  mode.hidden = modes.length === 0;
+  mode.textContent = modes.join(", ");
+
+  text("forwarded", s.forwarded);
+  text("forward_errors", s.forward_errors);
+  text("connect_errors", s.connect_errors);
+  text("dropped", s.dropped);
+  text("stream_queue", s.stream_queue_capacity ? s.stream_queue + " / " + s.stream_queue_capacity : "-");
+  text("offline_buffer", s.offline_buffer);
+
+  const apps = document.getElementById("apps");
+  apps.replaceChildren();
+  for (const a of s.apps) {
+    const row = apps.insertRow();
+    cell(row, a.id);
+    cell(row, a.name + (a.muted ? " (muted)" : ""), a.muted ? "muted" : "");
+    cell(row, a.topic);
+    cell(row, a.forwarded);
+    cell(row, a.failed, a.failed ? "down" : "");
+    cell(row, formatTime(a.last_delivery));
+    const test = document.createElement("button");
+    test.textContent = "Test";
+    test.onclick = () => sendTest(a.id);
+    row.insertCell().appendChild(test);
+  }
+
+  text("footer", "Version " + s.version + " · up " + s.uptime + " · updated " + new Date().toLocaleTimeString());
+}
+
+async function refresh() {
+  const error = document.getElementById("error");
+  try {
+    const resp = await fetch("state", { cache: "no-store" });
+    if (!resp.ok) throw new Error(resp.status + " " + resp.statusText);
+    render(await resp.json());
+    error.hidden = true;
+  } catch (err) {
+    error.textContent = "Could not load state: " + err.message;
+    error.hidden = false;
+  }
+}
+
+// sendTest runs a test message for the app through the real forward path;
+// its result also shows up in the live feed.
+async function sendTest(appID) {
+  const result = document.getElementById("test_result");
+  const priority = Number(document.getElementById("test_priority").value);
+  result.textContent = "Sending…";
+  result.className = "";
+  try {
+    const resp = await fetch("test", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ app_id: appID, priority: priority }),
+    });
+    const r = await resp.json();
+    if (r.error) throw new Error(r.error);
+    result.textContent = "App " + appID + ": " + r.status + " to " + r.topic + (r.rule ? " (rule " + r.rule + ")" : "");
+    result.className = r.status === "sent" ? "ok" : "";
+  } catch (err) {
+    result.textContent = "Test failed: " + err.message;
+    result.className = "down";
+  }
+}
+
+function addForward(e) {
+  const recent = document.getElementById("recent");
+  const row = recent.insertRow(0);
+  cell(row, formatTime(e.time));
+  cell(row, e.app || "#" + e.app_id);
+  cell(row, e.topic || "-");
+  cell(row, e.rule || "-");
+  cell(row, e.priority || "-");
+  const cls = { sent: "ok", failed: "down", muted: "muted" }[e.result] || "";
+  cell(row, e.result + (e.error ? ": " + e.error : ""), cls);
+  while (recent.rows.length > MAX_FEED_ROWS) recent.deleteRow(-1);
+}
+
+function follow() {
+  const feed = new EventSource("events");
+  const status = document.getElementById("feed");
+  feed.onopen = () => {
+    // The server replays its recent results on every (re)connect
+    document.getElementById("recent").replaceChildren();
+    status.textContent = "live";
+    status.className = "badge ok";
+  };
+  feed.onerror = () => {
+    status.textContent = "reconnecting";
+    status.className = "badge down";
+  };
+  feed.addEventListener("forward", (ev) => addForward(JSON.parse(ev.data)));
+}
+
+refresh();
+setInterval(refresh, REFRESH_MS);
+follow();
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// NTFY_UNKNOWN_APPS policies: where split topics send messages from apps
+// that are not (yet) in the app list.
+const (
+	unknownAppsTopic  = "topic"  // NTFY_TOPIC, the default when it is set
+	unknownAppsAppID  = "app-id" // a topic per app ID, app-<id>
+	unknownAppsReject = "reject" // drop the message and alert the error topic
+)
+
+var errUnknownApp = errors.New("message from unknown app rejected (NTFY_UNKNOWN_APPS=reject)")
+
+func parseUnknownApps(s, defaultTopic string) (string, error) {
+	switch policy := strings.ToLower(strings.TrimSpace(s)); policy {
+	case "":
+		if defaultTopic == "" {
+			return unknownAppsAppID, nil
+		}
+		return unknownAppsTopic, nil
+	case unknownAppsTopic:
+		if defaultTopic == "" {
+			return "", fmt.Errorf("NTFY_UNKNOWN_APPS=topic needs NTFY_TOPIC")
+		}
+		return policy, nil
+	case unknownAppsAppID, unknownAppsReject:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid NTFY_UNKNOWN_APPS %q (want topic, app-id or reject)", s)
+}
+
+// unknownAppTopic returns the split topic for appID when the app is not
+// known, or "" if such messages are rejected.
+func (c *Config) unknownAppTopic(appID int64) string {
+	switch c.UnknownApps {
+	case unknownAppsAppID:
+		return fmt.Sprintf("app-%d", appID)
+	case unknownAppsReject:
+		return ""
+	}
+	return c.NtfyTopic
+}
+
+// UnknownApps remembers app IDs that sent messages while not in the app
+// list, so each is synced for and reported only once.
+type UnknownApps struct {
+	mu   sync.Mutex
+	seen map[int64]bool
+}
+
+func NewUnknownApps() *UnknownApps {
+	return &UnknownApps{seen: make(map[int64]bool)}
+}
+
+// first records appID and reports whether it was not seen before.
+func (u *UnknownApps) first(appID int64) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.seen[appID] {
+		return false
+	}
+	u.seen[appID] = true
+	return true
+}
+
+// routeUnknownApp handles a message resolved to no topic because its app is
+// unknown and NTFY_UNKNOWN_APPS=reject. The app list may just be stale, so
+// the first message of an app triggers a sync; if the app is still unknown,
+// the message is rejected and the error topic alerted.
+func routeUnknownApp(ctx context.Context, b *Bridge, msg GotifyMessage) (topic, rule string, err error) {
+	first := b.unknown.first(msg.AppID)
+	if first {
+		if _, err := b.SyncNow(ctx); err != nil {
+			log.Printf("[SYNC ERROR] could not refresh apps for unknown app ID %d: %v", msg.AppID, err)
+		}
+		if topic, rule = resolveWith(b.cfg, b.store, msg, b.cfg.Rules().active); topic != "" {
+			return topic, rule, nil
+		}
+	}
+
+	if first {
+		body := fmt.Sprintf("Gotify app ID %d is not in the app list, so it has no split topic. "+
+			"Its messages are dropped (NTFY_UNKNOWN_APPS=reject); this is reported once per app.\nFirst message: %s",
+			msg.AppID, msg.Title)
+		if err := sendNtfy(ctx, b.cfg, b.cfg.NtfyErrorTopic, "Message from unknown app rejected", body, 4); err != nil {
+			log.Printf("[NTFY ERROR] failed to send unknown app alert: %v", err)
+		}
+	}
+	return "", "", errUnknownApp
+}
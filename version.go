@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X main.version=1.2.0 -X main.commit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// BuildInfo describes the running binary.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+	Tags      string `json:"tags,omitempty"` // build tags, e.g. "minimal"
+}
+
+// buildInfo falls back to the VCS data embedded by the Go toolchain when the
+// binary was built without ldflags.
+func buildInfo() BuildInfo {
+	bi := BuildInfo{Version: version, Commit: commit, BuildDate: buildDate, GoVersion: runtime.Version()}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if bi.Commit == "" {
+					bi.Commit = s.Value
+				}
+			case "vcs.time":
+				if bi.BuildDate == "" {
+					bi.BuildDate = s.Value
+				}
+			case "-tags":
+				bi.Tags = s.Value
+			}
+		}
+	}
+	if bi.Commit == "" {
+		bi.Commit = "unknown"
+	}
+	if bi.BuildDate == "" {
+		bi.BuildDate = "unknown"
+	}
+	return bi
+}
+
+func (bi BuildInfo) String() string {
+	s := fmt.Sprintf("gotify-to-ntfy-push %s (commit %s, built %s, %s", bi.Version, bi.Commit, bi.BuildDate, bi.GoVersion)
+	if bi.Tags != "" {
+		s += ", tags " + bi.Tags
+	}
+	return s + ")"
+}
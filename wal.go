@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// walCompactAfter is how many completed messages the WAL accumulates before
+// it is rewritten with only the pending ones.
+const walCompactAfter = 1000
+
+// walRecord is one line of WAL_FILE: a message read off the stream, or the
+// completion of one.
+type walRecord struct {
+	Op       string          `json:"op"` // received or done
+	Seq      uint64          `json:"seq"`
+	Received time.Time       `json:"received,omitzero"`
+	Message  json.RawMessage `json:"message,omitempty"` // the Gotify message as received
+}
+
+// WAL is a write-ahead log for the delivery pipeline. Every message is
+// appended and synced to disk before it is queued for a worker, and marked
+// done once its outcome is final: sent, failed for good, or delivered or
+// given up on by the offline buffer. Messages without a done record were
+// lost in a crash or a forced shutdown and are replayed on the next start,
+// so a message is forwarded at least once.
+type WAL struct {
+	mu      sync.Mutex
+	path    string
+	f       *os.File
+	seq     uint64
+	pending map[uint64]walRecord
+	done    int // done records since the last compaction
+}
+
+// OpenWAL reads the WAL at path, compacts it and returns the messages that
+// were never completed, oldest first. It returns nil without WAL_FILE;
+// all methods are no-ops on a nil WAL.
+func OpenWAL(path string) (*WAL, []GotifyMessage, error) {
+	if path == "" {
+		return nil, nil, nil
+	}
+	w := &WAL{path: path, pending: make(map[uint64]walRecord)}
+	if err := w.load(); err != nil {
+		return nil, nil, err
+	}
+	if err := w.compact(); err != nil {
+		return nil, nil, err
+	}
+
+	var replay []GotifyMessage
+	for _, r := range w.sorted() {
+		var msg GotifyMessage
+		if err := json.Unmarshal(r.Message, &msg); err != nil {
+			log.Printf("[WAL ERROR] skipping unreadable message seq=%d: %v", r.Seq, err)
+			continue
+		}
+		msg.rawExtras = rawExtras(r.Message)
+		msg.received = r.Received
+		msg.walSeq = r.Seq
+		replay = append(replay, msg)
+	}
+	return w, replay, nil
+}
+
+func (w *WAL) load() error {
+	f, err := os.Open(w.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 16<<20)
+	for line := 1; sc.Scan(); line++ {
+		var r walRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			// A crash can leave a partial last line; its message was
+			// never queued, so there is nothing to replay
+			log.Printf("[WAL WARN] ignoring unreadable line %d of %s: %v", line, w.path, err)
+			continue
+		}
+		w.seq = max(w.seq, r.Seq)
+		switch r.Op {
+		case "received":
+			w.pending[r.Seq] = r
+		case "done":
+			delete(w.pending, r.Seq)
+		}
+	}
+	return sc.Err()
+}
+
+func (w *WAL) sorted() []walRecord {
+	out := make([]walRecord, 0, len(w.pending))
+	for _, r := range w.pending {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Seq < out[j].Seq })
+	return out
+}
+
+// compact rewrites the file with only the pending messages and reopens it
+// for appending. Callers hold mu, or own w exclusively.
+func (w *WAL) compact() error {
+	if w.f != nil {
+		_ = w.f.Close()
+		w.f = nil
+	}
+	err := writeFileAtomic(w.path, func(f *os.File) error {
+		enc := json.NewEncoder(f)
+		for _, r := range w.sorted() {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return f.Sync()
+	})
+	if err != nil {
+		return err
+	}
+	w.f, err = os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	w.done = 0
+	return err
+}
+
+func (w *WAL) append(r walRecord, sync bool) error {
+	if w.f == nil {
+		return errors.New("not open")
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := w.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if sync {
+		return w.f.Sync()
+	}
+	return nil
+}
+
+// Received records msg, read off the stream as raw, before it is queued.
+func (w *WAL) Received(msg *GotifyMessage, raw []byte) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.seq++
+	r := walRecord{Op: "received", Seq: w.seq, Received: msg.received, Message: raw}
+	if err := w.append(r, true); err != nil {
+		log.Printf("[WAL ERROR] could not record message %d in %s: %v", msg.ID, w.path, err)
+		return
+	}
+	w.pending[r.Seq] = r
+	msg.walSeq = r.Seq
+}
+
+// Finished marks msg done after forwarding it returned err, unless it is
+// waiting in the offline buffer.
+func (w *WAL) Finished(msg GotifyMessage, err error) {
+	if !errors.Is(err, errBuffered) {
+		w.Done(msg)
+	}
+}
+
+// doneBuffered marks a message that left the offline buffer as completed.
+func (w *WAL) doneBuffered(m bufferedMsg) {
+	if m.Publish.msg != nil {
+		w.Done(*m.Publish.msg)
+	}
+}
+
+// Done marks msg as completed.
+func (w *WAL) Done(msg GotifyMessage) {
+	if w == nil || msg.walSeq == 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.pending[msg.walSeq]; !ok || w.f == nil {
+		return // closed on shutdown, the message is replayed on the next start
+	}
+	// Not synced: losing a done record only means a second delivery
+	if err := w.append(walRecord{Op: "done", Seq: msg.walSeq}, false); err != nil {
+		log.Printf("[WAL ERROR] could not complete message %d in %s: %v", msg.ID, w.path, err)
+		return
+	}
+	delete(w.pending, msg.walSeq)
+	if w.done++; w.done >= walCompactAfter {
+		if err := w.compact(); err != nil {
+			log.Printf("[WAL ERROR] could not compact %s: %v", w.path, err)
+		}
+	}
+}
+
+// Pending returns how many messages are not completed yet.
+func (w *WAL) Pending() int {
+	if w == nil {
+		return 0
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.pending)
+}
+
+// Close syncs and closes the file.
+func (w *WAL) Close() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Sync()
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	w.f = nil
+	return err
+}
+
+// replayWAL forwards the messages left over from the previous run before
+// the stream is connected, so they keep their order ahead of new ones.
+func replayWAL(ctx context.Context, b *Bridge, msgs []GotifyMessage) {
+	if len(msgs) == 0 {
+		return
+	}
+	log.Printf("[WAL] Replaying %d messages not completed before the last shutdown", len(msgs))
+	var failed int
+	for i, msg := range msgs {
+		if ctx.Err() != nil {
+			log.Printf("[WAL] Replay interrupted, %d messages are replayed on the next start", len(msgs)-i)
+			return
+		}
+		b.dedup.Seen(msg.AppID, msg.ID)
+		err := forwardToNtfy(ctx, b, msg)
+		if err != nil && !errors.Is(err, errBuffered) {
+			log.Printf("[WAL ERROR] replayed message %d could not be forwarded: %v", msg.ID, err)
+			b.stats.RecordForwardError(msg.AppID)
+			failed++
+		} else if err == nil {
+			b.stats.RecordForward(msg.AppID)
+		}
+		b.wal.Finished(msg, err)
+	}
+	log.Printf("[WAL] Replay finished: %d forwarded or buffered, %d failed", len(msgs)-failed, failed)
+}
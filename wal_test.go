@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// walReceive records a message in w the way the stream reader does.
+func walReceive(t *testing.T, w *WAL, id int64, extras string) GotifyMessage {
+	t.Helper()
+	raw := []byte(`{"id":` + jsonInt(id) + `,"appid":1,"title":"t","message":"m","priority":5` + extras + `}`)
+	var msg GotifyMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		t.Fatal(err)
+	}
+	msg.received = time.Now()
+	w.Received(&msg, raw)
+	if msg.walSeq == 0 {
+		t.Fatalf("message %d got no WAL record", id)
+	}
+	return msg
+}
+
+func jsonInt(n int64) string {
+	b, _ := json.Marshal(n)
+	return string(b)
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	n := 0
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		n++
+	}
+	return n
+}
+
+func TestWALReplayRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.log")
+	w, replay, err := OpenWAL(path)
+	if err != nil || len(replay) != 0 {
+		t.Fatalf("new WAL: %v, %d to replay", err, len(replay))
+	}
+
+	sent := walReceive(t, w, 1, "")
+	failed := walReceive(t, w, 2, "")
+	buffered := walReceive(t, w, 3, `,"extras":{"client::display":{"contentType":"text/markdown"}}`)
+	held := walReceive(t, w, 4, "")
+	lost := walReceive(t, w, 5, "") // in a worker when the process died
+
+	w.Finished(sent, nil)
+	w.Finished(failed, errors.New("ntfy answered 400"))
+	w.Finished(buffered, errBuffered)
+	w.Finished(held, errHeld) // errHeld wraps errBuffered: still pending
+	if n := w.Pending(); n != 3 {
+		t.Errorf("Pending() = %d, want 3", n)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	w, replay, err = OpenWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	var ids []int64
+	for _, m := range replay {
+		ids = append(ids, m.ID)
+	}
+	if len(ids) != 3 || ids[0] != 3 || ids[1] != 4 || ids[2] != 5 {
+		t.Fatalf("replayed %v, want [3 4 5] in order", ids)
+	}
+	r := replay[0]
+	if r.walSeq != buffered.walSeq || !r.received.Equal(buffered.received) || r.Title != "t" || r.Priority != 5 {
+		t.Errorf("replayed message lost fields: %+v", r)
+	}
+	if string(r.rawExtras) != `{"client::display":{"contentType":"text/markdown"}}` {
+		t.Errorf("rawExtras = %s", r.rawExtras)
+	}
+	// Opening compacts the file down to the pending messages
+	if n := countLines(t, path); n != 3 {
+		t.Errorf("WAL has %d lines after reopening, want 3", n)
+	}
+
+	// Sequence numbers continue after the replayed ones
+	next := walReceive(t, w, 6, "")
+	if next.walSeq <= lost.walSeq {
+		t.Errorf("new seq %d not after %d", next.walSeq, lost.walSeq)
+	}
+	for _, m := range replay {
+		w.Done(m)
+	}
+	if n := w.Pending(); n != 1 {
+		t.Errorf("Pending() = %d, want 1", n)
+	}
+}
+
+func TestWALCompactsAfterDoneRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.log")
+	w, _, err := OpenWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	pending := walReceive(t, w, 1, "")
+	for i := range walCompactAfter - 1 {
+		w.Done(walReceive(t, w, int64(i+2), ""))
+	}
+	// One received line each, plus a done line for all but the pending one
+	if n, want := countLines(t, path), 1+2*(walCompactAfter-1); n != want {
+		t.Fatalf("WAL has %d lines before compaction, want %d", n, want)
+	}
+	w.Done(walReceive(t, w, walCompactAfter+1, ""))
+	if n := countLines(t, path); n != 1 {
+		t.Errorf("WAL has %d lines after %d done records, want only the pending one", n, walCompactAfter)
+	}
+
+	// Appends go on after the compaction
+	w.Done(pending)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, replay, err := OpenWAL(path); err != nil || len(replay) != 0 {
+		t.Errorf("reopened: %v, %d to replay, want none", err, len(replay))
+	}
+}
+
+func TestWALIgnoresPartialLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.log")
+	w, _, err := OpenWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	walReceive(t, w, 1, "")
+	w.Close()
+
+	// A crash in the middle of a write
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"received","seq":2,"mess`)
+	f.Close()
+
+	w, replay, err := OpenWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if len(replay) != 1 || replay[0].ID != 1 {
+		t.Errorf("replayed %d messages, want message 1 only", len(replay))
+	}
+}
+
+func TestWALNil(t *testing.T) {
+	w, replay, err := OpenWAL("")
+	if w != nil || replay != nil || err != nil {
+		t.Fatalf("OpenWAL without a path: %v, %v, %v", w, replay, err)
+	}
+	// All methods are no-ops
+	msg := GotifyMessage{ID: 1, walSeq: 1}
+	w.Received(&msg, []byte(`{}`))
+	w.Finished(msg, nil)
+	w.Done(msg)
+	if w.Pending() != 0 || w.Close() != nil {
+		t.Error("nil WAL is not a no-op")
+	}
+}
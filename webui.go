@@ -0,0 +1,153 @@
+//go:build !minimal
+
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"time"
+)
+
+//go:embed ui
+var uiFiles embed.FS
+
+// uiApp is one row of the web UI's app table.
+type uiApp struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	Topic        string    `json:"topic"`
+	Muted        bool      `json:"muted"`
+	Forwarded    int64     `json:"forwarded"`
+	Failed       int64     `json:"failed"`
+	LastDelivery time.Time `json:"last_delivery,omitzero"`
+}
+
+// uiState is everything the web UI dashboard shows, polled from /ui/state.
+type uiState struct {
+	Connected      bool      `json:"connected"`
+	ConnectedSince time.Time `json:"connected_since,omitzero"`
+	Gotify         string    `json:"gotify"`
+	Uptime         string    `json:"uptime"`
+	Version        string    `json:"version"`
+	Paused         bool      `json:"paused"`
+	ObserveOnly    bool      `json:"observe_only"`
+	Forwarded      int64     `json:"forwarded"`
+	ForwardErrs    int64     `json:"forward_errors"`
+	ConnectErrs    int64     `json:"connect_errors"`
+	SyncErrs       int64     `json:"sync_errors"`
+	Dropped        int64     `json:"dropped"`
+	StreamQueue    int       `json:"stream_queue"`
+	StreamQueueCap int       `json:"stream_queue_capacity"`
+	OfflineBuffer  int       `json:"offline_buffer"`
+	Apps           []uiApp   `json:"apps"`
+}
+
+func handleUIState(b *Bridge) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg := b.cfg
+		snap := b.stats.Snapshot(b.store)
+		connected, since, _ := b.stats.ConnectionState()
+		s := uiState{
+			Connected:     connected,
+			Gotify:        redactURL(cfg.ActiveGotifyURL()),
+			Uptime:        snap.Uptime,
+			Version:       version,
+			Paused:        b.paused.Load(),
+			ObserveOnly:   cfg.ObserveOnly,
+			Forwarded:     snap.Forwarded,
+			ForwardErrs:   snap.ForwardErrs,
+			ConnectErrs:   snap.ConnectErrs,
+			SyncErrs:      snap.SyncErrs,
+			Dropped:       snap.Dropped,
+			OfflineBuffer: b.buffer.Len(),
+			Apps:          []uiApp{},
+		}
+		if connected {
+			s.ConnectedSince = since
+		}
+		if q := b.queue.Load(); q != nil {
+			s.StreamQueue, s.StreamQueueCap = len(*q), cap(*q)
+		}
+
+		counts := make(map[int64]AppStats, len(snap.Apps))
+		for _, a := range snap.Apps {
+			counts[a.AppID] = a
+		}
+		for _, app := range b.store.All() {
+			topic := cfg.NtfyTopic
+			if cfg.SplitTopics {
+				topic = b.store.TopicFor(app.ID, cfg.NtfyTopic)
+			}
+			c := counts[app.ID]
+			s.Apps = append(s.Apps, uiApp{ID: app.ID, Name: app.Name, Topic: topic, Muted: b.mutes.Muted(app.ID),
+				Forwarded: c.Forwarded, Failed: c.Failed, LastDelivery: c.LastDelivery})
+		}
+		writeJSON(w, http.StatusOK, s)
+	}
+}
+
+// feedKeepAlive is how often an idle feed sends an SSE comment, so proxies
+// do not close the connection.
+const feedKeepAlive = 30 * time.Second
+
+// handleUIEvents streams forward results as Server-Sent Events, starting
+// with the most recent ones.
+func handleUIEvents(b *Bridge) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		seed, events, cancel := b.recent.Subscribe()
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no") // nginx would buffer the stream otherwise
+		w.WriteHeader(http.StatusOK)
+		for i := len(seed) - 1; i >= 0; i-- {
+			writeEvent(w, seed[i])
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		keepAlive := time.NewTicker(feedKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e := <-events:
+				writeEvent(w, e)
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeEvent(w io.Writer, e forwardEvent) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: forward\ndata: %s\n\n", data)
+}
+
+// registerUI mounts the embedded dashboard at /ui/ and redirects / to it.
+func registerUI(mux *http.ServeMux, b *Bridge) {
+	static, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		panic(err) // the directory is embedded at build time
+	}
+	mux.HandleFunc("GET /ui/", requireAdmin(b.cfg, http.StripPrefix("/ui/", http.FileServerFS(static)).ServeHTTP))
+	mux.HandleFunc("GET /ui/state", requireAdmin(b.cfg, handleUIState(b)))
+	mux.HandleFunc("GET /ui/events", requireAdmin(b.cfg, handleUIEvents(b)))
+	mux.HandleFunc("POST /ui/test", requireAdmin(b.cfg, handleTestMessage(b)))
+	mux.Handle("GET /{$}", http.RedirectHandler("/ui/", http.StatusFound))
+}